@@ -2,20 +2,32 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"database/sql"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"github.com/ArtemKVD/WB-TechL0/internal/api"
 	"github.com/ArtemKVD/WB-TechL0/internal/cache"
 	"github.com/ArtemKVD/WB-TechL0/internal/config"
+	"github.com/ArtemKVD/WB-TechL0/internal/consumer"
+	"github.com/ArtemKVD/WB-TechL0/internal/health"
+	"github.com/ArtemKVD/WB-TechL0/internal/ingest"
+	"github.com/ArtemKVD/WB-TechL0/internal/lifecycle"
 	"github.com/ArtemKVD/WB-TechL0/internal/logger"
+	"github.com/ArtemKVD/WB-TechL0/internal/metrics"
+	"github.com/ArtemKVD/WB-TechL0/internal/migrations"
+	"github.com/ArtemKVD/WB-TechL0/internal/outbox"
+	"github.com/ArtemKVD/WB-TechL0/internal/producer"
+	"github.com/ArtemKVD/WB-TechL0/internal/rules"
 	"github.com/ArtemKVD/WB-TechL0/internal/server"
 	database "github.com/ArtemKVD/WB-TechL0/internal/storage"
-	"github.com/ArtemKVD/WB-TechL0/pkg/models"
-	"github.com/ArtemKVD/WB-TechL0/pkg/validator"
+	"github.com/ArtemKVD/WB-TechL0/internal/transport"
+	"github.com/ArtemKVD/WB-TechL0/pkg/faker"
 	_ "github.com/lib/pq"
-	"github.com/segmentio/kafka-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 )
 
 func main() {
@@ -25,102 +37,237 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
-	kafkaReader := Kafkainit(cfg)
-	defer closeKafka(kafkaReader)
+	rulesReloader := Rulesinit(cfg)
+	broker := Transportinit(ctx, cfg)
+	subscriber := broker.Subscriber(cfg.Kafka.Topic, cfg.Kafka.GroupID)
+	cacheService := cache.NewCache(cfg.Cache)
+	dbStorage := Storageinit(ctx, cfg)
 
-	cacheService := cache.NewCache()
-	dbStorage := Databaseinit(cfg)
-	defer closeDatabase(dbStorage)
+	if postgres, ok := dbStorage.(*database.Database); ok && cfg.Database.MigrateOnStart {
+		migrate(ctx, postgres)
+	}
 
-	loadCache(cacheService, dbStorage)
-	startServer(cacheService, dbStorage, cfg)
-	processMessages(ctx, kafkaReader, cacheService, dbStorage)
-}
+	serviceMetrics := Metricsinit(subscriber, cacheService, dbStorage)
+	orderStorage := serviceMetrics.InstrumentStorage(dbStorage)
+	probes := Healthinit(cfg, broker, dbStorage)
+	ingestSink, err := ingest.New(cfg, orderStorage, cacheService, broker)
+	if err != nil {
+		logger.Log.Fatal("Error creating ingest sink: ", err)
+	}
+	ingestHandler := api.NewIngestHandler(ingestSink, cfg.Ingest)
+	httpServer := server.NewServer(cacheService, orderStorage, cfg.HTTP, serviceMetrics, probes, ingestHandler)
 
-func Kafkainit(cfg *config.Config) *kafka.Reader {
-	return kafka.NewReader(kafka.ReaderConfig{
-		Brokers: []string{cfg.Kafka.Broker},
-		GroupID: cfg.Kafka.GroupID,
-		Topic:   cfg.Kafka.Topic,
+	opts := []consumer.Option{consumer.WithMetrics(serviceMetrics)}
+	var deadLetterPublisher transport.Publisher
+	if cfg.Kafka.DeadLetter.Topic != "" {
+		deadLetterPublisher = broker.Publisher(cfg.Kafka.DeadLetter.Topic)
+		opts = append(opts, consumer.WithDeadLetter(deadLetterPublisher))
+	}
+	orderConsumer := consumer.NewConsumer(subscriber, cacheService, orderStorage, cfg.Kafka, opts...)
+
+	var eventPublisher transport.Publisher
+	var relay *outbox.Relay
+	if store, ok := dbStorage.(outbox.Store); !ok {
+		logger.Log.WithField("storage", cfg.Storage.Kind).Warn("Storage has no outbox, order events are not published")
+	} else if cfg.Outbox.Topic != "" {
+		eventPublisher = broker.Publisher(cfg.Outbox.Topic)
+		relay = outbox.NewRelay(store, eventPublisher, cfg.Outbox)
+	} else {
+		logger.Log.Warn("OUTBOX_TOPIC is not set, order events are not published")
+	}
+
+	// Components are stopped in the order they are added: readiness is
+	// withdrawn first, then HTTP intake and the consumer are drained, and
+	// connections are closed last.
+	service := lifecycle.New(cfg.ShutdownTimeout)
+	service.Add("readiness", nil, func(stopCtx context.Context) error {
+		probes.MarkShuttingDown()
+		// Load balancers only stop routing here after they see /readyz fail.
+		select {
+		case <-time.After(cfg.ShutdownDrainDelay):
+		case <-stopCtx.Done():
+		}
+		return nil
+	})
+	service.Add("http", httpServer.Run, httpServer.Shutdown)
+	// The consumer is stopped while it may still be loading the cache,
+	// which the consumer's own Stop does not interrupt.
+	consumerStopping := make(chan struct{})
+	service.Add("consumer", func(runCtx context.Context) error {
+		if !loadCache(runCtx, consumerStopping, cacheService, orderStorage) {
+			return nil
+		}
+		probes.MarkReady()
+		orderConsumer.Run(runCtx)
+		return nil
+	}, func(context.Context) error {
+		close(consumerStopping)
+		orderConsumer.Stop()
+		return nil
+	})
+	if relay != nil {
+		service.Add("outbox relay", func(runCtx context.Context) error {
+			relay.Run(runCtx)
+			return nil
+		}, func(context.Context) error {
+			relay.Stop()
+			return nil
+		})
+	}
+	if rulesReloader != nil {
+		service.Add("validation rules", func(runCtx context.Context) error {
+			rulesReloader.Run(runCtx)
+			return nil
+		}, func(context.Context) error {
+			rulesReloader.Stop()
+			return nil
+		})
+	}
+	cacheService.StartJanitor()
+	service.Add("cache janitor", nil, func(context.Context) error {
+		cacheService.StopJanitor()
+		return nil
+	})
+	if deadLetterPublisher != nil {
+		service.Add("dead letter publisher", nil, func(context.Context) error {
+			return deadLetterPublisher.Close()
+		})
+	}
+	if publishSink, ok := ingestSink.(*ingest.PublishSink); ok {
+		service.Add("ingest publisher", nil, func(context.Context) error {
+			return publishSink.Close()
+		})
+	}
+	if eventPublisher != nil {
+		service.Add("outbox publisher", nil, func(context.Context) error {
+			return eventPublisher.Close()
+		})
+	}
+	service.Add("subscriber", nil, func(context.Context) error {
+		return subscriber.Close()
+	})
+	if natsBroker, ok := broker.(*transport.NATSBroker); ok {
+		service.Add("nats", nil, func(context.Context) error {
+			return natsBroker.Close()
+		})
+	}
+	service.Add("storage", nil, func(context.Context) error {
+		return dbStorage.Close()
 	})
-}
 
-func Databaseinit(cfg *config.Config) *database.Database {
-	dbStorage := database.NewDatabase(cfg.Database)
-	err := dbStorage.Connect()
+	err = service.Run(ctx)
 	if err != nil {
-		logger.Log.Fatal("Error connecting to DB: ", err)
+		logger.Log.Fatal("Service stopped with error: ", err)
 	}
-	return dbStorage
+	logger.Log.Info("Service stopped")
 }
 
-func closeKafka(reader *kafka.Reader) {
-	err := reader.Close()
+// Rulesinit loads the declarative validation rules, if configured. The
+// service does not start with an invalid rules file.
+func Rulesinit(cfg *config.Config) *rules.Reloader {
+	if cfg.Validation.RulesPath == "" {
+		return nil
+	}
+
+	reloader := rules.NewReloader(cfg.Validation)
+	err := reloader.Load()
 	if err != nil {
-		logger.Log.Error("Close Kafka reader error: ", err)
+		logger.Log.Fatal("Error loading validation rules: ", err)
 	}
+	return reloader
 }
 
-func closeDatabase(dbStorage *database.Database) {
-	err := dbStorage.Close()
+// Transportinit returns the configured message broker. The in-process
+// broker is seeded with generated orders, as no other process can reach it.
+func Transportinit(ctx context.Context, cfg *config.Config) transport.Broker {
+	broker, err := transport.New(cfg)
 	if err != nil {
-		logger.Log.Error("Close DB error: ", err)
+		logger.Log.Fatal("Error creating message transport: ", err)
 	}
+
+	if cfg.Transport.Kind == transport.Memory {
+		publisher := broker.Publisher(cfg.Kafka.Topic)
+		sent := producer.Send(ctx, publisher, faker.GenerateTestOrders(cfg.Transport.MemorySeedOrders))
+		logger.Log.WithField("orders", sent).Info("In-memory transport seeded with generated orders")
+	}
+	return broker
 }
 
-func loadCache(cacheService *cache.Cache, dbStorage *database.Database) {
-	err := cacheService.LoadCacheFromDB(dbStorage)
+// Storageinit connects the configured order storage.
+func Storageinit(ctx context.Context, cfg *config.Config) database.OrderStorage {
+	dbStorage, err := database.New(cfg)
 	if err != nil {
-		logger.Log.Error("Error loading cache: ", err)
+		logger.Log.Fatal("Error creating storage: ", err)
 	}
-}
 
-func startServer(cacheService *cache.Cache, dbStorage *database.Database, cfg *config.Config) {
-	httpServer := server.NewServer(cacheService, dbStorage, cfg.HTTP)
-	go httpServer.Start()
+	err = dbStorage.Connect(ctx)
+	if err != nil {
+		logger.Log.Fatal("Error connecting to DB: ", err)
+	}
+	return dbStorage
 }
 
-func processMessages(ctx context.Context, kafkaReader *kafka.Reader, cacheService *cache.Cache, dbStorage *database.Database) {
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-			processMessage(ctx, kafkaReader, cacheService, dbStorage)
-		}
+func Metricsinit(subscriber transport.Subscriber, cacheService *cache.Cache, dbStorage database.OrderStorage) *metrics.Metrics {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		metrics.NewCacheCollector(cacheService),
+	)
+	if pool, ok := dbStorage.(interface{ DB() *sql.DB }); ok {
+		registry.MustRegister(collectors.NewDBStatsCollector(pool.DB(), "orders"))
+	}
+	if kafkaSubscriber, ok := subscriber.(*transport.KafkaSubscriber); ok {
+		registry.MustRegister(metrics.NewKafkaCollector(kafkaSubscriber))
 	}
+	return metrics.New(registry)
 }
 
-func processMessage(ctx context.Context, kafkaReader *kafka.Reader, cacheService *cache.Cache, dbStorage *database.Database) {
-	message, err := kafkaReader.ReadMessage(ctx)
-	if err != nil {
-		if ctx.Err() != nil {
-			return
-		}
-		logger.Log.Error("Error reading message: ", err)
-		return
+func Healthinit(cfg *config.Config, broker transport.Broker, dbStorage database.OrderStorage) *health.Health {
+	probes := health.New(cfg.HTTP.HealthCheckTimeout)
+	if pinger, ok := dbStorage.(interface{ Ping(context.Context) error }); ok {
+		probes.AddCheck(cfg.Storage.Kind, pinger.Ping)
 	}
+	switch broker := broker.(type) {
+	case *transport.KafkaBroker:
+		probes.AddCheck("kafka", health.KafkaCheck(cfg.Kafka.Broker, cfg.Kafka.Topic))
+	case *transport.NATSBroker:
+		probes.AddCheck("nats", broker.Ping)
+	}
+	return probes
+}
 
-	var order models.Order
-	err = json.Unmarshal(message.Value, &order)
+func migrate(ctx context.Context, dbStorage *database.Database) {
+	migrator, err := migrations.New(dbStorage.DB())
 	if err != nil {
-		logger.Log.Error("Error unmarshaling message: ", err)
-		return
+		logger.Log.Fatal("Error loading migrations: ", err)
 	}
 
-	err = validator.ValidateOrder(order)
+	applied, err := migrator.Up(ctx)
 	if err != nil {
-		logger.Log.Error("Validation failed: ", err)
-		return
+		logger.Log.Fatal("Error applying migrations: ", err)
 	}
+	logger.Log.WithField("applied", applied).Info("Database schema is up to date")
+}
 
-	cacheService.Set(order)
-	logger.Log.Info("Order cached: ", order.OrderUID)
+const cacheLoadRetryDelay = 5 * time.Second
 
-	err = dbStorage.SaveOrder(order)
-	if err != nil {
-		logger.Log.Error("Error saving order: ", err)
-		return
+// loadCache fills the cache from storage, retrying until it succeeds. It
+// reports false if ctx is done or stopping is closed first.
+func loadCache(ctx context.Context, stopping <-chan struct{}, cacheService *cache.Cache, dbStorage database.OrderStorage) bool {
+	for {
+		err := cacheService.LoadCacheFromDB(ctx, dbStorage)
+		if err == nil {
+			return true
+		}
+		logger.Log.Error("Error loading cache: ", err)
+
+		select {
+		case <-time.After(cacheLoadRetryDelay):
+		case <-ctx.Done():
+			return false
+		case <-stopping:
+			return false
+		}
 	}
-	logger.Log.Info("Order saved to DB: ", order.OrderUID)
 }
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/config"
+	"github.com/ArtemKVD/WB-TechL0/internal/consumer"
+	"github.com/ArtemKVD/WB-TechL0/internal/logger"
+	"github.com/ArtemKVD/WB-TechL0/internal/transport"
+	"github.com/sirupsen/logrus"
+)
+
+// Re-injects messages from the dead letter topic into the main orders topic,
+// e.g. after the consumer has been fixed to accept them.
+func main() {
+	logger.Init()
+	cfg := config.Load()
+
+	broker := flag.String("broker", cfg.Kafka.Broker, "Kafka broker address")
+	limit := flag.Int("limit", 0, "maximum number of messages to replay, 0 for all")
+	idle := flag.Duration("idle", 10*time.Second, "stop after no message arrives for this long")
+	flag.Parse()
+
+	if cfg.Kafka.DeadLetter.Topic == "" {
+		logger.Log.Fatal("KAFKA_DLQ_TOPIC is not set")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	subscriber := transport.NewKafkaSubscriber(*broker, cfg.Kafka.DeadLetter.ReplayGroupID, cfg.Kafka.DeadLetter.Topic)
+	defer func() {
+		err := subscriber.Close()
+		if err != nil {
+			logger.Log.Error("Close Kafka reader error: ", err)
+		}
+	}()
+
+	publisher := transport.NewKafkaPublisher(*broker, cfg.Kafka.Topic)
+	defer func() {
+		err := publisher.Close()
+		if err != nil {
+			logger.Log.Error("Close Kafka writer error: ", err)
+		}
+	}()
+
+	replayed, err := consumer.ReplayDeadLetters(ctx, subscriber, publisher, *limit, *idle)
+	fields := logrus.Fields{
+		"from":     cfg.Kafka.DeadLetter.Topic,
+		"to":       cfg.Kafka.Topic,
+		"replayed": replayed,
+	}
+	if err != nil && ctx.Err() == nil {
+		logger.Log.WithFields(fields).Fatal("Dead letter replay failed: ", err)
+	}
+	logger.Log.WithFields(fields).Info("Dead letter replay finished")
+}
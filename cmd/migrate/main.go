@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/config"
+	"github.com/ArtemKVD/WB-TechL0/internal/logger"
+	"github.com/ArtemKVD/WB-TechL0/internal/migrations"
+	database "github.com/ArtemKVD/WB-TechL0/internal/storage"
+	_ "github.com/lib/pq"
+)
+
+const usage = `Usage: migrate [flags] up|down|version
+
+  up       apply all pending migrations
+  down     revert the latest migrations (see -steps)
+  version  print the current schema version
+
+Flags:
+`
+
+func main() {
+	logger.Init()
+	cfg := config.Load()
+
+	steps := flag.Int("steps", 1, "number of migrations to revert with down")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	dbStorage := database.NewDatabase(cfg.Database)
+	err := dbStorage.Connect(ctx)
+	if err != nil {
+		logger.Log.Fatal("Error connecting to DB: ", err)
+	}
+	defer func() {
+		err := dbStorage.Close()
+		if err != nil {
+			logger.Log.Error("Close DB error: ", err)
+		}
+	}()
+
+	migrator, err := migrations.New(dbStorage.DB())
+	if err != nil {
+		logger.Log.Fatal("Error loading migrations: ", err)
+	}
+
+	switch flag.Arg(0) {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			logger.Log.Fatal("Migration failed: ", err)
+		}
+		logger.Log.WithField("applied", applied).Info("Migrations applied")
+	case "down":
+		reverted, err := migrator.Down(ctx, *steps)
+		if err != nil {
+			logger.Log.Fatal("Migration failed: ", err)
+		}
+		logger.Log.WithField("reverted", reverted).Info("Migrations reverted")
+	case "version":
+		version, err := migrator.Version(ctx)
+		if err != nil {
+			logger.Log.Fatal("Reading schema version failed: ", err)
+		}
+		fmt.Println(version)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
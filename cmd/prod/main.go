@@ -2,34 +2,44 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"io"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"github.com/ArtemKVD/WB-TechL0/internal/config"
 	"github.com/ArtemKVD/WB-TechL0/internal/logger"
+	"github.com/ArtemKVD/WB-TechL0/internal/producer"
+	"github.com/ArtemKVD/WB-TechL0/internal/transport"
 	"github.com/ArtemKVD/WB-TechL0/pkg/faker"
-
-	"github.com/segmentio/kafka-go"
 )
 
 func main() {
+	logger.Init()
 	cfg := config.Load()
-	topic := cfg.Kafka.Topic
-	broker := "localhost:9092"
 
-	w := &kafka.Writer{
-		Addr:     kafka.TCP(broker),
-		Topic:    topic,
-		Balancer: &kafka.LeastBytes{},
+	if cfg.Transport.Kind == transport.Memory {
+		logger.Log.Warn("MESSAGE_TRANSPORT=memory, orders are not visible to the consumer process")
+	}
+	broker, err := transport.New(cfg)
+	if err != nil {
+		logger.Log.Fatal("Error creating message transport: ", err)
+	}
+	// The NATS broker owns the connection its publishers share.
+	if closer, ok := broker.(io.Closer); ok {
+		defer func() {
+			err := closer.Close()
+			if err != nil {
+				logger.Log.Error("Error closing message transport: ", err)
+			}
+		}()
 	}
 
+	publisher := broker.Publisher(cfg.Kafka.Topic)
 	defer func() {
-		err := w.Close()
+		err := publisher.Close()
 		if err != nil {
-			logger.Log.Error("Error close kafka writer:", err)
+			logger.Log.Error("Error closing publisher: ", err)
 		}
 	}()
 
@@ -37,32 +47,5 @@ func main() {
 	defer stop()
 
 	orders := faker.GenerateTestOrders(10)
-
-	for _, order := range orders {
-		select {
-		case <-ctx.Done():
-			logger.Log.Info("Shutting down producer")
-			return
-		default:
-			sendOrder, err := json.Marshal(order)
-			if err != nil {
-				logger.Log.Error("marshal order error: ", err)
-				continue
-			}
-
-			err = w.WriteMessages(ctx,
-				kafka.Message{
-					Value: sendOrder,
-				},
-			)
-
-			if err != nil {
-				logger.Log.Error("write message error: ", err)
-				continue
-			}
-
-			logger.Log.WithField("order_uid", order.OrderUID).Info("order written to kafka")
-			time.Sleep(1 * time.Microsecond)
-		}
-	}
+	producer.Send(ctx, publisher, orders)
 }
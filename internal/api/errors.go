@@ -0,0 +1,41 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// Machine-readable error codes returned in ErrorResponse.
+const (
+	CodeMissingOrderID  = "missing_order_id"
+	CodeOrderNotFound   = "order_not_found"
+	CodeStorageTimeout  = "storage_timeout"
+	CodeStorageError    = "storage_error"
+	CodeInvalidArgument = "invalid_argument"
+
+	CodeInvalidBody           = "invalid_body"
+	CodeUnsupportedMediaType  = "unsupported_media_type"
+	CodeBodyTooLarge          = "body_too_large"
+	CodeBatchTooLarge         = "batch_too_large"
+	CodeEmptyBatch            = "empty_batch"
+	CodeIdempotencyInProgress = "idempotency_in_progress"
+	CodeIdempotencyKeyReused  = "idempotency_key_reused"
+)
+
+// ErrorResponse is the envelope of every error returned by the API.
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+type ErrorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func writeError(c *gin.Context, status int, code, message string) {
+	c.AbortWithStatusJSON(status, ErrorResponse{
+		Error: ErrorBody{
+			Code:    code,
+			Message: message,
+		},
+	})
+}
@@ -1,15 +1,22 @@
 package api
 
 import (
+	"context"
 	"errors"
 	"net/http"
+	"strings"
 
 	"github.com/ArtemKVD/WB-TechL0/internal/cache"
 	"github.com/ArtemKVD/WB-TechL0/internal/logger"
 	database "github.com/ArtemKVD/WB-TechL0/internal/storage"
+	"github.com/ArtemKVD/WB-TechL0/pkg/models"
 	"github.com/gin-gonic/gin"
 )
 
+// statusClientClosedRequest is the non-standard nginx code for requests
+// abandoned by the client before a response could be written.
+const statusClientClosedRequest = 499
+
 type Handler struct {
 	cache   cache.CacheService
 	storage database.OrderStorage
@@ -27,23 +34,65 @@ func (h *Handler) IndexPage(c *gin.Context) {
 	c.HTML(http.StatusOK, "index.html", gin.H{})
 }
 
+// GetOrder serves /order?id=. It renders order.html unless the client asks
+// for JSON through the Accept header.
 func (h *Handler) GetOrder(c *gin.Context) {
-	orderUID := c.Query("id")
+	order, ok := h.findOrder(c, c.Query("id"))
+	if !ok {
+		return
+	}
+
+	logger.Log.Info("order request completed")
+	switch c.NegotiateFormat(gin.MIMEHTML, gin.MIMEJSON) {
+	case gin.MIMEJSON:
+		c.JSON(http.StatusOK, order)
+	default:
+		c.HTML(http.StatusOK, "order.html", order)
+	}
+}
+
+// GetOrderJSON serves GET /api/v1/orders/:uid.
+func (h *Handler) GetOrderJSON(c *gin.Context) {
+	order, ok := h.findOrder(c, c.Param("uid"))
+	if !ok {
+		return
+	}
+
+	logger.Log.Info("order request completed")
+	c.JSON(http.StatusOK, order)
+}
+
+// findOrder looks the order up in the cache and then in storage. On failure
+// it writes the error response and returns false.
+func (h *Handler) findOrder(c *gin.Context, orderUID string) (models.Order, bool) {
+	orderUID = strings.TrimSpace(orderUID)
+	if orderUID == "" {
+		writeError(c, http.StatusBadRequest, CodeMissingOrderID, "Order id is required")
+		return models.Order{}, false
+	}
 
 	order, found := h.cache.Get(orderUID)
-	if !found {
-		var err error
-		order, err = h.storage.GetOrder(orderUID)
-		if err != nil {
-			if errors.Is(err, database.ErrNotFound) {
-				c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
-			} else {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-			}
-			return
+	if found {
+		return order, true
+	}
+
+	order, err := h.storage.GetOrder(c.Request.Context(), orderUID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrNotFound):
+			writeError(c, http.StatusNotFound, CodeOrderNotFound, "Order not found")
+		case errors.Is(err, context.Canceled):
+			logger.Log.WithField("order_uid", orderUID).Info("order request canceled by client")
+			c.AbortWithStatus(statusClientClosedRequest)
+		case errors.Is(err, context.DeadlineExceeded):
+			writeError(c, http.StatusGatewayTimeout, CodeStorageTimeout, "Database timeout")
+		default:
+			logger.Log.WithField("order_uid", orderUID).Error("Get order error: ", err)
+			writeError(c, http.StatusInternalServerError, CodeStorageError, "Database error")
 		}
-		h.cache.Set(order)
+		return models.Order{}, false
 	}
-	logger.Log.Info("order request completed")
-	c.HTML(http.StatusOK, "order.html", order)
+
+	h.cache.Set(order)
+	return order, true
 }
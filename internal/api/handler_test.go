@@ -1,7 +1,10 @@
 package api_test
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -13,6 +16,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func setupTestRouter(handler *api.Handler) *gin.Engine {
@@ -23,6 +27,7 @@ func setupTestRouter(handler *api.Handler) *gin.Engine {
 
 	router.GET("/", handler.IndexPage)
 	router.GET("/order", handler.GetOrder)
+	router.GET("/api/v1/orders/:uid", handler.GetOrderJSON)
 
 	return router
 }
@@ -42,6 +47,8 @@ func TestHandler_GetOrder(t *testing.T) {
 			OrderUID:    "test1",
 			TrackNumber: "WBILMTESTTRACK",
 			Entry:       "WBIL",
+			Payment:     models.Payment{Currency: "USD", Amount: 1817},
+			Items:       []models.Item{{Price: 453, Status: models.ItemStatusAccepted}},
 		}
 
 		mockCache.EXPECT().
@@ -55,6 +62,9 @@ func TestHandler_GetOrder(t *testing.T) {
 
 		assert.Equal(t, http.StatusOK, w.Code)
 		assert.Contains(t, w.Body.String(), "test1")
+		assert.Contains(t, w.Body.String(), "<td>18.17</td>")
+		assert.Contains(t, w.Body.String(), "<td>4.53</td>")
+		assert.Contains(t, w.Body.String(), "<td>accepted (202)</td>")
 	})
 
 	t.Run("order not found in cache and found in database", func(t *testing.T) {
@@ -70,7 +80,7 @@ func TestHandler_GetOrder(t *testing.T) {
 			Times(1)
 
 		mockStorage.EXPECT().
-			GetOrder("test2").
+			GetOrder(gomock.Any(), "test2").
 			Return(expectedOrder, nil).
 			Times(1)
 
@@ -93,7 +103,7 @@ func TestHandler_GetOrder(t *testing.T) {
 			Times(1)
 
 		mockStorage.EXPECT().
-			GetOrder("test3").
+			GetOrder(gomock.Any(), "test3").
 			Return(models.Order{}, database.ErrNotFound).
 			Times(1)
 
@@ -112,7 +122,7 @@ func TestHandler_GetOrder(t *testing.T) {
 			Times(1)
 
 		mockStorage.EXPECT().
-			GetOrder("test4").
+			GetOrder(gomock.Any(), "test4").
 			Return(models.Order{}, errors.New("database connection failed")).
 			Times(1)
 
@@ -123,6 +133,165 @@ func TestHandler_GetOrder(t *testing.T) {
 		assert.Equal(t, http.StatusInternalServerError, w.Code)
 		assert.Contains(t, w.Body.String(), "Database error")
 	})
+
+	t.Run("database timeout", func(t *testing.T) {
+		mockCache.EXPECT().
+			Get("test5").
+			Return(models.Order{}, false).
+			Times(1)
+
+		mockStorage.EXPECT().
+			GetOrder(gomock.Any(), "test5").
+			Return(models.Order{}, fmt.Errorf("query order: %w", context.DeadlineExceeded)).
+			Times(1)
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/order?id=test5", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+		assert.Contains(t, w.Body.String(), "Database timeout")
+	})
+
+	t.Run("request context is passed to storage", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		mockCache.EXPECT().
+			Get("test6").
+			Return(models.Order{}, false).
+			Times(1)
+
+		mockStorage.EXPECT().
+			GetOrder(gomock.Any(), "test6").
+			DoAndReturn(func(ctx context.Context, orderUID string) (models.Order, error) {
+				cancel()
+				<-ctx.Done()
+				return models.Order{}, ctx.Err()
+			}).
+			Times(1)
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/order?id=test6", nil).WithContext(ctx)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, 499, w.Code)
+		assert.Empty(t, w.Body.String())
+	})
+}
+
+func TestHandler_GetOrderNegotiation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockCache := mocks.NewMockCacheService(ctrl)
+	mockStorage := mocks.NewMockOrderStorage(ctrl)
+
+	handler := api.NewHandler(mockCache, mockStorage)
+	router := setupTestRouter(handler)
+
+	t.Run("missing id is rejected before lookup", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/order?id=", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		var resp api.ErrorResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, api.CodeMissingOrderID, resp.Error.Code)
+	})
+
+	t.Run("accept json returns the order as json", func(t *testing.T) {
+		expectedOrder := models.Order{OrderUID: "test1", TrackNumber: "WBILMTESTTRACK"}
+
+		mockCache.EXPECT().
+			Get("test1").
+			Return(expectedOrder, true).
+			Times(1)
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/order?id=test1", nil)
+		req.Header.Set("Accept", "application/json")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+
+		var order models.Order
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &order))
+		assert.Equal(t, expectedOrder, order)
+	})
+
+	t.Run("browser accept header returns html", func(t *testing.T) {
+		mockCache.EXPECT().
+			Get("test1").
+			Return(models.Order{OrderUID: "test1"}, true).
+			Times(1)
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/order?id=test1", nil)
+		req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+	})
+}
+
+func TestHandler_GetOrderJSON(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockCache := mocks.NewMockCacheService(ctrl)
+	mockStorage := mocks.NewMockOrderStorage(ctrl)
+
+	handler := api.NewHandler(mockCache, mockStorage)
+	router := setupTestRouter(handler)
+
+	tests := []struct {
+		name       string
+		storageErr error
+		wantStatus int
+		wantCode   string
+	}{
+		{"found in database", nil, http.StatusOK, ""},
+		{"not found", database.ErrNotFound, http.StatusNotFound, api.CodeOrderNotFound},
+		{"timeout", context.DeadlineExceeded, http.StatusGatewayTimeout, api.CodeStorageTimeout},
+		{"database error", errors.New("connection refused"), http.StatusInternalServerError, api.CodeStorageError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectedOrder := models.Order{OrderUID: "uid1", Entry: "WBIL"}
+
+			mockCache.EXPECT().Get("uid1").Return(models.Order{}, false).Times(1)
+			if tt.storageErr != nil {
+				mockStorage.EXPECT().GetOrder(gomock.Any(), "uid1").Return(models.Order{}, tt.storageErr).Times(1)
+			} else {
+				mockStorage.EXPECT().GetOrder(gomock.Any(), "uid1").Return(expectedOrder, nil).Times(1)
+				mockCache.EXPECT().Set(expectedOrder).Times(1)
+			}
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/api/v1/orders/uid1", nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+
+			if tt.wantCode == "" {
+				var order models.Order
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &order))
+				assert.Equal(t, expectedOrder, order)
+				return
+			}
+
+			var resp api.ErrorResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Equal(t, tt.wantCode, resp.Error.Code)
+			assert.NotEmpty(t, resp.Error.Message)
+		})
+	}
 }
 
 func TestHandler_IndexPage(t *testing.T) {
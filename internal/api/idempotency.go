@@ -0,0 +1,113 @@
+package api
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	errIdempotencyInProgress = errors.New("request with this idempotency key is in progress")
+	errIdempotencyKeyReused  = errors.New("idempotency key was used for a different request")
+)
+
+// storedResponse is a response replayed for a repeated idempotency key.
+type storedResponse struct {
+	status int
+	body   []byte
+}
+
+// idempotencyStore remembers responses by idempotency key for ttl. Keys are
+// kept in memory, so they are only honored by the instance that saw them
+// first and are forgotten on restart.
+type idempotencyStore struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*idempotencyEntry
+	// expiry lists keys in the order they were reserved, which with a
+	// fixed ttl is also the order they expire in.
+	expiry []expiringKey
+}
+
+type idempotencyEntry struct {
+	fingerprint [32]byte
+	done        bool
+	response    storedResponse
+	expires     time.Time
+}
+
+type expiringKey struct {
+	key     string
+	expires time.Time
+}
+
+func newIdempotencyStore(ttl time.Duration) *idempotencyStore {
+	return &idempotencyStore{
+		ttl:     ttl,
+		entries: make(map[string]*idempotencyEntry),
+	}
+}
+
+// begin reserves key for a request with fingerprint. If the key already
+// has a response for the same request, it is returned with replay set.
+func (s *idempotencyStore) begin(key string, fingerprint [32]byte) (storedResponse, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.expire(now)
+
+	entry, ok := s.entries[key]
+	switch {
+	case !ok:
+		expires := now.Add(s.ttl)
+		s.entries[key] = &idempotencyEntry{fingerprint: fingerprint, expires: expires}
+		s.expiry = append(s.expiry, expiringKey{key: key, expires: expires})
+		return storedResponse{}, false, nil
+	case entry.fingerprint != fingerprint:
+		return storedResponse{}, false, errIdempotencyKeyReused
+	case !entry.done:
+		return storedResponse{}, false, errIdempotencyInProgress
+	default:
+		return entry.response, true, nil
+	}
+}
+
+// complete stores the response of the request that reserved key.
+func (s *idempotencyStore) complete(key string, response storedResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if ok {
+		entry.done = true
+		entry.response = response
+	}
+}
+
+// release drops the reservation of key, so the request can be retried.
+func (s *idempotencyStore) release(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if ok && !entry.done {
+		delete(s.entries, key)
+	}
+}
+
+// expire drops the entries that expired by now. Must be called with mu held.
+func (s *idempotencyStore) expire(now time.Time) {
+	n := 0
+	for n < len(s.expiry) && !s.expiry[n].expires.After(now) {
+		item := s.expiry[n]
+		// The key may have been released and reserved again since.
+		entry, ok := s.entries[item.key]
+		if ok && entry.expires.Equal(item.expires) {
+			delete(s.entries, item.key)
+		}
+		n++
+	}
+	s.expiry = s.expiry[n:]
+}
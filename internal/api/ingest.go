@@ -0,0 +1,293 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/config"
+	"github.com/ArtemKVD/WB-TechL0/internal/ingest"
+	"github.com/ArtemKVD/WB-TechL0/internal/logger"
+	database "github.com/ArtemKVD/WB-TechL0/internal/storage"
+	"github.com/ArtemKVD/WB-TechL0/pkg/models"
+	"github.com/ArtemKVD/WB-TechL0/pkg/validator"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// IdempotencyKeyHeader makes a POST /api/v1/orders request safe to
+	// retry: a repeated request with the same key and body gets the first
+	// response back instead of being processed again.
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set on responses replayed for a key.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	mimeNDJSON = "application/x-ndjson"
+)
+
+// Statuses of an IngestResult.
+const (
+	IngestAccepted = "accepted"
+	IngestInvalid  = "invalid"
+	IngestConflict = "conflict"
+	IngestFailed   = "failed"
+)
+
+// IngestResponse is returned by POST /api/v1/orders, with one result per
+// order in request order.
+type IngestResponse struct {
+	Accepted int            `json:"accepted"`
+	Rejected int            `json:"rejected"`
+	Results  []IngestResult `json:"results"`
+}
+
+// IngestResult is the outcome for one order. Index is the position of the
+// order in the request, not counting blank NDJSON lines. A rejected order
+// has either the violations found by validation or an Error. Warnings are
+// violations of rules that do not reject the order.
+type IngestResult struct {
+	Index      int                   `json:"index"`
+	OrderUID   string                `json:"order_uid,omitempty"`
+	Status     string                `json:"status"`
+	Error      string                `json:"error,omitempty"`
+	Violations []validator.Violation `json:"violations,omitempty"`
+	Warnings   []validator.Violation `json:"warnings,omitempty"`
+}
+
+type IngestHandler struct {
+	sink         ingest.Sink
+	maxBodyBytes int64
+	maxBatchSize int
+	idempotency  *idempotencyStore
+}
+
+func NewIngestHandler(sink ingest.Sink, cfg config.IngestConfig) *IngestHandler {
+	return &IngestHandler{
+		sink:         sink,
+		maxBodyBytes: cfg.MaxBodyBytes,
+		maxBatchSize: cfg.MaxBatchSize,
+		idempotency:  newIdempotencyStore(cfg.IdempotencyTTL),
+	}
+}
+
+// CreateOrders serves POST /api/v1/orders. The body is a single order as
+// application/json or a batch of orders, one per line, as
+// application/x-ndjson. Every order is validated like one read from the
+// broker, and the valid ones are handed to the sink together.
+//
+// A single order gets 202 when accepted, 422 when invalid and 409 on a
+// conflicting version. A batch gets 200 with the result of every order.
+// Both get 503 if no order could be handed to the sink.
+func (h *IngestHandler) CreateOrders(c *gin.Context) {
+	batch, ok := h.mediaType(c)
+	if !ok {
+		return
+	}
+	body, ok := h.readBody(c)
+	if !ok {
+		return
+	}
+
+	key := c.GetHeader(IdempotencyKeyHeader)
+	if key == "" {
+		status, resp := h.ingest(c.Request.Context(), body, batch)
+		c.JSON(status, resp)
+		return
+	}
+
+	fingerprint := sha256.Sum256(append([]byte(fmt.Sprintf("%t\n", batch)), body...))
+	stored, replay, err := h.idempotency.begin(key, fingerprint)
+	switch {
+	case errors.Is(err, errIdempotencyInProgress):
+		writeError(c, http.StatusConflict, CodeIdempotencyInProgress, "A request with this idempotency key is in progress")
+		return
+	case errors.Is(err, errIdempotencyKeyReused):
+		writeError(c, http.StatusUnprocessableEntity, CodeIdempotencyKeyReused, "Idempotency key was used for a different request")
+		return
+	case replay:
+		c.Header(IdempotentReplayedHeader, "true")
+		c.Data(stored.status, gin.MIMEJSON+"; charset=utf-8", stored.body)
+		return
+	}
+	// Failures on our side, panics included, are not remembered, so the
+	// client can retry them with the same key. Releasing a key whose
+	// response was stored does nothing.
+	defer h.idempotency.release(key)
+
+	status, resp := h.ingest(c.Request.Context(), body, batch)
+	encoded, err := json.Marshal(resp)
+	if err != nil {
+		logger.Log.Error("Encode ingest response error: ", err)
+		writeError(c, http.StatusInternalServerError, CodeStorageError, "Internal error")
+		return
+	}
+	if status < http.StatusInternalServerError {
+		h.idempotency.complete(key, storedResponse{status: status, body: encoded})
+	}
+	c.Data(status, gin.MIMEJSON+"; charset=utf-8", encoded)
+}
+
+// mediaType reports whether the request holds an NDJSON batch. On an
+// unsupported content type it writes the error response and returns false.
+func (h *IngestHandler) mediaType(c *gin.Context) (bool, bool) {
+	mediaType, _, err := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if err == nil {
+		switch mediaType {
+		case gin.MIMEJSON:
+			return false, true
+		case mimeNDJSON, "application/ndjson":
+			return true, true
+		}
+	}
+	writeError(c, http.StatusUnsupportedMediaType, CodeUnsupportedMediaType,
+		"Content-Type must be application/json or "+mimeNDJSON)
+	return false, false
+}
+
+func (h *IngestHandler) readBody(c *gin.Context) ([]byte, bool) {
+	reader := c.Request.Body
+	if h.maxBodyBytes > 0 {
+		reader = http.MaxBytesReader(c.Writer, reader, h.maxBodyBytes)
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(c, http.StatusRequestEntityTooLarge, CodeBodyTooLarge,
+				fmt.Sprintf("Request body exceeds %d bytes", tooLarge.Limit))
+			return nil, false
+		}
+		writeError(c, http.StatusBadRequest, CodeInvalidBody, "Cannot read request body")
+		return nil, false
+	}
+	return body, true
+}
+
+// ingest decodes, validates and hands over the orders in body, returning
+// the status and payload of the response.
+func (h *IngestHandler) ingest(ctx context.Context, body []byte, batch bool) (int, any) {
+	lines := [][]byte{body}
+	if batch {
+		lines = nonBlankLines(body)
+		switch {
+		case len(lines) == 0:
+			return errorResponse(http.StatusBadRequest, CodeEmptyBatch, "Batch has no orders")
+		case h.maxBatchSize > 0 && len(lines) > h.maxBatchSize:
+			return errorResponse(http.StatusRequestEntityTooLarge, CodeBatchTooLarge,
+				fmt.Sprintf("Batch has more than %d orders", h.maxBatchSize))
+		}
+	}
+
+	results := make([]IngestResult, len(lines))
+	var valid []models.Order
+	var validIdx []int
+	for i, line := range lines {
+		results[i] = IngestResult{Index: i}
+
+		var order models.Order
+		err := json.Unmarshal(line, &order)
+		if err != nil {
+			if !batch {
+				return errorResponse(http.StatusBadRequest, CodeInvalidBody, "Invalid order JSON: "+err.Error())
+			}
+			results[i].Status = IngestInvalid
+			results[i].Error = "invalid JSON: " + err.Error()
+			continue
+		}
+		results[i].OrderUID = order.OrderUID
+
+		results[i].Warnings, err = validator.CheckOrder(order)
+		if err != nil {
+			results[i].Status = IngestInvalid
+			violations, ok := validator.Violations(err)
+			if ok {
+				results[i].Violations = violations
+			} else {
+				results[i].Error = err.Error()
+			}
+			continue
+		}
+		valid = append(valid, order)
+		validIdx = append(validIdx, i)
+	}
+
+	sinkFailed := false
+	if len(valid) > 0 {
+		errs, err := h.sink.Accept(ctx, valid)
+		if err != nil {
+			logger.Log.WithField("orders", len(valid)).Error("Ingest orders error: ", err)
+			sinkFailed = true
+			errs = make([]error, len(valid))
+			for i := range errs {
+				errs[i] = err
+			}
+		}
+		for j, i := range validIdx {
+			results[i].Status, results[i].Error = sinkResult(results[i].OrderUID, errs[j])
+		}
+	}
+
+	resp := IngestResponse{Results: results}
+	for _, result := range results {
+		if result.Status == IngestAccepted {
+			resp.Accepted++
+		} else {
+			resp.Rejected++
+		}
+	}
+	logger.Log.WithFields(logrus.Fields{
+		"accepted": resp.Accepted,
+		"rejected": resp.Rejected,
+	}).Info("Orders ingested over HTTP")
+
+	switch {
+	case sinkFailed && resp.Accepted == 0:
+		return http.StatusServiceUnavailable, resp
+	case batch:
+		return http.StatusOK, resp
+	}
+	switch results[0].Status {
+	case IngestAccepted:
+		return http.StatusAccepted, resp
+	case IngestInvalid:
+		return http.StatusUnprocessableEntity, resp
+	case IngestConflict:
+		return http.StatusConflict, resp
+	default:
+		return http.StatusServiceUnavailable, resp
+	}
+}
+
+func sinkResult(orderUID string, err error) (string, string) {
+	switch {
+	case err == nil:
+		return IngestAccepted, ""
+	case errors.Is(err, database.ErrOrderConflict):
+		return IngestConflict, "order already exists with different content"
+	default:
+		logger.Log.WithField("order_uid", orderUID).Error("Ingest order error: ", err)
+		return IngestFailed, "order could not be processed, retry later"
+	}
+}
+
+func nonBlankLines(body []byte) [][]byte {
+	var lines [][]byte
+	for _, line := range bytes.Split(body, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func errorResponse(status int, code, message string) (int, any) {
+	return status, ErrorResponse{Error: ErrorBody{Code: code, Message: message}}
+}
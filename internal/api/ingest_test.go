@@ -0,0 +1,439 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/api"
+	"github.com/ArtemKVD/WB-TechL0/internal/cache"
+	"github.com/ArtemKVD/WB-TechL0/internal/config"
+	"github.com/ArtemKVD/WB-TechL0/internal/ingest"
+	database "github.com/ArtemKVD/WB-TechL0/internal/storage"
+	"github.com/ArtemKVD/WB-TechL0/internal/storage/storagetest"
+	"github.com/ArtemKVD/WB-TechL0/internal/transport"
+	"github.com/ArtemKVD/WB-TechL0/pkg/models"
+	"github.com/ArtemKVD/WB-TechL0/pkg/validator"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const ndjsonType = "application/x-ndjson"
+
+var ingestConfig = config.IngestConfig{
+	Mode:           ingest.Store,
+	MaxBodyBytes:   1 << 20,
+	MaxBatchSize:   3,
+	IdempotencyTTL: time.Minute,
+}
+
+type storeFixture struct {
+	router  *gin.Engine
+	storage *database.MemoryStorage
+	cache   *cache.Cache
+}
+
+func newStoreFixture(t *testing.T, cfg config.IngestConfig) storeFixture {
+	t.Helper()
+	storage := database.NewMemoryStorage(config.DatabaseConfig{ConflictPolicy: database.ConflictReject})
+	c := cache.NewCache(config.CacheConfig{MaxSize: 100, TTL: time.Minute, Policy: cache.PolicyLRU})
+	return storeFixture{
+		router:  newIngestRouter(ingest.NewStoreSink(storage, c), cfg),
+		storage: storage,
+		cache:   c,
+	}
+}
+
+func newIngestRouter(sink ingest.Sink, cfg config.IngestConfig) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/orders", api.NewIngestHandler(sink, cfg).CreateOrders)
+	return router
+}
+
+func postOrders(router *gin.Engine, contentType, idempotencyKey string, body []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/orders", bytes.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	if idempotencyKey != "" {
+		req.Header.Set(api.IdempotencyKeyHeader, idempotencyKey)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func encodeJSON(t *testing.T, value any) []byte {
+	t.Helper()
+	body, err := json.Marshal(value)
+	require.NoError(t, err)
+	return body
+}
+
+func ndjson(t *testing.T, lines ...any) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	for _, line := range lines {
+		if raw, ok := line.(string); ok {
+			buf.WriteString(raw)
+		} else {
+			buf.Write(encodeJSON(t, line))
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+func decodeIngest(t *testing.T, w *httptest.ResponseRecorder) api.IngestResponse {
+	t.Helper()
+	var resp api.IngestResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp
+}
+
+func decodeError(t *testing.T, w *httptest.ResponseRecorder) api.ErrorBody {
+	t.Helper()
+	var resp api.ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp.Error
+}
+
+func TestIngest_SingleOrder(t *testing.T) {
+	t.Run("valid order is saved and cached", func(t *testing.T) {
+		f := newStoreFixture(t, ingestConfig)
+		order := storagetest.Order("http-1", 0)
+
+		w := postOrders(f.router, "application/json; charset=utf-8", "", encodeJSON(t, order))
+
+		require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+		resp := decodeIngest(t, w)
+		assert.Equal(t, 1, resp.Accepted)
+		assert.Equal(t, []api.IngestResult{{Index: 0, OrderUID: "http-1", Status: api.IngestAccepted}}, resp.Results)
+
+		_, err := f.storage.GetOrder(context.Background(), "http-1")
+		assert.NoError(t, err)
+		_, found := f.cache.Get("http-1")
+		assert.True(t, found)
+	})
+
+	t.Run("invalid order gets field errors", func(t *testing.T) {
+		f := newStoreFixture(t, ingestConfig)
+		order := storagetest.Order("http-2", 0)
+		order.Delivery.Phone = ""
+		order.SMID = 0
+
+		w := postOrders(f.router, "application/json", "", encodeJSON(t, order))
+
+		require.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+		resp := decodeIngest(t, w)
+		assert.Equal(t, 1, resp.Rejected)
+		require.Len(t, resp.Results, 1)
+		result := resp.Results[0]
+		assert.Equal(t, api.IngestInvalid, result.Status)
+		assert.Equal(t, "http-2", result.OrderUID)
+
+		assert.Equal(t, []validator.Violation{
+			{Path: "delivery.phone", Rule: "required", Value: "", Message: "is required"},
+			{Path: "sm_id", Rule: "required", Value: float64(0), Message: "is required"},
+		}, result.Violations)
+
+		_, err := f.storage.GetOrder(context.Background(), "http-2")
+		assert.ErrorIs(t, err, database.ErrNotFound)
+	})
+
+	t.Run("malformed date gets a field error", func(t *testing.T) {
+		f := newStoreFixture(t, ingestConfig)
+		var order map[string]any
+		require.NoError(t, json.Unmarshal(encodeJSON(t, storagetest.Order("http-6", 0)), &order))
+		order["date_created"] = "26.11.2021"
+
+		w := postOrders(f.router, "application/json", "", encodeJSON(t, order))
+
+		require.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+		assert.Equal(t, []validator.Violation{
+			{Path: "date_created", Rule: "timestamp", Value: "26.11.2021", Message: "must be an RFC 3339 timestamp"},
+		}, decodeIngest(t, w).Results[0].Violations)
+	})
+
+	t.Run("business rule warnings are returned", func(t *testing.T) {
+		f := newStoreFixture(t, ingestConfig)
+		order := storagetest.Order("http-5", 0)
+		order.Items[0].TotalPrice = 90
+		order.Payment.GoodsTotal = 407
+		order.Payment.Amount = 1907
+
+		w := postOrders(f.router, "application/json", "", encodeJSON(t, order))
+
+		require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+		result := decodeIngest(t, w).Results[0]
+		assert.Equal(t, api.IngestAccepted, result.Status)
+		assert.Equal(t, []validator.Violation{{
+			Path:    "items[0].total_price",
+			Rule:    validator.RuleItemTotalPrice,
+			Value:   float64(90),
+			Message: "must be price 100 less 0% sale",
+		}}, result.Warnings)
+	})
+
+	t.Run("conflicting version", func(t *testing.T) {
+		f := newStoreFixture(t, ingestConfig)
+		order := storagetest.Order("http-3", 0)
+		require.Equal(t, http.StatusAccepted, postOrders(f.router, "application/json", "", encodeJSON(t, order)).Code)
+
+		order.CustomerID = "someone-else"
+		w := postOrders(f.router, "application/json", "", encodeJSON(t, order))
+
+		require.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+		assert.Equal(t, api.IngestConflict, decodeIngest(t, w).Results[0].Status)
+	})
+
+	t.Run("malformed body", func(t *testing.T) {
+		f := newStoreFixture(t, ingestConfig)
+
+		w := postOrders(f.router, "application/json", "", []byte(`{"order_uid": `))
+
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, api.CodeInvalidBody, decodeError(t, w).Code)
+	})
+
+	t.Run("unsupported content type", func(t *testing.T) {
+		f := newStoreFixture(t, ingestConfig)
+
+		w := postOrders(f.router, "text/plain", "", encodeJSON(t, storagetest.Order("http-4", 0)))
+
+		require.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+		assert.Equal(t, api.CodeUnsupportedMediaType, decodeError(t, w).Code)
+	})
+}
+
+func TestIngest_Batch(t *testing.T) {
+	f := newStoreFixture(t, ingestConfig)
+	invalid := storagetest.Order("batch-2", 1)
+	invalid.Payment.Currency = ""
+	body := ndjson(t,
+		storagetest.Order("batch-1", 0),
+		"",
+		`{"order_uid": "broken"`,
+		invalid,
+	)
+
+	w := postOrders(f.router, ndjsonType, "", body)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	resp := decodeIngest(t, w)
+	assert.Equal(t, 1, resp.Accepted)
+	assert.Equal(t, 2, resp.Rejected)
+	require.Len(t, resp.Results, 3)
+
+	assert.Equal(t, api.IngestResult{Index: 0, OrderUID: "batch-1", Status: api.IngestAccepted}, resp.Results[0])
+
+	assert.Equal(t, 1, resp.Results[1].Index)
+	assert.Equal(t, api.IngestInvalid, resp.Results[1].Status)
+	assert.Contains(t, resp.Results[1].Error, "invalid JSON")
+
+	assert.Equal(t, 2, resp.Results[2].Index)
+	assert.Equal(t, api.IngestInvalid, resp.Results[2].Status)
+	assert.Equal(t, []validator.Violation{
+		{Path: "payment.currency", Rule: "required", Value: "", Message: "is required"},
+	}, resp.Results[2].Violations)
+
+	_, err := f.storage.GetOrder(context.Background(), "batch-1")
+	assert.NoError(t, err)
+}
+
+func TestIngest_BatchLimits(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    config.IngestConfig
+		body   []byte
+		status int
+		code   string
+	}{
+		{
+			name:   "empty batch",
+			cfg:    ingestConfig,
+			body:   []byte("\n  \n"),
+			status: http.StatusBadRequest,
+			code:   api.CodeEmptyBatch,
+		},
+		{
+			name: "too many orders",
+			cfg:  ingestConfig,
+			body: ndjson(t,
+				storagetest.Order("limit-1", 0),
+				storagetest.Order("limit-2", 0),
+				storagetest.Order("limit-3", 0),
+				storagetest.Order("limit-4", 0),
+			),
+			status: http.StatusRequestEntityTooLarge,
+			code:   api.CodeBatchTooLarge,
+		},
+		{
+			name: "body too large",
+			cfg: config.IngestConfig{
+				MaxBodyBytes:   64,
+				MaxBatchSize:   ingestConfig.MaxBatchSize,
+				IdempotencyTTL: time.Minute,
+			},
+			body:   ndjson(t, storagetest.Order("limit-5", 0)),
+			status: http.StatusRequestEntityTooLarge,
+			code:   api.CodeBodyTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newStoreFixture(t, tt.cfg)
+
+			w := postOrders(f.router, ndjsonType, "", tt.body)
+
+			require.Equal(t, tt.status, w.Code, w.Body.String())
+			assert.Equal(t, tt.code, decodeError(t, w).Code)
+		})
+	}
+}
+
+// flakySink panics on the first panics calls, fails the next failures
+// calls and then accepts every order.
+type flakySink struct {
+	panics   int32
+	failures int32
+	calls    atomic.Int32
+	accepted atomic.Int32
+}
+
+func (s *flakySink) Accept(ctx context.Context, orders []models.Order) ([]error, error) {
+	call := s.calls.Add(1)
+	if call <= s.panics {
+		panic("sink panicked")
+	}
+	if call <= s.panics+s.failures {
+		return nil, errors.New("storage is down")
+	}
+	s.accepted.Add(int32(len(orders)))
+	return make([]error, len(orders)), nil
+}
+
+func TestIngest_Idempotency(t *testing.T) {
+	body := ndjson(t, storagetest.Order("idem-1", 0), storagetest.Order("idem-2", 1))
+
+	t.Run("repeated request is replayed", func(t *testing.T) {
+		sink := &flakySink{}
+		router := newIngestRouter(sink, ingestConfig)
+
+		first := postOrders(router, ndjsonType, "key-1", body)
+		require.Equal(t, http.StatusOK, first.Code, first.Body.String())
+		assert.Empty(t, first.Header().Get(api.IdempotentReplayedHeader))
+
+		second := postOrders(router, ndjsonType, "key-1", body)
+		require.Equal(t, http.StatusOK, second.Code)
+		assert.Equal(t, "true", second.Header().Get(api.IdempotentReplayedHeader))
+		assert.JSONEq(t, first.Body.String(), second.Body.String())
+
+		assert.Equal(t, int32(1), sink.calls.Load())
+		assert.Equal(t, int32(2), sink.accepted.Load())
+	})
+
+	t.Run("key reused for another request", func(t *testing.T) {
+		router := newIngestRouter(&flakySink{}, ingestConfig)
+		require.Equal(t, http.StatusOK, postOrders(router, ndjsonType, "key-2", body).Code)
+
+		w := postOrders(router, ndjsonType, "key-2", ndjson(t, storagetest.Order("idem-3", 0)))
+
+		require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Equal(t, api.CodeIdempotencyKeyReused, decodeError(t, w).Code)
+	})
+
+	t.Run("failed request can be retried", func(t *testing.T) {
+		sink := &flakySink{failures: 1}
+		router := newIngestRouter(sink, ingestConfig)
+
+		first := postOrders(router, ndjsonType, "key-3", body)
+		require.Equal(t, http.StatusServiceUnavailable, first.Code, first.Body.String())
+		resp := decodeIngest(t, first)
+		assert.Equal(t, 2, resp.Rejected)
+		assert.Equal(t, api.IngestFailed, resp.Results[0].Status)
+
+		second := postOrders(router, ndjsonType, "key-3", body)
+		require.Equal(t, http.StatusOK, second.Code, second.Body.String())
+		assert.Empty(t, second.Header().Get(api.IdempotentReplayedHeader))
+		assert.Equal(t, 2, decodeIngest(t, second).Accepted)
+		assert.Equal(t, int32(2), sink.calls.Load())
+	})
+
+	t.Run("key is released when the request panics", func(t *testing.T) {
+		sink := &flakySink{panics: 1}
+		router := newIngestRouter(sink, ingestConfig)
+
+		assert.Panics(t, func() { postOrders(router, ndjsonType, "key-5", body) })
+
+		w := postOrders(router, ndjsonType, "key-5", body)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, 2, decodeIngest(t, w).Accepted)
+	})
+
+	t.Run("keys expire", func(t *testing.T) {
+		sink := &flakySink{}
+		cfg := ingestConfig
+		cfg.IdempotencyTTL = 10 * time.Millisecond
+		router := newIngestRouter(sink, cfg)
+
+		require.Equal(t, http.StatusOK, postOrders(router, ndjsonType, "key-4", body).Code)
+		time.Sleep(20 * time.Millisecond)
+		w := postOrders(router, ndjsonType, "key-4", body)
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get(api.IdempotentReplayedHeader))
+		assert.Equal(t, int32(2), sink.calls.Load())
+	})
+}
+
+func TestIngest_PublishMode(t *testing.T) {
+	broker := transport.NewMemoryBroker(2)
+	subscriber := broker.Subscriber("orders", "test")
+	defer subscriber.Close()
+
+	cfg := &config.Config{
+		Kafka:  config.KafkaConfig{Topic: "orders"},
+		Ingest: ingestConfig,
+	}
+	cfg.Ingest.Mode = ingest.Publish
+	sink, err := ingest.New(cfg, nil, nil, broker)
+	require.NoError(t, err)
+	defer sink.(*ingest.PublishSink).Close()
+	router := newIngestRouter(sink, cfg.Ingest)
+
+	invalid := storagetest.Order("pub-2", 0)
+	invalid.Items = nil
+	w := postOrders(router, ndjsonType, "", ndjson(t, storagetest.Order("pub-1", 0), invalid))
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	resp := decodeIngest(t, w)
+	assert.Equal(t, 1, resp.Accepted)
+	assert.Equal(t, api.IngestInvalid, resp.Results[1].Status)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	message, err := subscriber.Fetch(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "pub-1", string(message.Key))
+	var order models.Order
+	require.NoError(t, json.Unmarshal(message.Value, &order))
+	assert.Equal(t, storagetest.Order("pub-1", 0), order)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = subscriber.Fetch(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestIngest_UnknownMode(t *testing.T) {
+	_, err := ingest.New(&config.Config{Ingest: config.IngestConfig{Mode: "email"}}, nil, nil, nil)
+	assert.ErrorContains(t, err, `unknown ingest mode "email"`)
+}
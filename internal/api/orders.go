@@ -0,0 +1,177 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/logger"
+	database "github.com/ArtemKVD/WB-TechL0/internal/storage"
+	"github.com/ArtemKVD/WB-TechL0/pkg/models"
+	"github.com/gin-gonic/gin"
+)
+
+// OrderListResponse is returned by GET /api/v1/orders. NextCursor is empty
+// on the last page.
+type OrderListResponse struct {
+	Orders     []models.Order `json:"orders"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+// ListOrders serves /orders, rendering orders.html unless the client asks
+// for JSON through the Accept header.
+func (h *Handler) ListOrders(c *gin.Context) {
+	resp, ok := h.listOrders(c)
+	if !ok {
+		return
+	}
+
+	switch c.NegotiateFormat(gin.MIMEHTML, gin.MIMEJSON) {
+	case gin.MIMEJSON:
+		c.JSON(http.StatusOK, resp)
+	default:
+		var nextURL string
+		if resp.NextCursor != "" {
+			query := c.Request.URL.Query()
+			query.Set("cursor", resp.NextCursor)
+			nextURL = "/orders?" + query.Encode()
+		}
+		c.HTML(http.StatusOK, "orders.html", gin.H{
+			"Orders":  resp.Orders,
+			"NextURL": nextURL,
+		})
+	}
+}
+
+// ListOrdersJSON serves GET /api/v1/orders.
+func (h *Handler) ListOrdersJSON(c *gin.Context) {
+	resp, ok := h.listOrders(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) listOrders(c *gin.Context) (OrderListResponse, bool) {
+	filter, err := parseOrderFilter(c)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, CodeInvalidArgument, err.Error())
+		return OrderListResponse{}, false
+	}
+
+	page, err := h.storage.ListOrders(c.Request.Context(), filter)
+	if err != nil {
+		switch {
+		case errors.Is(err, context.Canceled):
+			c.AbortWithStatus(statusClientClosedRequest)
+		case errors.Is(err, context.DeadlineExceeded):
+			writeError(c, http.StatusGatewayTimeout, CodeStorageTimeout, "Database timeout")
+		default:
+			logger.Log.Error("List orders error: ", err)
+			writeError(c, http.StatusInternalServerError, CodeStorageError, "Database error")
+		}
+		return OrderListResponse{}, false
+	}
+
+	resp := OrderListResponse{Orders: page.Orders}
+	if page.Next != nil {
+		resp.NextCursor = encodeCursor(*page.Next)
+	}
+	return resp, true
+}
+
+func parseOrderFilter(c *gin.Context) (database.OrderFilter, error) {
+	filter := database.OrderFilter{
+		CustomerID:      strings.TrimSpace(c.Query("customer_id")),
+		TrackNumber:     strings.TrimSpace(c.Query("track_number")),
+		DeliveryService: strings.TrimSpace(c.Query("delivery_service")),
+		Currency:        strings.TrimSpace(c.Query("currency")),
+		Provider:        strings.TrimSpace(c.Query("provider")),
+		Brand:           strings.TrimSpace(c.Query("brand")),
+	}
+
+	var err error
+	if value := c.Query("nm_id"); value != "" {
+		filter.NmID, err = strconv.Atoi(value)
+		if err != nil {
+			return filter, fmt.Errorf("nm_id must be an integer")
+		}
+	}
+
+	if value := c.Query("limit"); value != "" {
+		filter.Limit, err = strconv.Atoi(value)
+		if err != nil || filter.Limit < 1 || filter.Limit > database.MaxListLimit {
+			return filter, fmt.Errorf("limit must be between 1 and %d", database.MaxListLimit)
+		}
+	}
+
+	if value := c.Query("created_from"); value != "" {
+		filter.CreatedFrom, err = parseFilterTime(value, false)
+		if err != nil {
+			return filter, fmt.Errorf("created_from must be an RFC3339 timestamp or a YYYY-MM-DD date")
+		}
+	}
+
+	if value := c.Query("created_to"); value != "" {
+		filter.CreatedTo, err = parseFilterTime(value, true)
+		if err != nil {
+			return filter, fmt.Errorf("created_to must be an RFC3339 timestamp or a YYYY-MM-DD date")
+		}
+	}
+
+	if value := c.Query("cursor"); value != "" {
+		cursor, err := decodeCursor(value)
+		if err != nil {
+			return filter, fmt.Errorf("cursor is malformed")
+		}
+		filter.After = &cursor
+	}
+
+	return filter, nil
+}
+
+// parseFilterTime accepts RFC3339 timestamps and plain dates. A plain date
+// used as an upper bound covers the whole day.
+func parseFilterTime(value string, endOfDay bool) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err == nil {
+		return t, nil
+	}
+
+	t, err = time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Microsecond)
+	}
+	return t, nil
+}
+
+func encodeCursor(cursor database.Cursor) string {
+	raw := cursor.DateCreated.UTC().Format(time.RFC3339Nano) + "|" + cursor.OrderUID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(value string) (database.Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return database.Cursor{}, err
+	}
+
+	dateCreated, orderUID, found := strings.Cut(string(raw), "|")
+	if !found || orderUID == "" {
+		return database.Cursor{}, errors.New("cursor must contain a timestamp and an order id")
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, dateCreated)
+	if err != nil {
+		return database.Cursor{}, err
+	}
+	return database.Cursor{DateCreated: t, OrderUID: orderUID}, nil
+}
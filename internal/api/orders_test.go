@@ -0,0 +1,156 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/api"
+	"github.com/ArtemKVD/WB-TechL0/internal/mocks"
+	database "github.com/ArtemKVD/WB-TechL0/internal/storage"
+	"github.com/ArtemKVD/WB-TechL0/pkg/models"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupListRouter(handler *api.Handler) *gin.Engine {
+	router := setupTestRouter(handler)
+	router.GET("/orders", handler.ListOrders)
+	router.GET("/api/v1/orders", handler.ListOrdersJSON)
+	return router
+}
+
+func TestHandler_ListOrdersJSON(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockCache := mocks.NewMockCacheService(ctrl)
+	mockStorage := mocks.NewMockOrderStorage(ctrl)
+
+	handler := api.NewHandler(mockCache, mockStorage)
+	router := setupListRouter(handler)
+
+	next := database.Cursor{
+		DateCreated: time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		OrderUID:    "order2",
+	}
+
+	t.Run("filters are passed to storage and the next cursor round-trips", func(t *testing.T) {
+		mockStorage.EXPECT().
+			ListOrders(gomock.Any(), database.OrderFilter{
+				CustomerID:      "customer",
+				TrackNumber:     "WBILMTESTTRACK",
+				DeliveryService: "meest",
+				CreatedFrom:     time.Date(2021, 11, 1, 0, 0, 0, 0, time.UTC),
+				CreatedTo:       time.Date(2021, 11, 30, 23, 59, 59, 999999000, time.UTC),
+				Currency:        "USD",
+				Provider:        "wbpay",
+				Brand:           "Vivienne Sabo",
+				NmID:            2389212,
+				Limit:           2,
+			}).
+			Return(database.OrderPage{
+				Orders: []models.Order{{OrderUID: "order1"}, {OrderUID: "order2"}},
+				Next:   &next,
+			}, nil).
+			Times(1)
+
+		query := url.Values{
+			"customer_id":      {"customer"},
+			"track_number":     {"WBILMTESTTRACK"},
+			"delivery_service": {"meest"},
+			"created_from":     {"2021-11-01"},
+			"created_to":       {"2021-11-30"},
+			"currency":         {"USD"},
+			"provider":         {"wbpay"},
+			"brand":            {"Vivienne Sabo"},
+			"nm_id":            {"2389212"},
+			"limit":            {"2"},
+		}
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/api/v1/orders?"+query.Encode(), nil)
+		router.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var resp api.OrderListResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Len(t, resp.Orders, 2)
+		require.NotEmpty(t, resp.NextCursor)
+
+		mockStorage.EXPECT().
+			ListOrders(gomock.Any(), database.OrderFilter{After: &next}).
+			Return(database.OrderPage{Orders: []models.Order{{OrderUID: "order3"}}}, nil).
+			Times(1)
+
+		w = httptest.NewRecorder()
+		req = httptest.NewRequest("GET", "/api/v1/orders?cursor="+resp.NextCursor, nil)
+		router.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+
+		resp = api.OrderListResponse{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, []models.Order{{OrderUID: "order3"}}, resp.Orders)
+		assert.Empty(t, resp.NextCursor)
+	})
+
+	invalid := []struct {
+		name  string
+		query string
+	}{
+		{"non-numeric nm_id", "nm_id=abc"},
+		{"limit too large", "limit=1000"},
+		{"zero limit", "limit=0"},
+		{"bad date", "created_from=yesterday"},
+		{"bad cursor", "cursor=not-a-cursor"},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/api/v1/orders?"+tt.query, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+
+			var resp api.ErrorResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Equal(t, api.CodeInvalidArgument, resp.Error.Code)
+		})
+	}
+}
+
+func TestHandler_ListOrdersHTML(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockCache := mocks.NewMockCacheService(ctrl)
+	mockStorage := mocks.NewMockOrderStorage(ctrl)
+
+	handler := api.NewHandler(mockCache, mockStorage)
+	router := setupListRouter(handler)
+
+	mockStorage.EXPECT().
+		ListOrders(gomock.Any(), database.OrderFilter{Brand: "Vivienne Sabo"}).
+		Return(database.OrderPage{
+			Orders: []models.Order{{OrderUID: "order1"}},
+			Next:   &database.Cursor{DateCreated: time.Now(), OrderUID: "order1"},
+		}, nil).
+		Times(1)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/orders?brand=Vivienne+Sabo", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, w.Body.String(), "/order?id=order1")
+	assert.Contains(t, w.Body.String(), "cursor=")
+	assert.Contains(t, w.Body.String(), "brand=Vivienne&#43;Sabo")
+}
@@ -0,0 +1,125 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/config"
+	"github.com/ArtemKVD/WB-TechL0/pkg/models"
+)
+
+// legacyCache is the previous sync.Map based implementation, kept only as a
+// baseline for the benchmarks below.
+type legacyCache struct {
+	orders      sync.Map
+	accessTimes sync.Map
+	maxSize     int
+	currentSize int
+	ttl         time.Duration
+}
+
+func (c *legacyCache) Set(order models.Order) {
+	orderUID := order.OrderUID
+	_, exists := c.orders.Load(orderUID)
+	if exists {
+		c.accessTimes.Store(orderUID, time.Now())
+		return
+	}
+	c.Clean()
+	if c.currentSize >= c.maxSize {
+		c.DeleteOldest()
+	}
+	c.orders.Store(orderUID, order)
+	c.accessTimes.Store(orderUID, time.Now())
+	c.currentSize++
+}
+
+func (c *legacyCache) DeleteOldest() {
+	var oldestKey string
+	var oldestTime time.Time
+	c.accessTimes.Range(func(key, value interface{}) bool {
+		accessTime := value.(time.Time)
+		if oldestKey == "" || accessTime.Before(oldestTime) {
+			oldestKey = key.(string)
+			oldestTime = accessTime
+		}
+		return true
+	})
+	if oldestKey != "" {
+		c.orders.Delete(oldestKey)
+		c.accessTimes.Delete(oldestKey)
+		// The original code incremented here; decrement so the baseline
+		// actually evicts instead of scanning on every call.
+		c.currentSize--
+	}
+}
+
+func (c *legacyCache) Get(orderUID string) (models.Order, bool) {
+	order, exists := c.orders.Load(orderUID)
+	if !exists {
+		return models.Order{}, false
+	}
+	c.accessTimes.Store(orderUID, time.Now())
+	return order.(models.Order), true
+}
+
+func (c *legacyCache) Clean() {
+	now := time.Now()
+	c.accessTimes.Range(func(key, value interface{}) bool {
+		if now.Sub(value.(time.Time)) > c.ttl {
+			c.orders.Delete(key)
+			c.accessTimes.Delete(key)
+			c.currentSize--
+		}
+		return true
+	})
+}
+
+type benchCache interface {
+	Set(order models.Order)
+	Get(orderUID string) (models.Order, bool)
+}
+
+func benchmarkImplementations(b *testing.B, run func(b *testing.B, c benchCache, size int)) {
+	for _, size := range []int{100, 1000, 10000} {
+		for _, policy := range []string{PolicyLRU, PolicyLFU, PolicyFIFO} {
+			b.Run(fmt.Sprintf("%s/size=%d", policy, size), func(b *testing.B) {
+				run(b, NewCache(config.CacheConfig{MaxSize: size, TTL: time.Hour, Policy: policy}), size)
+			})
+		}
+		b.Run(fmt.Sprintf("legacy/size=%d", size), func(b *testing.B) {
+			run(b, &legacyCache{maxSize: size, ttl: time.Hour}, size)
+		})
+	}
+}
+
+func BenchmarkCache_SetWithEviction(b *testing.B) {
+	benchmarkImplementations(b, func(b *testing.B, c benchCache, size int) {
+		orders := make([]models.Order, 2*size)
+		for i := range orders {
+			orders[i] = models.Order{OrderUID: fmt.Sprintf("order%d", i)}
+		}
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			c.Set(orders[i%len(orders)])
+		}
+	})
+}
+
+func BenchmarkCache_Get(b *testing.B) {
+	benchmarkImplementations(b, func(b *testing.B, c benchCache, size int) {
+		orderUIDs := make([]string, size)
+		for i := range orderUIDs {
+			orderUIDs[i] = fmt.Sprintf("order%d", i)
+			c.Set(models.Order{OrderUID: orderUIDs[i]})
+		}
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			c.Get(orderUIDs[i%size])
+		}
+	})
+}
@@ -1,121 +1,285 @@
 package cache
 
 import (
+	"container/list"
+	"context"
 	"sync"
 	"time"
 
+	"github.com/ArtemKVD/WB-TechL0/internal/config"
 	"github.com/ArtemKVD/WB-TechL0/internal/logger"
 	database "github.com/ArtemKVD/WB-TechL0/internal/storage"
 	"github.com/ArtemKVD/WB-TechL0/pkg/models"
+	"github.com/sirupsen/logrus"
 )
 
+const (
+	defaultMaxSize = 1000
+	defaultTTL     = 15 * time.Minute
+)
+
+// Cache is a size-bounded cache of orders. Which entry is evicted when it
+// is full is decided by the configured policy; entries not accessed within
+// ttl are treated as missing and removed by a background janitor.
 type Cache struct {
-	orders      sync.Map
-	accessTimes sync.Map
-	maxSize     int
-	currentSize int
-	ttl         time.Duration
+	mu      sync.Mutex
+	entries map[string]*entry
+	policy  evictionPolicy
+	maxSize int
+	ttl     time.Duration
+	stats   Stats
+
+	cleanupInterval time.Duration
+	janitorOnce     sync.Once
+	stopOnce        sync.Once
+	stop            chan struct{}
+	done            chan struct{}
+}
+
+// Stats are cumulative counters of cache activity since creation. Size is
+// the number of entries at the time of the snapshot.
+type Stats struct {
+	Hits        uint64
+	Misses      uint64
+	Evictions   uint64
+	Expirations uint64
+	Size        int
+}
+
+type entry struct {
+	order      models.Order
+	accessedAt time.Time
+
+	// Bookkeeping owned by the eviction policy.
+	element *list.Element
+	freq    int
 }
 
 //go:generate mockgen -destination=../mocks/cache_mock.go -package=mocks github.com/ArtemKVD/WB-TechL0/internal/cache CacheService
 type CacheService interface {
 	Set(order models.Order)
 	Get(orderUID string) (models.Order, bool)
-	LoadCacheFromDB(storage database.OrderStorage) error
+	LoadCacheFromDB(ctx context.Context, storage database.OrderStorage) error
 	DeleteOldest()
 	Clean()
 }
 
-func NewCache() *Cache {
-	logger.Log.Info("cache initialized")
+func NewCache(cfg config.CacheConfig) *Cache {
+	policy, ok := newPolicy(cfg.Policy)
+	if !ok {
+		logger.Log.WithField("policy", cfg.Policy).Error("Unknown cache eviction policy, using lru")
+		policy, _ = newPolicy(PolicyLRU)
+	}
+
+	maxSize := cfg.MaxSize
+	if maxSize <= 0 {
+		maxSize = defaultMaxSize
+	}
+	ttl := cfg.TTL
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+
+	logger.Log.WithFields(logrus.Fields{
+		"max_size": maxSize,
+		"ttl":      ttl.String(),
+		"policy":   cfg.Policy,
+	}).Info("cache initialized")
+
 	return &Cache{
-		maxSize: 1000,
-		ttl:     15 * time.Minute,
+		entries:         make(map[string]*entry),
+		policy:          policy,
+		maxSize:         maxSize,
+		ttl:             ttl,
+		cleanupInterval: cfg.CleanupInterval,
+		stop:            make(chan struct{}),
+		done:            make(chan struct{}),
 	}
 }
 
 func (c *Cache) Set(order models.Order) {
-	orderUID := order.OrderUID
+	c.mu.Lock()
+	updated := c.setLocked(order, time.Now())
+	c.mu.Unlock()
 
-	_, exists := c.orders.Load(orderUID)
-	if exists {
-		c.accessTimes.Store(orderUID, time.Now())
-		logger.Log.WithField("order_uid", orderUID).Info("order updated in cache")
+	if updated {
+		logger.Log.WithField("order_uid", order.OrderUID).Info("order updated in cache")
 		return
 	}
-	c.Clean()
-	if c.currentSize >= c.maxSize {
-		c.DeleteOldest()
+	logger.Log.WithField("order_uid", order.OrderUID).Info("Order cached")
+}
+
+// setLocked stores the order and reports whether it replaced an existing
+// entry.
+func (c *Cache) setLocked(order models.Order, now time.Time) bool {
+	e, exists := c.entries[order.OrderUID]
+	if exists {
+		e.order = order
+		e.accessedAt = now
+		c.policy.touch(e)
+		return true
 	}
 
-	c.orders.Store(orderUID, order)
-	c.accessTimes.Store(orderUID, time.Now())
-	c.currentSize++
+	for len(c.entries) >= c.maxSize {
+		c.deleteOldestLocked()
+	}
 
-	logger.Log.WithField("order_uid", orderUID).Info("Order cached")
+	e = &entry{order: order, accessedAt: now}
+	c.policy.add(e)
+	c.entries[order.OrderUID] = e
+	return false
 }
 
+// DeleteOldest evicts the entry chosen by the eviction policy.
 func (c *Cache) DeleteOldest() {
-	var oldestKey string
-	var oldestTime time.Time
-
-	c.accessTimes.Range(func(key, value interface{}) bool {
-		accessTime := value.(time.Time)
-		if oldestKey == "" || accessTime.Before(oldestTime) {
-			oldestKey = key.(string)
-			oldestTime = accessTime
-		}
-		return true
-	})
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deleteOldestLocked()
+}
 
-	if oldestKey != "" {
-		c.orders.Delete(oldestKey)
-		c.accessTimes.Delete(oldestKey)
-		c.currentSize++
-		logger.Log.WithField("order_uid", oldestKey).Info("Oldest order deleted")
+func (c *Cache) deleteOldestLocked() {
+	e := c.policy.victim()
+	if e == nil {
+		return
 	}
+
+	c.removeLocked(e)
+	c.stats.Evictions++
+	logger.Log.WithField("order_uid", e.order.OrderUID).Info("Oldest order deleted")
+}
+
+func (c *Cache) removeLocked(e *entry) {
+	c.policy.remove(e)
+	delete(c.entries, e.order.OrderUID)
 }
 
 func (c *Cache) Get(orderUID string) (models.Order, bool) {
+	now := time.Now()
 
-	order, exists := c.orders.Load(orderUID)
+	c.mu.Lock()
+	e, exists := c.entries[orderUID]
+	if exists && c.expired(e, now) {
+		c.removeLocked(e)
+		c.stats.Expirations++
+		exists = false
+	}
 	if !exists {
+		c.stats.Misses++
+		c.mu.Unlock()
 		logger.Log.WithField("order_uid", orderUID).Info("Order not found in cache")
 		return models.Order{}, false
 	}
-	c.accessTimes.Store(orderUID, time.Now())
+
+	e.accessedAt = now
+	c.policy.touch(e)
+	c.stats.Hits++
+	order := e.order
+	c.mu.Unlock()
+
 	logger.Log.WithField("order_uid", orderUID).Info("Order found in cache")
-	return order.(models.Order), true
+	return order, true
 }
 
-func (c *Cache) LoadCacheFromDB(storage database.OrderStorage) error {
-	tempCache, err := storage.LoadOrdersFromDB()
+func (c *Cache) LoadCacheFromDB(ctx context.Context, storage database.OrderStorage) error {
+	tempCache, err := storage.LoadOrdersFromDB(ctx)
 	if err != nil {
 		return err
 	}
 
 	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	for orderUID, order := range tempCache {
-		c.orders.Store(orderUID, order)
-		c.accessTimes.Store(orderUID, now)
-		c.currentSize++
+		c.setLocked(order, now)
 		logger.Log.Info("Order loaded in cache", orderUID)
 	}
 
 	return nil
 }
 
+// Clean removes all entries that were not accessed within ttl.
 func (c *Cache) Clean() {
 	now := time.Now()
-	c.accessTimes.Range(func(key, value interface{}) bool {
-		lastAccess := value.(time.Time)
-		if now.Sub(lastAccess) > c.ttl {
-			orderUID := key.(string)
-			c.orders.Delete(orderUID)
-			c.accessTimes.Delete(orderUID)
-			c.currentSize--
+	removed := 0
+
+	c.mu.Lock()
+	for orderUID, e := range c.entries {
+		if c.expired(e, now) {
+			c.removeLocked(e)
+			c.stats.Expirations++
+			removed++
 			logger.Log.WithField("order_uid", orderUID).Info("order removed from cache")
 		}
-		return true
+	}
+	c.mu.Unlock()
+
+	if removed > 0 {
+		logger.Log.WithField("removed", removed).Info("cache cleaned")
+	}
+}
+
+func (c *Cache) expired(e *entry, now time.Time) bool {
+	return now.Sub(e.accessedAt) > c.ttl
+}
+
+// StartJanitor runs Clean every cleanup interval in the background until
+// StopJanitor is called. A non-positive interval disables the janitor.
+func (c *Cache) StartJanitor() {
+	if c.cleanupInterval <= 0 {
+		return
+	}
+
+	c.janitorOnce.Do(func() {
+		go c.runJanitor()
+	})
+}
+
+func (c *Cache) runJanitor() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(c.cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			c.Clean()
+		}
+	}
+}
+
+// StopJanitor stops the background janitor and waits for it to exit. It is
+// safe to call more than once, and when the janitor was never started.
+func (c *Cache) StopJanitor() {
+	c.stopOnce.Do(func() {
+		close(c.stop)
 	})
+
+	started := true
+	c.janitorOnce.Do(func() {
+		started = false
+	})
+	if started {
+		<-c.done
+	}
+}
+
+// Len returns the number of cached orders, including expired ones the
+// janitor has not removed yet.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// Stats returns a snapshot of the cache counters.
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Size = len(c.entries)
+	return stats
 }
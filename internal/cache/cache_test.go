@@ -0,0 +1,297 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/config"
+	"github.com/ArtemKVD/WB-TechL0/internal/logger"
+	"github.com/ArtemKVD/WB-TechL0/internal/mocks"
+	"github.com/ArtemKVD/WB-TechL0/pkg/models"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	logger.Log.SetOutput(io.Discard)
+	logger.Log.SetLevel(logrus.WarnLevel)
+	os.Exit(m.Run())
+}
+
+func newTestCache(maxSize int, ttl time.Duration) *Cache {
+	return NewCache(config.CacheConfig{MaxSize: maxSize, TTL: ttl, Policy: PolicyLRU})
+}
+
+func order(orderUID string) models.Order {
+	return models.Order{OrderUID: orderUID}
+}
+
+func TestCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := newTestCache(3, time.Hour)
+
+	c.Set(order("a"))
+	c.Set(order("b"))
+	c.Set(order("c"))
+
+	_, found := c.Get("a")
+	require.True(t, found)
+
+	c.Set(order("d"))
+
+	assert.Equal(t, 3, c.Len())
+	_, found = c.Get("b")
+	assert.False(t, found, "b was the least recently used entry")
+	for _, orderUID := range []string{"a", "c", "d"} {
+		_, found = c.Get(orderUID)
+		assert.True(t, found, orderUID)
+	}
+}
+
+func TestCache_SetReplacesExistingOrder(t *testing.T) {
+	c := newTestCache(2, time.Hour)
+
+	c.Set(models.Order{OrderUID: "a", TrackNumber: "old"})
+	c.Set(order("b"))
+	c.Set(models.Order{OrderUID: "a", TrackNumber: "new"})
+	c.Set(order("c"))
+
+	assert.Equal(t, 2, c.Len())
+	got, found := c.Get("a")
+	require.True(t, found)
+	assert.Equal(t, "new", got.TrackNumber)
+	_, found = c.Get("b")
+	assert.False(t, found)
+}
+
+func TestCache_DeleteOldestShrinksSize(t *testing.T) {
+	c := newTestCache(10, time.Hour)
+
+	c.Set(order("a"))
+	c.Set(order("b"))
+	c.DeleteOldest()
+
+	assert.Equal(t, 1, c.Len())
+	_, found := c.Get("a")
+	assert.False(t, found)
+
+	c.DeleteOldest()
+	c.DeleteOldest()
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestCache_CleanRemovesExpired(t *testing.T) {
+	c := newTestCache(10, time.Minute)
+
+	c.Set(order("a"))
+	c.Set(order("b"))
+	c.Set(order("c"))
+
+	c.mu.Lock()
+	c.entries["a"].accessedAt = time.Now().Add(-2 * time.Minute)
+	c.entries["c"].accessedAt = time.Now().Add(-2 * time.Minute)
+	c.mu.Unlock()
+
+	c.Clean()
+
+	assert.Equal(t, 1, c.Len())
+	_, found := c.Get("b")
+	assert.True(t, found)
+}
+
+func TestCache_GetDoesNotReturnExpired(t *testing.T) {
+	c := newTestCache(10, time.Minute)
+
+	c.Set(order("a"))
+	c.mu.Lock()
+	c.entries["a"].accessedAt = time.Now().Add(-2 * time.Minute)
+	c.mu.Unlock()
+
+	_, found := c.Get("a")
+	assert.False(t, found)
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestCache_Janitor(t *testing.T) {
+	c := NewCache(config.CacheConfig{MaxSize: 10, TTL: 20 * time.Millisecond, CleanupInterval: 5 * time.Millisecond})
+	c.StartJanitor()
+	defer c.StopJanitor()
+
+	c.Set(order("a"))
+	assert.Eventually(t, func() bool {
+		return c.Len() == 0
+	}, time.Second, 5*time.Millisecond)
+
+	c.StopJanitor()
+	c.StopJanitor()
+	c.StartJanitor()
+
+	c.Set(order("b"))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 1, c.Len(), "janitor must not run after stop")
+}
+
+func TestCache_StopJanitorWithoutStart(t *testing.T) {
+	c := NewCache(config.CacheConfig{CleanupInterval: time.Millisecond})
+
+	done := make(chan struct{})
+	go func() {
+		c.StopJanitor()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("StopJanitor blocked")
+	}
+}
+
+func TestCache_Policies(t *testing.T) {
+	tests := []struct {
+		policy  string
+		evicted string
+	}{
+		// a, b, c are inserted; a is read twice and b once before d arrives.
+		{PolicyLRU, "c"},
+		{PolicyFIFO, "a"},
+		{PolicyLFU, "c"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			c := NewCache(config.CacheConfig{MaxSize: 3, TTL: time.Hour, Policy: tt.policy})
+
+			c.Set(order("a"))
+			c.Set(order("b"))
+			c.Set(order("c"))
+			c.Get("a")
+			c.Get("b")
+			c.Get("a")
+			c.Set(order("d"))
+
+			assert.Equal(t, 3, c.Len())
+			c.mu.Lock()
+			_, found := c.entries[tt.evicted]
+			c.mu.Unlock()
+			assert.False(t, found, "%s should have been evicted", tt.evicted)
+		})
+	}
+}
+
+func TestCache_LFUEvictionAfterRemoval(t *testing.T) {
+	c := NewCache(config.CacheConfig{MaxSize: 3, TTL: time.Hour, Policy: PolicyLFU})
+
+	c.Set(order("a"))
+	c.Set(order("b"))
+	c.Set(order("c"))
+	c.Get("a")
+	c.Get("b")
+	c.Get("c")
+
+	// Removing the only entry of the lowest bucket leaves a stale minimum.
+	c.mu.Lock()
+	c.removeLocked(c.entries["a"])
+	c.mu.Unlock()
+
+	c.Set(order("d"))
+	c.Set(order("e"))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, found := c.entries["d"]
+	assert.False(t, found, "d has the lowest frequency")
+	assert.Len(t, c.entries, 3)
+}
+
+func TestCache_UnknownPolicyFallsBackToLRU(t *testing.T) {
+	c := NewCache(config.CacheConfig{MaxSize: 2, TTL: time.Hour, Policy: "random"})
+
+	c.Set(order("a"))
+	c.Set(order("b"))
+	c.Get("a")
+	c.Set(order("c"))
+
+	_, found := c.Get("a")
+	assert.True(t, found)
+	_, found = c.Get("b")
+	assert.False(t, found)
+}
+
+func TestCache_LoadCacheFromDBRespectsMaxSize(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	orders := map[string]models.Order{}
+	for i := 0; i < 5; i++ {
+		orderUID := fmt.Sprintf("order%d", i)
+		orders[orderUID] = order(orderUID)
+	}
+
+	mockStorage := mocks.NewMockOrderStorage(ctrl)
+	mockStorage.EXPECT().LoadOrdersFromDB(gomock.Any()).Return(orders, nil).Times(1)
+
+	c := newTestCache(3, time.Hour)
+	require.NoError(t, c.LoadCacheFromDB(context.Background(), mockStorage))
+	assert.Equal(t, 3, c.Len())
+}
+
+func TestCache_ConcurrentAccess(t *testing.T) {
+	c := newTestCache(50, time.Hour)
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				orderUID := fmt.Sprintf("order%d", (w*31+i)%200)
+				switch i % 4 {
+				case 0:
+					c.Get(orderUID)
+				case 1:
+					c.DeleteOldest()
+				case 2:
+					c.Clean()
+				default:
+					c.Set(order(orderUID))
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	assert.LessOrEqual(t, c.Len(), 50)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	assert.Equal(t, len(c.entries), c.policy.(*recencyPolicy).list.Len())
+}
+
+func TestCache_Stats(t *testing.T) {
+	c := newTestCache(2, time.Minute)
+
+	c.Set(order("a"))
+	c.Set(order("b"))
+	c.Get("a")
+	c.Get("missing")
+	c.Set(order("c"))
+
+	c.mu.Lock()
+	c.entries["a"].accessedAt = time.Now().Add(-2 * time.Minute)
+	c.mu.Unlock()
+	c.Get("a")
+
+	assert.Equal(t, Stats{
+		Hits:        1,
+		Misses:      2,
+		Evictions:   1,
+		Expirations: 1,
+		Size:        1,
+	}, c.Stats())
+}
@@ -0,0 +1,130 @@
+package cache
+
+import (
+	"container/list"
+	"strings"
+)
+
+// Eviction policies selectable through config.CacheConfig.Policy.
+const (
+	PolicyLRU  = "lru"
+	PolicyLFU  = "lfu"
+	PolicyFIFO = "fifo"
+)
+
+// evictionPolicy decides which entry goes when the cache is full. Methods
+// are called with the cache lock held and are O(1), except that LFU's
+// victim is O(distinct frequencies) after a removal emptied the least
+// frequently used bucket.
+type evictionPolicy interface {
+	add(e *entry)
+	touch(e *entry)
+	remove(e *entry)
+	victim() *entry
+}
+
+func newPolicy(name string) (evictionPolicy, bool) {
+	switch strings.ToLower(name) {
+	case PolicyLRU, "":
+		return &recencyPolicy{list: list.New(), refreshOnTouch: true}, true
+	case PolicyFIFO:
+		return &recencyPolicy{list: list.New()}, true
+	case PolicyLFU:
+		return &frequencyPolicy{buckets: make(map[int]*list.List)}, true
+	default:
+		return nil, false
+	}
+}
+
+// recencyPolicy keeps entries in insertion order, most recent at the front.
+// With refreshOnTouch it is LRU, without it FIFO.
+type recencyPolicy struct {
+	list           *list.List
+	refreshOnTouch bool
+}
+
+func (p *recencyPolicy) add(e *entry) {
+	e.element = p.list.PushFront(e)
+}
+
+func (p *recencyPolicy) touch(e *entry) {
+	if p.refreshOnTouch {
+		p.list.MoveToFront(e.element)
+	}
+}
+
+func (p *recencyPolicy) remove(e *entry) {
+	p.list.Remove(e.element)
+	e.element = nil
+}
+
+func (p *recencyPolicy) victim() *entry {
+	element := p.list.Back()
+	if element == nil {
+		return nil
+	}
+	return element.Value.(*entry)
+}
+
+// frequencyPolicy is LFU with one list per access count. Ties are broken
+// by recency within the least frequently used bucket.
+type frequencyPolicy struct {
+	buckets map[int]*list.List
+	minFreq int
+}
+
+func (p *frequencyPolicy) add(e *entry) {
+	e.freq = 1
+	p.push(e)
+	p.minFreq = 1
+}
+
+func (p *frequencyPolicy) touch(e *entry) {
+	p.unlink(e)
+	e.freq++
+	p.push(e)
+	if _, ok := p.buckets[p.minFreq]; !ok {
+		p.minFreq = e.freq
+	}
+}
+
+func (p *frequencyPolicy) remove(e *entry) {
+	p.unlink(e)
+}
+
+func (p *frequencyPolicy) victim() *entry {
+	bucket, ok := p.buckets[p.minFreq]
+	if !ok {
+		// minFreq went stale after an arbitrary removal; the number of
+		// distinct frequencies is small, so a rescan is cheap.
+		p.minFreq = 0
+		for freq := range p.buckets {
+			if p.minFreq == 0 || freq < p.minFreq {
+				p.minFreq = freq
+			}
+		}
+		bucket, ok = p.buckets[p.minFreq]
+		if !ok {
+			return nil
+		}
+	}
+	return bucket.Back().Value.(*entry)
+}
+
+func (p *frequencyPolicy) push(e *entry) {
+	bucket, ok := p.buckets[e.freq]
+	if !ok {
+		bucket = list.New()
+		p.buckets[e.freq] = bucket
+	}
+	e.element = bucket.PushFront(e)
+}
+
+func (p *frequencyPolicy) unlink(e *entry) {
+	bucket := p.buckets[e.freq]
+	bucket.Remove(e.element)
+	e.element = nil
+	if bucket.Len() == 0 {
+		delete(p.buckets, e.freq)
+	}
+}
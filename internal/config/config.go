@@ -2,34 +2,159 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/ArtemKVD/WB-TechL0/internal/logger"
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	HTTP     HTTPConfig
-	Database DatabaseConfig
-	Kafka    KafkaConfig
+	HTTP       HTTPConfig
+	Database   DatabaseConfig
+	Storage    StorageConfig
+	Kafka      KafkaConfig
+	Transport  TransportConfig
+	NATS       NATSConfig
+	Cache      CacheConfig
+	Outbox     OutboxConfig
+	Ingest     IngestConfig
+	Validation ValidationConfig
+	// ShutdownTimeout bounds the whole graceful shutdown: draining HTTP
+	// requests and the consumer, flushing writers and closing connections.
+	ShutdownTimeout time.Duration
+	// ShutdownDrainDelay is how long /readyz reports shutting down before
+	// the HTTP server stops accepting connections.
+	ShutdownDrainDelay time.Duration
 }
 
 type HTTPConfig struct {
-	Port string
+	Port         string
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+	// HealthCheckTimeout bounds each dependency check of the readiness probe.
+	HealthCheckTimeout time.Duration
 }
 
 type DatabaseConfig struct {
-	Host     string
-	Port     string
-	User     string
-	Password string
-	Name     string
-	SSLMode  string
+	Host           string
+	Port           string
+	User           string
+	Password       string
+	Name           string
+	SSLMode        string
+	ConnectTimeout time.Duration
+	ReadTimeout    time.Duration
+	WriteTimeout   time.Duration
+	ConflictPolicy string
+	MigrateOnStart bool
+}
+
+// StorageConfig selects where orders are stored. Kind is postgres, sqlite
+// for a database file at SQLitePath, or memory for a storage that keeps
+// nothing across restarts. Timeouts and the conflict policy of
+// DatabaseConfig apply to every kind.
+type StorageConfig struct {
+	Kind       string
+	SQLitePath string
+}
+
+// CacheConfig controls the in-memory order cache. Policy is one of lru,
+// lfu or fifo.
+type CacheConfig struct {
+	MaxSize         int
+	TTL             time.Duration
+	CleanupInterval time.Duration
+	Policy          string
 }
 
 type KafkaConfig struct {
-	Broker  string
-	GroupID string
-	Topic   string
+	Broker        string
+	GroupID       string
+	Topic         string
+	RetryInterval time.Duration
+	// Workers is the number of messages processed concurrently, and
+	// WorkerQueueSize how many fetched messages may wait for each worker
+	// before fetching pauses.
+	Workers         int
+	WorkerQueueSize int
+	// BatchSize is the number of orders a worker saves in one transaction,
+	// and BatchWindow how long it waits for a batch to fill. A BatchSize of
+	// 1 or less saves every message on its own.
+	BatchSize   int
+	BatchWindow time.Duration
+	Retry       RetryConfig
+	DeadLetter  DeadLetterConfig
+}
+
+// RetryConfig bounds how long a transient persistence failure is retried
+// before the message is dead-lettered.
+type RetryConfig struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// TransportConfig selects the message broker. Kind is kafka, nats for NATS
+// JetStream, or memory for an in-process broker that needs no external
+// services; it is seeded with MemorySeedOrders generated orders, since
+// nothing else can publish to it.
+type TransportConfig struct {
+	Kind             string
+	MemoryPartitions int
+	MemorySeedOrders int
+}
+
+// NATSConfig configures the NATS JetStream transport. Topics and groups
+// are still named by KafkaConfig and become subjects and durable consumers.
+// A message that is not acked within AckWait is delivered again, and at most
+// MaxAckPending messages of a consumer may be unacked. Streams keep messages
+// for StreamMaxAge, or forever if it is zero.
+type NATSConfig struct {
+	URL            string
+	ConnectTimeout time.Duration
+	AckWait        time.Duration
+	MaxAckPending  int
+	StreamMaxAge   time.Duration
+}
+
+// DeadLetterConfig describes where unprocessable order messages are parked.
+// An empty Topic disables dead-lettering and such messages are dropped.
+type DeadLetterConfig struct {
+	Topic         string
+	ReplayGroupID string
+}
+
+// OutboxConfig controls the relay publishing order events from the outbox
+// table. An empty Topic disables the relay; events are still recorded and
+// published once it is enabled. Sent events are deleted after Retention.
+type OutboxConfig struct {
+	Topic           string
+	BatchSize       int
+	PollInterval    time.Duration
+	Retention       time.Duration
+	CleanupInterval time.Duration
+}
+
+// IngestConfig controls POST /api/v1/orders. Mode "store" saves accepted
+// orders directly, "publish" sends them to the orders topic so they take the
+// same path as orders from the broker. Requests are limited to MaxBodyBytes
+// and MaxBatchSize orders, and responses to requests with an
+// Idempotency-Key are replayed for IdempotencyTTL.
+type IngestConfig struct {
+	Mode           string
+	MaxBodyBytes   int64
+	MaxBatchSize   int
+	IdempotencyTTL time.Duration
+}
+
+// ValidationConfig points to a file of declarative validation rules. It is
+// checked for changes every ReloadInterval, and an empty RulesPath disables
+// the rules.
+type ValidationConfig struct {
+	RulesPath      string
+	ReloadInterval time.Duration
 }
 
 func Load() *Config {
@@ -40,20 +165,134 @@ func Load() *Config {
 
 	return &Config{
 		HTTP: HTTPConfig{
-			Port: os.Getenv("HTTP_PORT"),
+			Port:               os.Getenv("HTTP_PORT"),
+			ReadTimeout:        getDuration("HTTP_READ_TIMEOUT", 5*time.Second),
+			WriteTimeout:       getDuration("HTTP_WRITE_TIMEOUT", 10*time.Second),
+			IdleTimeout:        getDuration("HTTP_IDLE_TIMEOUT", time.Minute),
+			HealthCheckTimeout: getDuration("HEALTH_CHECK_TIMEOUT", 2*time.Second),
 		},
 		Database: DatabaseConfig{
-			Host:     os.Getenv("POSTGRES_HOST"),
-			Port:     os.Getenv("POSTGRES_PORT"),
-			User:     os.Getenv("POSTGRES_USER"),
-			Password: os.Getenv("POSTGRES_PASSWORD"),
-			Name:     os.Getenv("POSTGRES_DB"),
-			SSLMode:  os.Getenv("POSTGRES_SSLMODE"),
+			Host:           os.Getenv("POSTGRES_HOST"),
+			Port:           os.Getenv("POSTGRES_PORT"),
+			User:           os.Getenv("POSTGRES_USER"),
+			Password:       os.Getenv("POSTGRES_PASSWORD"),
+			Name:           os.Getenv("POSTGRES_DB"),
+			SSLMode:        os.Getenv("POSTGRES_SSLMODE"),
+			ConnectTimeout: getDuration("POSTGRES_CONNECT_TIMEOUT", 5*time.Second),
+			ReadTimeout:    getDuration("POSTGRES_READ_TIMEOUT", 3*time.Second),
+			WriteTimeout:   getDuration("POSTGRES_WRITE_TIMEOUT", 5*time.Second),
+			ConflictPolicy: getString("ORDER_CONFLICT_POLICY", "reject"),
+			MigrateOnStart: getBool("POSTGRES_MIGRATE_ON_START", false),
+		},
+		Storage: StorageConfig{
+			Kind:       getString("STORAGE_BACKEND", "postgres"),
+			SQLitePath: getString("SQLITE_PATH", "orders.db"),
 		},
 		Kafka: KafkaConfig{
-			Broker:  os.Getenv("KAFKA_BROKER"),
-			GroupID: os.Getenv("KAFKA_GROUP_ID"),
-			Topic:   os.Getenv("KAFKA_TOPIC"),
+			Broker:          os.Getenv("KAFKA_BROKER"),
+			GroupID:         os.Getenv("KAFKA_GROUP_ID"),
+			Topic:           os.Getenv("KAFKA_TOPIC"),
+			RetryInterval:   getDuration("KAFKA_RETRY_INTERVAL", time.Second),
+			Workers:         getInt("KAFKA_WORKERS", 4),
+			WorkerQueueSize: getInt("KAFKA_WORKER_QUEUE_SIZE", 16),
+			BatchSize:       getInt("KAFKA_BATCH_SIZE", 100),
+			BatchWindow:     getDuration("KAFKA_BATCH_WINDOW", 50*time.Millisecond),
+			Retry: RetryConfig{
+				MaxAttempts:    getInt("KAFKA_RETRY_MAX_ATTEMPTS", 5),
+				InitialBackoff: getDuration("KAFKA_RETRY_INITIAL_BACKOFF", 200*time.Millisecond),
+				MaxBackoff:     getDuration("KAFKA_RETRY_MAX_BACKOFF", 10*time.Second),
+			},
+			DeadLetter: DeadLetterConfig{
+				Topic:         os.Getenv("KAFKA_DLQ_TOPIC"),
+				ReplayGroupID: getString("KAFKA_DLQ_REPLAY_GROUP_ID", "order-dlq-replay"),
+			},
+		},
+		Transport: TransportConfig{
+			Kind:             getString("MESSAGE_TRANSPORT", "kafka"),
+			MemoryPartitions: getInt("MEMORY_TRANSPORT_PARTITIONS", 4),
+			MemorySeedOrders: getInt("MEMORY_TRANSPORT_SEED_ORDERS", 10),
+		},
+		NATS: NATSConfig{
+			URL:            getString("NATS_URL", "nats://localhost:4222"),
+			ConnectTimeout: getDuration("NATS_CONNECT_TIMEOUT", 5*time.Second),
+			AckWait:        getDuration("NATS_ACK_WAIT", 30*time.Second),
+			MaxAckPending:  getInt("NATS_MAX_ACK_PENDING", 1000),
+			StreamMaxAge:   getDuration("NATS_STREAM_MAX_AGE", 7*24*time.Hour),
+		},
+		Cache: CacheConfig{
+			MaxSize:         getInt("CACHE_MAX_SIZE", 1000),
+			TTL:             getDuration("CACHE_TTL", 15*time.Minute),
+			CleanupInterval: getDuration("CACHE_CLEANUP_INTERVAL", time.Minute),
+			Policy:          getString("CACHE_POLICY", "lru"),
+		},
+		Outbox: OutboxConfig{
+			Topic:           os.Getenv("OUTBOX_TOPIC"),
+			BatchSize:       getInt("OUTBOX_BATCH_SIZE", 100),
+			PollInterval:    getDuration("OUTBOX_POLL_INTERVAL", time.Second),
+			Retention:       getDuration("OUTBOX_RETENTION", 7*24*time.Hour),
+			CleanupInterval: getDuration("OUTBOX_CLEANUP_INTERVAL", time.Hour),
 		},
+		Ingest: IngestConfig{
+			Mode:           getString("INGEST_MODE", "store"),
+			MaxBodyBytes:   int64(getInt("INGEST_MAX_BODY_BYTES", 10<<20)),
+			MaxBatchSize:   getInt("INGEST_MAX_BATCH_SIZE", 1000),
+			IdempotencyTTL: getDuration("INGEST_IDEMPOTENCY_TTL", 24*time.Hour),
+		},
+		Validation: ValidationConfig{
+			RulesPath:      os.Getenv("VALIDATION_RULES_PATH"),
+			ReloadInterval: getDuration("VALIDATION_RULES_RELOAD_INTERVAL", 10*time.Second),
+		},
+		ShutdownTimeout:    getDuration("SHUTDOWN_TIMEOUT", 30*time.Second),
+		ShutdownDrainDelay: getDuration("SHUTDOWN_DRAIN_DELAY", 5*time.Second),
+	}
+}
+
+func getString(key string, def string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	return value
+}
+
+func getBool(key string, def bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		logger.Log.WithField("key", key).Error("invalid boolean, using default: ", err)
+		return def
+	}
+	return b
+}
+
+func getInt(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		logger.Log.WithField("key", key).Error("invalid integer, using default: ", err)
+		return def
+	}
+	return n
+}
+
+func getDuration(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		logger.Log.WithField("key", key).Error("invalid duration, using default: ", err)
+		return def
 	}
+	return d
 }
@@ -0,0 +1,31 @@
+package consumer
+
+import (
+	"math/rand"
+	"time"
+)
+
+// backoff computes exponentially growing delays with jitter so that
+// consumers retrying the same outage do not hit the database in lockstep.
+type backoff struct {
+	initial time.Duration
+	max     time.Duration
+}
+
+// duration returns the delay before retry number attempt (starting at 1):
+// a random value in [d/2, d] where d doubles per attempt up to max.
+func (b backoff) duration(attempt int) time.Duration {
+	d := b.initial
+	for i := 1; i < attempt && d < b.max; i++ {
+		d *= 2
+	}
+	if d > b.max {
+		d = b.max
+	}
+	if d <= 0 {
+		return 0
+	}
+
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
@@ -0,0 +1,37 @@
+package consumer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff_Duration(t *testing.T) {
+	b := backoff{initial: 100 * time.Millisecond, max: time.Second}
+
+	tests := []struct {
+		attempt int
+		min     time.Duration
+		max     time.Duration
+	}{
+		{1, 50 * time.Millisecond, 100 * time.Millisecond},
+		{2, 100 * time.Millisecond, 200 * time.Millisecond},
+		{3, 200 * time.Millisecond, 400 * time.Millisecond},
+		{4, 400 * time.Millisecond, 800 * time.Millisecond},
+		{5, 500 * time.Millisecond, time.Second},
+		{50, 500 * time.Millisecond, time.Second},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			d := b.duration(tt.attempt)
+			assert.GreaterOrEqual(t, d, tt.min, "attempt %d", tt.attempt)
+			assert.LessOrEqual(t, d, tt.max, "attempt %d", tt.attempt)
+		}
+	}
+}
+
+func TestBackoff_Zero(t *testing.T) {
+	assert.Equal(t, time.Duration(0), backoff{}.duration(3))
+}
@@ -0,0 +1,124 @@
+package consumer
+
+import (
+	"context"
+	"time"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/logger"
+	"github.com/ArtemKVD/WB-TechL0/pkg/models"
+	"github.com/sirupsen/logrus"
+)
+
+// runBatches is the worker loop used when batching is enabled. It saves
+// the messages of queue in batches and returns once queue is closed.
+func (c *Consumer) runBatches(ctx context.Context, queue <-chan *trackedMessage) {
+	var carry *trackedMessage
+	for {
+		batch, next, open := c.nextBatch(queue, carry)
+		carry = next
+
+		if len(batch) > 0 {
+			c.processBatch(ctx, batch)
+		}
+		if !open {
+			return
+		}
+	}
+}
+
+// nextBatch collects messages from queue, starting with carry if set, until
+// the batch is full, the batch window has passed or queue is closed. A batch
+// holds at most one message per order: a second one ends the batch and is
+// returned as the start of the next, so updates of an order keep their
+// order. open is false once queue is closed.
+func (c *Consumer) nextBatch(queue <-chan *trackedMessage, carry *trackedMessage) (batch []*trackedMessage, next *trackedMessage, open bool) {
+	if carry == nil {
+		tracked, ok := <-queue
+		if !ok {
+			return nil, nil, false
+		}
+		carry = tracked
+	}
+
+	batch = []*trackedMessage{carry}
+	keys := map[string]bool{carry.key: true}
+
+	timer := time.NewTimer(c.batchWindow)
+	defer timer.Stop()
+
+	for len(batch) < c.batchSize {
+		select {
+		case tracked, ok := <-queue:
+			if !ok {
+				return batch, nil, false
+			}
+			if keys[tracked.key] {
+				return batch, tracked, true
+			}
+			keys[tracked.key] = true
+			batch = append(batch, tracked)
+		case <-timer.C:
+			return batch, nil, true
+		}
+	}
+	return batch, nil, true
+}
+
+// processBatch saves the orders of batch in one transaction and commits
+// their offsets together. Messages that cannot be decoded or whose order
+// failed to save go through processMessage one by one, which retries,
+// holds or dead-letters them exactly like unbatched messages, so a bad
+// order never holds up the rest of the batch.
+func (c *Consumer) processBatch(ctx context.Context, batch []*trackedMessage) {
+	if ctx.Err() != nil {
+		c.abandon(batch...)
+		return
+	}
+
+	var (
+		orders   []models.Order
+		decoded  []*trackedMessage
+		fallback []*trackedMessage
+	)
+	for _, tracked := range batch {
+		order, _, err := decodeOrder(tracked.message)
+		if err != nil {
+			fallback = append(fallback, tracked)
+			continue
+		}
+		orders = append(orders, order)
+		decoded = append(decoded, tracked)
+	}
+
+	if len(orders) > 0 {
+		results, err := c.storage.SaveOrders(ctx, orders)
+		if err != nil {
+			logger.Log.WithFields(logrus.Fields{
+				"orders": len(orders),
+				"error":  err.Error(),
+			}).Warn("Saving batch failed, processing messages one by one")
+			fallback = batch
+		} else {
+			saved := make([]*trackedMessage, 0, len(decoded))
+			for i, tracked := range decoded {
+				if results[i] != nil {
+					fallback = append(fallback, tracked)
+					continue
+				}
+				c.cache.Set(orders[i])
+				c.metrics.MessageConsumed(tracked.message.Topic, tracked.fetchedAt)
+				saved = append(saved, tracked)
+			}
+			logger.Log.WithFields(logrus.Fields{
+				"saved":  len(saved),
+				"failed": len(decoded) - len(saved),
+			}).Info("Order batch saved to DB")
+
+			c.commit(ctx, saved...)
+		}
+	}
+
+	for _, tracked := range fallback {
+		c.processMessage(ctx, tracked)
+	}
+}
@@ -0,0 +1,81 @@
+package consumer_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/consumer"
+	database "github.com/ArtemKVD/WB-TechL0/internal/storage"
+	"github.com/ArtemKVD/WB-TechL0/internal/transport"
+	"github.com/ArtemKVD/WB-TechL0/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsumer_BatchIsolatesBadOrders(t *testing.T) {
+	uids := make([]string, 10)
+	for i := range uids {
+		uids[i] = fmt.Sprintf("order%d", i)
+	}
+	messages := versionedMessages(t, uids)
+	messages = append(messages, transport.Message{Topic: "orders", Offset: int64(len(messages)), Value: []byte("{not json")})
+
+	subscriber := &fakeSubscriber{messages: messages, events: &eventLog{}}
+	publisher := &fakePublisher{}
+	storage := newMemoryStorage(nil)
+	storage.fail = func(order models.Order) error {
+		if order.OrderUID == "order3" {
+			return fmt.Errorf("%w: %s", database.ErrOrderConflict, order.OrderUID)
+		}
+		return nil
+	}
+
+	cfg := testKafkaConfig
+	cfg.Workers = 1
+	cfg.WorkerQueueSize = len(messages)
+	cfg.BatchSize = len(messages)
+	cfg.BatchWindow = time.Second
+	c := consumer.NewConsumer(subscriber, newTestCache(), storage, cfg, consumer.WithDeadLetter(publisher))
+	subscriber.onDrained = c.Stop
+	runConsumer(context.Background(), t, c)
+
+	assert.Equal(t, int64(1), storage.batches.Load())
+	assert.Equal(t, int64(len(uids)-1), storage.saves.Load())
+	assert.NotContains(t, storage.orders, "order3")
+
+	require.Len(t, publisher.messages, 2)
+	assert.Equal(t, consumer.StageDecode, header(t, publisher.messages[0], consumer.HeaderStage))
+	assert.Equal(t, consumer.StagePersist, header(t, publisher.messages[1], consumer.HeaderStage))
+	assert.Equal(t, "3", header(t, publisher.messages[1], consumer.HeaderOriginalOffset))
+
+	assert.Equal(t, int64(len(messages)-1), subscriber.committed[len(subscriber.committed)-1])
+}
+
+func TestConsumer_BatchFailureFallsBackToSingleSaves(t *testing.T) {
+	uids := []string{"order1", "order2", "order3"}
+	subscriber := &fakeSubscriber{messages: versionedMessages(t, uids), events: &eventLog{}}
+	storage := &failingBatchStorage{memoryStorage: newMemoryStorage(nil)}
+
+	cfg := testKafkaConfig
+	cfg.Workers = 1
+	cfg.BatchSize = len(uids)
+	cfg.BatchWindow = time.Second
+	c := consumer.NewConsumer(subscriber, newTestCache(), storage, cfg)
+	subscriber.onDrained = c.Stop
+	runConsumer(context.Background(), t, c)
+
+	assert.Equal(t, int64(len(uids)), storage.saves.Load())
+	assert.Equal(t, []int64{0, 1, 2}, subscriber.committed)
+}
+
+// failingBatchStorage loses the connection on every batch, while single
+// saves succeed.
+type failingBatchStorage struct {
+	*memoryStorage
+}
+
+func (s *failingBatchStorage) SaveOrders(ctx context.Context, orders []models.Order) ([]error, error) {
+	return nil, context.DeadlineExceeded
+}
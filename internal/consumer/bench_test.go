@@ -0,0 +1,67 @@
+package consumer_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/consumer"
+	"github.com/ArtemKVD/WB-TechL0/internal/logger"
+	"github.com/ArtemKVD/WB-TechL0/internal/transport"
+	"github.com/sirupsen/logrus"
+)
+
+// BenchmarkConsumer measures throughput with a simulated database round
+// trip of 200µs per save call, spread over 1000 orders and 4 partitions.
+// With batching, one round trip covers a whole batch.
+func BenchmarkConsumer(b *testing.B) {
+	output, level := logger.Log.Out, logger.Log.Level
+	logger.Log.SetOutput(io.Discard)
+	logger.Log.SetLevel(logrus.WarnLevel)
+	defer func() {
+		logger.Log.SetOutput(output)
+		logger.Log.SetLevel(level)
+	}()
+
+	uids := make([]string, 1000)
+	for i := range uids {
+		uids[i] = fmt.Sprintf("order%d", i)
+	}
+	templates := versionedMessages(b, uids)
+
+	for _, workers := range []int{1, 4, 16, 64} {
+		for _, batchSize := range []int{1, 100} {
+			b.Run(fmt.Sprintf("workers=%d/batch=%d", workers, batchSize), func(b *testing.B) {
+				messages := make([]transport.Message, b.N)
+				for i := range messages {
+					messages[i] = templates[i%len(templates)]
+					messages[i].Partition = i % 4
+					messages[i].Offset = int64(i / 4)
+				}
+
+				subscriber := &fakeSubscriber{messages: messages, events: &eventLog{}}
+				storage := newMemoryStorage(func() time.Duration {
+					return 200 * time.Microsecond
+				})
+
+				cfg := testKafkaConfig
+				cfg.Workers = workers
+				cfg.WorkerQueueSize = 16
+				cfg.BatchSize = batchSize
+				cfg.BatchWindow = 5 * time.Millisecond
+				c := consumer.NewConsumer(subscriber, newTestCache(), storage, cfg)
+				subscriber.onDrained = c.Stop
+
+				b.ResetTimer()
+				c.Run(context.Background())
+				b.StopTimer()
+
+				if storage.saves.Load() != int64(b.N) {
+					b.Fatalf("saved %d of %d orders", storage.saves.Load(), b.N)
+				}
+			})
+		}
+	}
+}
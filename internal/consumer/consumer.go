@@ -0,0 +1,353 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/cache"
+	"github.com/ArtemKVD/WB-TechL0/internal/config"
+	"github.com/ArtemKVD/WB-TechL0/internal/logger"
+	"github.com/ArtemKVD/WB-TechL0/internal/metrics"
+	database "github.com/ArtemKVD/WB-TechL0/internal/storage"
+	"github.com/ArtemKVD/WB-TechL0/internal/transport"
+	"github.com/ArtemKVD/WB-TechL0/pkg/models"
+	"github.com/ArtemKVD/WB-TechL0/pkg/validator"
+	"github.com/sirupsen/logrus"
+)
+
+// stageCommit labels offset commit failures. Commits are retried in place
+// and never dead-lettered.
+const stageCommit = "commit"
+
+type Consumer struct {
+	subscriber    transport.Subscriber
+	cache         cache.CacheService
+	storage       database.OrderStorage
+	retryInterval time.Duration
+	maxAttempts   int
+	backoff       backoff
+	deadLetter    transport.Publisher
+	metrics       *metrics.Metrics
+
+	workers     int
+	queueSize   int
+	batchSize   int
+	batchWindow time.Duration
+	offsets     *offsetTracker
+	commitMu    sync.Mutex
+
+	stopOnce sync.Once
+	stopping chan struct{}
+}
+
+type Option func(*Consumer)
+
+// WithDeadLetter makes the consumer republish unprocessable messages to p
+// instead of dropping them.
+func WithDeadLetter(p transport.Publisher) Option {
+	return func(c *Consumer) {
+		c.deadLetter = p
+	}
+}
+
+// WithMetrics records processing counters, latency and partition lag in m.
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(c *Consumer) {
+		c.metrics = m
+	}
+}
+
+func NewConsumer(subscriber transport.Subscriber, cacheService cache.CacheService, storage database.OrderStorage, cfg config.KafkaConfig, opts ...Option) *Consumer {
+	c := &Consumer{
+		subscriber:    subscriber,
+		cache:         cacheService,
+		storage:       storage,
+		retryInterval: cfg.RetryInterval,
+		maxAttempts:   cfg.Retry.MaxAttempts,
+		backoff: backoff{
+			initial: cfg.Retry.InitialBackoff,
+			max:     cfg.Retry.MaxBackoff,
+		},
+		workers:     cfg.Workers,
+		queueSize:   cfg.WorkerQueueSize,
+		batchSize:   cfg.BatchSize,
+		batchWindow: cfg.BatchWindow,
+		offsets:     newOffsetTracker(),
+		stopping:    make(chan struct{}),
+	}
+	if c.workers < 1 {
+		c.workers = 1
+	}
+	if c.queueSize < 0 {
+		c.queueSize = 0
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Run fetches messages and processes them on a pool of workers until Stop
+// is called or ctx is canceled. Messages are assigned to workers by
+// order_uid, so updates of one order are applied in the order they were
+// fetched. A message is committed only after it, and every message fetched
+// before it from the same partition, has been persisted or found to be
+// unprocessable, so a crash or DB outage leads to redelivery rather than
+// loss.
+//
+// Stop lets the messages already handed to workers finish before Run
+// returns, while canceling ctx abandons them uncommitted.
+func (c *Consumer) Run(ctx context.Context) {
+	fetchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-c.stopping:
+			cancel()
+		case <-fetchCtx.Done():
+		}
+	}()
+
+	pool := c.startWorkers(ctx)
+	defer pool.wait()
+
+	for {
+		select {
+		case <-fetchCtx.Done():
+			return
+		default:
+		}
+
+		message, err := c.subscriber.Fetch(fetchCtx)
+		if err != nil {
+			if fetchCtx.Err() != nil {
+				return
+			}
+			logger.Log.Error("Error fetching message: ", err)
+			continue
+		}
+
+		// Blocks while the worker is saturated, which stops fetching.
+		pool.dispatch(fetchCtx, message)
+	}
+}
+
+// Stop stops fetching and makes Run return once the messages already
+// handed to workers are processed and committed. It does not wait for Run
+// to return.
+func (c *Consumer) Stop() {
+	c.stopOnce.Do(func() {
+		close(c.stopping)
+	})
+}
+
+func (c *Consumer) processMessage(ctx context.Context, tracked *trackedMessage) {
+	if ctx.Err() != nil {
+		c.abandon(tracked)
+		return
+	}
+
+	message := tracked.message
+	err := c.retry(ctx, message, "process", func() error {
+		return c.handleMessage(ctx, message)
+	})
+	if err != nil {
+		c.abandon(tracked)
+		return
+	}
+	c.metrics.MessageConsumed(message.Topic, tracked.fetchedAt)
+
+	c.commit(ctx, tracked)
+}
+
+// abandon hands tracked back to the subscriber for redelivery. It is never
+// marked done, so no offset at or after it is committed either.
+func (c *Consumer) abandon(tracked ...*trackedMessage) {
+	messages := make([]transport.Message, len(tracked))
+	for i, t := range tracked {
+		messages[i] = t.message
+	}
+
+	err := c.subscriber.Nack(context.Background(), messages...)
+	if err != nil {
+		logger.Log.Error("Returning unprocessed messages failed: ", err)
+	}
+}
+
+// commit commits the offsets made contiguous by tracked being done, one
+// per partition. Commits are serialized so that a partition's offset never
+// moves backwards.
+func (c *Consumer) commit(ctx context.Context, tracked ...*trackedMessage) {
+	c.commitMu.Lock()
+	defer c.commitMu.Unlock()
+
+	var messages []transport.Message
+	latest := make(map[topicPartition]int)
+	for _, t := range tracked {
+		message, ok := c.offsets.done(t)
+		if !ok {
+			continue
+		}
+		key := topicPartition{topic: message.Topic, partition: message.Partition}
+		if i, seen := latest[key]; seen {
+			messages[i] = message
+			continue
+		}
+		latest[key] = len(messages)
+		messages = append(messages, message)
+	}
+	if len(messages) == 0 {
+		return
+	}
+
+	err := c.retry(ctx, messages[0], stageCommit, func() error {
+		err := c.subscriber.Ack(ctx, messages...)
+		if err != nil {
+			c.metrics.MessageFailed(stageCommit)
+		}
+		return err
+	})
+	if err != nil {
+		return
+	}
+
+	for _, message := range messages {
+		if message.HighWaterMark > 0 {
+			c.metrics.SetPartitionLag(message.Topic, message.Partition, message.HighWaterMark-message.Offset-1)
+		}
+		logger.Log.WithFields(logMessageFields(message)).Info("Offset committed")
+	}
+}
+
+// handleMessage decodes, validates and persists a single message. It only
+// returns an error when the message has to be retried; messages that can
+// never succeed are dead-lettered and reported as handled.
+func (c *Consumer) handleMessage(ctx context.Context, message transport.Message) error {
+	order, stage, err := decodeOrder(message)
+	if err != nil {
+		c.metrics.MessageFailed(stage)
+		return c.sendToDeadLetter(ctx, message, stage, err)
+	}
+
+	err = c.saveOrder(ctx, message, order)
+	if err != nil {
+		c.metrics.MessageFailed(StagePersist)
+		// Without a dead letter topic an order the database could not take
+		// is held and retried in place rather than dropped. Permanent
+		// failures would never succeed and stall the partition, so they are
+		// dead-lettered, or dropped without a dead letter topic.
+		if ctx.Err() != nil || (c.deadLetter == nil && database.IsTransient(err)) {
+			return fmt.Errorf("save order %s: %w", order.OrderUID, err)
+		}
+		return c.sendToDeadLetter(ctx, message, StagePersist, err)
+	}
+	logger.Log.Info("Order saved to DB: ", order.OrderUID)
+
+	c.cache.Set(order)
+	return nil
+}
+
+// decodeOrder decodes and validates the order in message. On failure it
+// also returns the stage that failed. Violations of warn rules are logged
+// and do not fail the order.
+func decodeOrder(message transport.Message) (models.Order, string, error) {
+	var order models.Order
+	err := json.Unmarshal(message.Value, &order)
+	if err != nil {
+		return order, StageDecode, err
+	}
+
+	warnings, err := validator.CheckOrder(order)
+	if err != nil {
+		return order, StageValidate, err
+	}
+	if len(warnings) > 0 {
+		fields := logMessageFields(message)
+		fields["order_uid"] = order.OrderUID
+		fields["violations"] = warnings
+		logger.Log.WithFields(fields).Warn("Order violates business rules")
+	}
+	return order, "", nil
+}
+
+// saveOrder persists the order, retrying transient failures with jittered
+// exponential backoff up to maxAttempts. Permanent failures are returned
+// right away.
+func (c *Consumer) saveOrder(ctx context.Context, message transport.Message, order models.Order) error {
+	for attempt := 1; ; attempt++ {
+		err := c.storage.SaveOrder(ctx, order)
+		if err == nil {
+			return nil
+		}
+
+		transient := database.IsTransient(err)
+		fields := logMessageFields(message)
+		fields["order_uid"] = order.OrderUID
+		fields["attempt"] = attempt
+		fields["max_attempts"] = c.maxAttempts
+		fields["transient"] = transient
+		fields["error"] = err.Error()
+
+		if !transient || attempt >= c.maxAttempts || ctx.Err() != nil {
+			logger.Log.WithFields(fields).Error("Saving order failed")
+			return err
+		}
+
+		delay := c.backoff.duration(attempt)
+		fields["backoff"] = delay.String()
+		logger.Log.WithFields(fields).Warn("Saving order failed, retrying")
+
+		err = sleep(ctx, delay)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// retry runs fn until it succeeds or ctx is canceled, waiting retryInterval
+// between attempts. The message is held in place meanwhile, so later
+// offsets are never committed past it.
+func (c *Consumer) retry(ctx context.Context, message transport.Message, step string, fn func() error) error {
+	for {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		logger.Log.WithFields(logMessageFields(message)).WithFields(logrus.Fields{
+			"step":  step,
+			"error": err.Error(),
+		}).Error("Message processing failed, retrying")
+
+		err = sleep(ctx, c.retryInterval)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func logMessageFields(message transport.Message) logrus.Fields {
+	return logrus.Fields{
+		"topic":     message.Topic,
+		"partition": message.Partition,
+		"offset":    message.Offset,
+	}
+}
@@ -0,0 +1,415 @@
+package consumer_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/config"
+	"github.com/ArtemKVD/WB-TechL0/internal/consumer"
+	"github.com/ArtemKVD/WB-TechL0/internal/metrics"
+	"github.com/ArtemKVD/WB-TechL0/internal/mocks"
+	database "github.com/ArtemKVD/WB-TechL0/internal/storage"
+	"github.com/ArtemKVD/WB-TechL0/internal/transport"
+	"github.com/ArtemKVD/WB-TechL0/pkg/models"
+	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSubscriber serves a fixed set of messages and then blocks, calling
+// onDrained so the test can stop the consumer once everything was fetched.
+// Acks are recorded as commits.
+type fakeSubscriber struct {
+	mu        sync.Mutex
+	messages  []transport.Message
+	next      int
+	committed []int64
+	events    *eventLog
+	onDrained func()
+}
+
+func (r *fakeSubscriber) Fetch(ctx context.Context) (transport.Message, error) {
+	r.mu.Lock()
+	if r.next < len(r.messages) {
+		message := r.messages[r.next]
+		r.next++
+		r.mu.Unlock()
+		return message, nil
+	}
+	r.mu.Unlock()
+
+	if r.onDrained != nil {
+		r.onDrained()
+	}
+	<-ctx.Done()
+	return transport.Message{}, ctx.Err()
+}
+
+func (r *fakeSubscriber) Ack(ctx context.Context, msgs ...transport.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, msg := range msgs {
+		r.committed = append(r.committed, msg.Offset)
+		r.events.add(fmt.Sprintf("commit:%d", msg.Offset))
+	}
+	return nil
+}
+
+func (r *fakeSubscriber) Nack(ctx context.Context, msgs ...transport.Message) error {
+	return nil
+}
+
+func (r *fakeSubscriber) Close() error {
+	return nil
+}
+
+type eventLog struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (l *eventLog) add(event string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, event)
+}
+
+func (l *eventLog) list() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.events...)
+}
+
+func testOrder(orderUID string) models.Order {
+	return models.Order{
+		OrderUID:        orderUID,
+		TrackNumber:     "WBILMTESTTRACK",
+		Entry:           "WBIL",
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		ShardKey:        "9",
+		SMID:            99,
+		DateCreated:     models.Timestamp{Time: time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC)},
+		OOFShard:        "1",
+		Delivery: models.Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "test@gmail.com",
+		},
+		Payment: models.Payment{
+			Transaction:  orderUID,
+			Currency:     "USD",
+			Provider:     "wbpay",
+			Amount:       1817,
+			PaymentDt:    models.UnixTime{Time: time.Unix(1637907727, 0).UTC()},
+			Bank:         "alpha",
+			DeliveryCost: 1500,
+			GoodsTotal:   317,
+		},
+		Items: []models.Item{{
+			ChrtID:      9934930,
+			TrackNumber: "WBILMTESTTRACK",
+			Price:       453,
+			RID:         "ab4219087a764ae0btest",
+			Name:        "Mascaras",
+			Sale:        30,
+			Size:        "0",
+			TotalPrice:  317,
+			NmID:        2389212,
+			Brand:       "Vivienne Sabo",
+			Status:      202,
+		}},
+	}
+}
+
+func orderMessages(t *testing.T, firstOffset int64, orderUIDs ...string) []transport.Message {
+	t.Helper()
+	messages := make([]transport.Message, 0, len(orderUIDs))
+	for i, orderUID := range orderUIDs {
+		value, err := json.Marshal(testOrder(orderUID))
+		require.NoError(t, err)
+		messages = append(messages, transport.Message{
+			Topic:  "orders",
+			Offset: firstOffset + int64(i),
+			Value:  value,
+		})
+	}
+	return messages
+}
+
+func runConsumer(ctx context.Context, t *testing.T, c *consumer.Consumer) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		c.Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("consumer did not stop")
+	}
+}
+
+var testKafkaConfig = config.KafkaConfig{
+	RetryInterval: time.Millisecond,
+	Retry: config.RetryConfig{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+	},
+}
+
+func TestConsumer_CommitsOnlyAfterSave(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	events := &eventLog{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	subscriber := &fakeSubscriber{
+		messages: orderMessages(t, 0, "order1", "order2", "order3"),
+		events:   events,
+	}
+
+	mockStorage := mocks.NewMockOrderStorage(ctrl)
+	mockCache := mocks.NewMockCacheService(ctrl)
+
+	failures := 2
+	mockStorage.EXPECT().
+		SaveOrder(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, order models.Order) error {
+			if order.OrderUID == "order2" && failures > 0 {
+				failures--
+				events.add("save-failed:" + order.OrderUID)
+				return fmt.Errorf("write: %w", syscall.ECONNRESET)
+			}
+			events.add("save:" + order.OrderUID)
+			return nil
+		}).
+		Times(5)
+
+	mockCache.EXPECT().
+		Set(gomock.Any()).
+		Do(func(order models.Order) {
+			events.add("cache:" + order.OrderUID)
+		}).
+		Times(3)
+
+	c := consumer.NewConsumer(subscriber, mockCache, mockStorage, testKafkaConfig)
+	subscriber.onDrained = c.Stop
+	runConsumer(ctx, t, c)
+
+	assert.Equal(t, []string{
+		"save:order1", "cache:order1", "commit:0",
+		"save-failed:order2", "save-failed:order2", "save:order2", "cache:order2", "commit:1",
+		"save:order3", "cache:order3", "commit:2",
+	}, events.list())
+}
+
+func TestConsumer_NoOrderLostWhenDatabaseFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	messages := orderMessages(t, 0, "order1", "order2", "order3")
+	saved := map[string]int{}
+
+	// First run: the database goes away after the first order and the
+	// consumer is stopped while still retrying the second one.
+	events := &eventLog{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	subscriber := &fakeSubscriber{messages: messages, events: events}
+
+	mockStorage := mocks.NewMockOrderStorage(ctrl)
+	mockCache := mocks.NewMockCacheService(ctrl)
+
+	attempts := 0
+	mockStorage.EXPECT().
+		SaveOrder(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, order models.Order) error {
+			if order.OrderUID == "order1" {
+				saved[order.OrderUID]++
+				return nil
+			}
+			attempts++
+			if attempts == 3 {
+				cancel()
+			}
+			return fmt.Errorf("dial tcp: %w", syscall.ECONNREFUSED)
+		}).
+		MinTimes(4)
+	mockCache.EXPECT().Set(gomock.Any()).Times(1)
+
+	c := consumer.NewConsumer(subscriber, mockCache, mockStorage, testKafkaConfig)
+	runConsumer(ctx, t, c)
+
+	require.Equal(t, []int64{0}, subscriber.committed)
+
+	// Second run: the group resumes after the last committed offset and
+	// the database is healthy again.
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+
+	reader2 := &fakeSubscriber{
+		messages: messages[subscriber.committed[len(subscriber.committed)-1]+1:],
+		events:   &eventLog{},
+	}
+
+	mockStorage2 := mocks.NewMockOrderStorage(ctrl)
+	mockCache2 := mocks.NewMockCacheService(ctrl)
+	mockStorage2.EXPECT().
+		SaveOrder(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, order models.Order) error {
+			saved[order.OrderUID]++
+			return nil
+		}).
+		Times(2)
+	mockCache2.EXPECT().Set(gomock.Any()).Times(2)
+
+	c2 := consumer.NewConsumer(reader2, mockCache2, mockStorage2, testKafkaConfig)
+	reader2.onDrained = c2.Stop
+	runConsumer(ctx2, t, c2)
+
+	assert.Equal(t, map[string]int{"order1": 1, "order2": 1, "order3": 1}, saved)
+	assert.Equal(t, []int64{1, 2}, reader2.committed)
+}
+
+func TestConsumer_DropsInvalidMessagesWithoutDeadLetter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	subscriber := &fakeSubscriber{
+		messages: []transport.Message{{Offset: 0, Value: []byte("{not json")}},
+		events:   &eventLog{},
+	}
+
+	mockStorage := mocks.NewMockOrderStorage(ctrl)
+	mockCache := mocks.NewMockCacheService(ctrl)
+
+	c := consumer.NewConsumer(subscriber, mockCache, mockStorage, testKafkaConfig)
+	subscriber.onDrained = c.Stop
+	runConsumer(ctx, t, c)
+
+	assert.Equal(t, []int64{0}, subscriber.committed)
+}
+
+func TestConsumer_DropsPermanentFailuresWithoutDeadLetter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	subscriber := &fakeSubscriber{
+		messages: orderMessages(t, 0, "order1", "order2"),
+		events:   &eventLog{},
+	}
+
+	mockStorage := mocks.NewMockOrderStorage(ctrl)
+	mockCache := mocks.NewMockCacheService(ctrl)
+
+	mockStorage.EXPECT().
+		SaveOrder(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, order models.Order) error {
+			if order.OrderUID == "order1" {
+				return fmt.Errorf("%w: %s", database.ErrOrderConflict, order.OrderUID)
+			}
+			return nil
+		}).
+		Times(2)
+	mockCache.EXPECT().Set(gomock.Any()).Times(1)
+
+	c := consumer.NewConsumer(subscriber, mockCache, mockStorage, testKafkaConfig)
+	subscriber.onDrained = c.Stop
+	runConsumer(ctx, t, c)
+
+	assert.Equal(t, []int64{0, 1}, subscriber.committed)
+}
+
+func TestConsumer_RecordsMetrics(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	messages := append(orderMessages(t, 0, "order1"), transport.Message{Topic: "orders", Offset: 1, Value: []byte("{not json")})
+	messages[0].HighWaterMark = 10
+	subscriber := &fakeSubscriber{
+		messages: messages,
+		events:   &eventLog{},
+	}
+
+	mockStorage := mocks.NewMockOrderStorage(ctrl)
+	mockCache := mocks.NewMockCacheService(ctrl)
+	mockStorage.EXPECT().SaveOrder(gomock.Any(), gomock.Any()).Return(nil)
+	mockCache.EXPECT().Set(gomock.Any())
+
+	registry := prometheus.NewRegistry()
+	c := consumer.NewConsumer(subscriber, mockCache, mockStorage, testKafkaConfig, consumer.WithMetrics(metrics.New(registry)))
+	subscriber.onDrained = c.Stop
+	runConsumer(ctx, t, c)
+
+	err := testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP order_service_consumer_messages_consumed_total Messages processed, by topic.
+# TYPE order_service_consumer_messages_consumed_total counter
+order_service_consumer_messages_consumed_total{topic="orders"} 2
+# HELP order_service_consumer_messages_failed_total Message processing failures, by stage.
+# TYPE order_service_consumer_messages_failed_total counter
+order_service_consumer_messages_failed_total{stage="decode"} 1
+# HELP order_service_consumer_partition_lag Messages behind the high water mark as of the last committed message, by partition.
+# TYPE order_service_consumer_partition_lag gauge
+order_service_consumer_partition_lag{partition="0",topic="orders"} 9
+`), "order_service_consumer_messages_consumed_total", "order_service_consumer_messages_failed_total", "order_service_consumer_partition_lag")
+	assert.NoError(t, err)
+}
+
+func TestConsumer_StopDrainsMessageInFlight(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	subscriber := &fakeSubscriber{
+		messages: orderMessages(t, 0, "order1", "order2"),
+		events:   &eventLog{},
+	}
+
+	mockStorage := mocks.NewMockOrderStorage(ctrl)
+	mockCache := mocks.NewMockCacheService(ctrl)
+
+	var c *consumer.Consumer
+	mockStorage.EXPECT().
+		SaveOrder(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, order models.Order) error {
+			// Shutdown starts while the order is being saved.
+			c.Stop()
+			time.Sleep(10 * time.Millisecond)
+			return ctx.Err()
+		})
+	mockCache.EXPECT().Set(gomock.Any())
+
+	c = consumer.NewConsumer(subscriber, mockCache, mockStorage, testKafkaConfig)
+	subscriber.onDrained = c.Stop
+	runConsumer(context.Background(), t, c)
+
+	assert.Equal(t, []int64{0}, subscriber.committed)
+}
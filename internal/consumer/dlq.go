@@ -0,0 +1,143 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/logger"
+	"github.com/ArtemKVD/WB-TechL0/internal/transport"
+	"github.com/ArtemKVD/WB-TechL0/pkg/validator"
+	"github.com/sirupsen/logrus"
+)
+
+// Processing stages reported in the HeaderStage header of dead letters.
+const (
+	StageDecode   = "decode"
+	StageValidate = "validate"
+	StagePersist  = "persist"
+)
+
+// Headers attached to every dead-lettered message. The original key, value
+// and headers are kept untouched so the message can be replayed as is.
+const (
+	HeaderStage             = "x-dlq-stage"
+	HeaderError             = "x-dlq-error"
+	HeaderOriginalTopic     = "x-dlq-original-topic"
+	HeaderOriginalPartition = "x-dlq-original-partition"
+	HeaderOriginalOffset    = "x-dlq-original-offset"
+	HeaderTimestamp         = "x-dlq-timestamp"
+	// HeaderViolations holds the validation violations as a JSON array. It
+	// is only set on messages rejected at the validate stage.
+	HeaderViolations = "x-dlq-violations"
+)
+
+const deadLetterHeaderPrefix = "x-dlq-"
+
+func (c *Consumer) sendToDeadLetter(ctx context.Context, message transport.Message, stage string, cause error) error {
+	fields := logMessageFields(message)
+	fields["stage"] = stage
+	fields["error"] = cause.Error()
+
+	if c.deadLetter == nil {
+		logger.Log.WithFields(fields).Error("Dead letter topic is not configured, dropping message")
+		return nil
+	}
+
+	err := c.deadLetter.Publish(ctx, deadLetterMessage(message, stage, cause, time.Now()))
+	if err != nil {
+		return err
+	}
+
+	logger.Log.WithFields(fields).Warn("Message sent to dead letter topic")
+	return nil
+}
+
+func deadLetterMessage(message transport.Message, stage string, cause error, now time.Time) transport.Message {
+	headers := stripDeadLetterHeaders(message.Headers)
+	headers = append(headers,
+		transport.Header{Key: HeaderStage, Value: []byte(stage)},
+		transport.Header{Key: HeaderError, Value: []byte(cause.Error())},
+		transport.Header{Key: HeaderOriginalTopic, Value: []byte(message.Topic)},
+		transport.Header{Key: HeaderOriginalPartition, Value: []byte(strconv.Itoa(message.Partition))},
+		transport.Header{Key: HeaderOriginalOffset, Value: []byte(strconv.FormatInt(message.Offset, 10))},
+		transport.Header{Key: HeaderTimestamp, Value: []byte(now.UTC().Format(time.RFC3339Nano))},
+	)
+	if violations, ok := validator.Violations(cause); ok {
+		value, err := json.Marshal(violations)
+		if err == nil {
+			headers = append(headers, transport.Header{Key: HeaderViolations, Value: value})
+		}
+	}
+
+	return transport.Message{
+		Key:     message.Key,
+		Value:   message.Value,
+		Headers: headers,
+	}
+}
+
+func stripDeadLetterHeaders(headers []transport.Header) []transport.Header {
+	result := make([]transport.Header, 0, len(headers))
+	for _, header := range headers {
+		if strings.HasPrefix(header.Key, deadLetterHeaderPrefix) {
+			continue
+		}
+		result = append(result, header)
+	}
+	return result
+}
+
+func headerValue(headers []transport.Header, key string) string {
+	for _, header := range headers {
+		if header.Key == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+// ReplayDeadLetters moves messages from a dead letter subscriber back to
+// the main topic publisher, dropping the dead letter headers. It stops after limit
+// messages (0 means no limit), when no message arrives within idle, or when
+// ctx is canceled, and returns the number of replayed messages.
+func ReplayDeadLetters(ctx context.Context, subscriber transport.Subscriber, publisher transport.Publisher, limit int, idle time.Duration) (int, error) {
+	replayed := 0
+	for limit == 0 || replayed < limit {
+		fetchCtx, cancel := context.WithTimeout(ctx, idle)
+		message, err := subscriber.Fetch(fetchCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+				return replayed, nil
+			}
+			return replayed, err
+		}
+
+		err = publisher.Publish(ctx, transport.Message{
+			Key:     message.Key,
+			Value:   message.Value,
+			Headers: stripDeadLetterHeaders(message.Headers),
+		})
+		if err != nil {
+			return replayed, err
+		}
+
+		err = subscriber.Ack(ctx, message)
+		if err != nil {
+			return replayed, err
+		}
+		replayed++
+
+		logger.Log.WithFields(logrus.Fields{
+			"offset":          message.Offset,
+			"stage":           headerValue(message.Headers, HeaderStage),
+			"original_offset": headerValue(message.Headers, HeaderOriginalOffset),
+		}).Info("Dead letter replayed")
+	}
+
+	return replayed, nil
+}
@@ -0,0 +1,160 @@
+package consumer_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/consumer"
+	"github.com/ArtemKVD/WB-TechL0/internal/mocks"
+	"github.com/ArtemKVD/WB-TechL0/internal/transport"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakePublisher struct {
+	mu       sync.Mutex
+	messages []transport.Message
+	failures int
+}
+
+func (w *fakePublisher) Publish(ctx context.Context, msgs ...transport.Message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.failures > 0 {
+		w.failures--
+		return errors.New("broker not available")
+	}
+	w.messages = append(w.messages, msgs...)
+	return nil
+}
+
+func (w *fakePublisher) Close() error {
+	return nil
+}
+
+func header(t *testing.T, message transport.Message, key string) string {
+	t.Helper()
+	for _, h := range message.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	t.Fatalf("header %s not found", key)
+	return ""
+}
+
+func TestConsumer_DeadLettersInvalidMessages(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	invalid := testOrder("invalid")
+	invalid.Items = nil
+	invalidValue, err := json.Marshal(invalid)
+	require.NoError(t, err)
+
+	var badDate map[string]any
+	require.NoError(t, json.Unmarshal(invalidValue, &badDate))
+	badDate["items"] = testOrder("bad-date").Items
+	badDate["date_created"] = "26.11.2021"
+	badDateValue, err := json.Marshal(badDate)
+	require.NoError(t, err)
+
+	subscriber := &fakeSubscriber{
+		messages: []transport.Message{
+			{
+				Topic:     "orders",
+				Partition: 2,
+				Offset:    10,
+				Key:       []byte("key"),
+				Value:     []byte("{not json"),
+				Headers:   []transport.Header{{Key: "trace-id", Value: []byte("abc")}},
+			},
+			{Topic: "orders", Partition: 2, Offset: 11, Value: invalidValue},
+			{Topic: "orders", Partition: 2, Offset: 12, Value: badDateValue},
+		},
+		events: &eventLog{},
+	}
+	publisher := &fakePublisher{failures: 1}
+
+	mockStorage := mocks.NewMockOrderStorage(ctrl)
+	mockCache := mocks.NewMockCacheService(ctrl)
+
+	before := time.Now().UTC()
+	c := consumer.NewConsumer(subscriber, mockCache, mockStorage, testKafkaConfig, consumer.WithDeadLetter(publisher))
+	subscriber.onDrained = c.Stop
+	runConsumer(ctx, t, c)
+
+	assert.Equal(t, []int64{10, 11, 12}, subscriber.committed)
+	require.Len(t, publisher.messages, 3)
+
+	decodeFailure := publisher.messages[0]
+	assert.Equal(t, []byte("key"), decodeFailure.Key)
+	assert.Equal(t, []byte("{not json"), decodeFailure.Value)
+	assert.Equal(t, "abc", header(t, decodeFailure, "trace-id"))
+	assert.Equal(t, consumer.StageDecode, header(t, decodeFailure, consumer.HeaderStage))
+	assert.NotEmpty(t, header(t, decodeFailure, consumer.HeaderError))
+	assert.Equal(t, "orders", header(t, decodeFailure, consumer.HeaderOriginalTopic))
+	assert.Equal(t, "2", header(t, decodeFailure, consumer.HeaderOriginalPartition))
+	assert.Equal(t, "10", header(t, decodeFailure, consumer.HeaderOriginalOffset))
+
+	failedAt, err := time.Parse(time.RFC3339Nano, header(t, decodeFailure, consumer.HeaderTimestamp))
+	require.NoError(t, err)
+	assert.False(t, failedAt.Before(before.Truncate(time.Second)))
+
+	validateFailure := publisher.messages[1]
+	assert.Equal(t, consumer.StageValidate, header(t, validateFailure, consumer.HeaderStage))
+	assert.Equal(t, "order validation failed: items is required", header(t, validateFailure, consumer.HeaderError))
+	assert.JSONEq(t, `[{"path": "items", "rule": "required", "message": "is required"}]`,
+		header(t, validateFailure, consumer.HeaderViolations))
+	assert.Equal(t, "11", header(t, validateFailure, consumer.HeaderOriginalOffset))
+
+	dateFailure := publisher.messages[2]
+	assert.Equal(t, consumer.StageValidate, header(t, dateFailure, consumer.HeaderStage))
+	assert.JSONEq(t, `[{"path": "date_created", "rule": "timestamp", "value": "26.11.2021", "message": "must be an RFC 3339 timestamp"}]`,
+		header(t, dateFailure, consumer.HeaderViolations))
+}
+
+func TestReplayDeadLetters(t *testing.T) {
+	ctx := context.Background()
+
+	subscriber := &fakeSubscriber{
+		messages: []transport.Message{
+			{
+				Offset: 0,
+				Key:    []byte("order1"),
+				Value:  []byte(`{"order_uid":"order1"}`),
+				Headers: []transport.Header{
+					{Key: "trace-id", Value: []byte("abc")},
+					{Key: consumer.HeaderStage, Value: []byte(consumer.StageValidate)},
+					{Key: consumer.HeaderError, Value: []byte("order item is nil")},
+				},
+			},
+			{Offset: 1, Value: []byte(`{"order_uid":"order2"}`)},
+			{Offset: 2, Value: []byte(`{"order_uid":"order3"}`)},
+		},
+		events: &eventLog{},
+	}
+	publisher := &fakePublisher{}
+
+	replayed, err := consumer.ReplayDeadLetters(ctx, subscriber, publisher, 2, 10*time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, 2, replayed)
+	assert.Equal(t, []int64{0, 1}, subscriber.committed)
+
+	require.Len(t, publisher.messages, 2)
+	assert.Equal(t, []byte("order1"), publisher.messages[0].Key)
+	assert.Equal(t, []transport.Header{{Key: "trace-id", Value: []byte("abc")}}, publisher.messages[0].Headers)
+
+	replayed, err = consumer.ReplayDeadLetters(ctx, subscriber, publisher, 0, 10*time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, 1, replayed)
+	assert.Equal(t, []int64{0, 1, 2}, subscriber.committed)
+}
@@ -0,0 +1,113 @@
+package consumer_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/config"
+	"github.com/ArtemKVD/WB-TechL0/internal/consumer"
+	"github.com/ArtemKVD/WB-TechL0/internal/producer"
+	"github.com/ArtemKVD/WB-TechL0/internal/transport"
+	"github.com/ArtemKVD/WB-TechL0/pkg/models"
+	"github.com/nats-io/nats-server/v2/server"
+	natstest "github.com/nats-io/nats-server/v2/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipeline_MemoryTransport(t *testing.T) {
+	testPipeline(t, transport.NewMemoryBroker(4))
+}
+
+func TestPipeline_NATSTransport(t *testing.T) {
+	opts := natstest.DefaultTestOptions
+	opts.Port = server.RANDOM_PORT
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+	srv := natstest.RunServer(&opts)
+	defer srv.Shutdown()
+
+	broker, err := transport.NewNATSBroker(config.NATSConfig{
+		URL:            srv.ClientURL(),
+		ConnectTimeout: time.Second,
+		AckWait:        500 * time.Millisecond,
+		MaxAckPending:  100,
+	})
+	require.NoError(t, err)
+	defer broker.Close()
+
+	testPipeline(t, broker)
+}
+
+// testPipeline runs producer, consumer and dead letter topic on broker,
+// with no other external services.
+func testPipeline(t *testing.T, broker transport.Broker) {
+	ctx := context.Background()
+	orders := broker.Publisher("orders")
+
+	var sent []models.Order
+	for i := range 50 {
+		sent = append(sent, testOrder(fmt.Sprintf("order%d", i)))
+	}
+	require.Equal(t, len(sent), producer.Send(ctx, orders, sent))
+	// A redelivery and a message that is not an order.
+	require.Equal(t, 1, producer.Send(ctx, orders, sent[:1]))
+	require.NoError(t, orders.Publish(ctx, transport.Message{Key: []byte("junk"), Value: []byte("{not json")}))
+
+	storage := newMemoryStorage(nil)
+	orderCache := newTestCache()
+
+	cfg := testKafkaConfig
+	cfg.Workers = 4
+	cfg.WorkerQueueSize = 8
+	cfg.BatchSize = 10
+	cfg.BatchWindow = time.Millisecond
+	subscriber := broker.Subscriber("orders", "order-consumers")
+	c := consumer.NewConsumer(subscriber, orderCache, storage, cfg,
+		consumer.WithDeadLetter(broker.Publisher("orders-dlq")),
+	)
+
+	done := make(chan struct{})
+	go func() {
+		c.Run(ctx)
+		close(done)
+	}()
+
+	deadLetters := broker.Subscriber("orders-dlq", "inspect")
+	defer deadLetters.Close()
+	fetchCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	deadLetter, err := deadLetters.Fetch(fetchCtx)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("junk"), deadLetter.Key)
+	assert.Equal(t, consumer.StageDecode, header(t, deadLetter, consumer.HeaderStage))
+
+	require.Eventually(t, func() bool {
+		storage.mu.Lock()
+		defer storage.mu.Unlock()
+		return len(storage.orders) == len(sent)
+	}, 5*time.Second, time.Millisecond)
+
+	c.Stop()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("consumer did not stop")
+	}
+	require.NoError(t, subscriber.Close())
+
+	for _, order := range sent {
+		_, cached := orderCache.Get(order.OrderUID)
+		assert.True(t, cached, order.OrderUID)
+	}
+
+	// Everything was acked, so the group has nothing left to deliver.
+	next := broker.Subscriber("orders", "order-consumers")
+	defer next.Close()
+	fetchCtx, cancel = context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	_, err = next.Fetch(fetchCtx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
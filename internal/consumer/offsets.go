@@ -0,0 +1,78 @@
+package consumer
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/transport"
+)
+
+// trackedMessage is a fetched message waiting to be processed and
+// committed.
+type trackedMessage struct {
+	message   transport.Message
+	key       string
+	fetchedAt time.Time
+	done      bool
+}
+
+type topicPartition struct {
+	topic     string
+	partition int
+}
+
+// offsetTracker remembers fetched messages per partition in fetch order.
+// Messages complete out of order when several workers are running, but an
+// offset may only be committed once every message before it in the same
+// partition is done as well.
+type offsetTracker struct {
+	mu      sync.Mutex
+	pending map[topicPartition][]*trackedMessage
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{pending: make(map[topicPartition][]*trackedMessage)}
+}
+
+func (t *offsetTracker) add(message transport.Message) *trackedMessage {
+	tracked := &trackedMessage{message: message, fetchedAt: time.Now()}
+	key := topicPartition{topic: message.Topic, partition: message.Partition}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending[key] = append(t.pending[key], tracked)
+	return tracked
+}
+
+// done marks tracked as processed and returns the last message of the
+// contiguous run of processed messages at the head of its partition, which
+// is the one to commit. It reports false if an earlier message is still
+// being processed.
+func (t *offsetTracker) done(tracked *trackedMessage) (transport.Message, bool) {
+	key := topicPartition{topic: tracked.message.Topic, partition: tracked.message.Partition}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tracked.done = true
+	pending := t.pending[key]
+
+	n := 0
+	for n < len(pending) && pending[n].done {
+		n++
+	}
+	if n == 0 {
+		return transport.Message{}, false
+	}
+
+	last := pending[n-1].message
+	for i := range pending[:n] {
+		pending[i] = nil
+	}
+	if n == len(pending) {
+		delete(t.pending, key)
+	} else {
+		t.pending[key] = pending[n:]
+	}
+	return last, true
+}
@@ -0,0 +1,50 @@
+package consumer
+
+import (
+	"testing"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/transport"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOffsetTracker_CommitsContiguousOffsets(t *testing.T) {
+	tracker := newOffsetTracker()
+
+	p0 := make([]*trackedMessage, 4)
+	for i := range p0 {
+		p0[i] = tracker.add(transport.Message{Topic: "orders", Partition: 0, Offset: int64(10 + i)})
+	}
+	p1 := tracker.add(transport.Message{Topic: "orders", Partition: 1, Offset: 7})
+
+	_, ok := tracker.done(p0[1])
+	assert.False(t, ok, "offset 10 is still in flight")
+	_, ok = tracker.done(p0[2])
+	assert.False(t, ok)
+
+	message, ok := tracker.done(p1)
+	assert.True(t, ok, "partitions are independent")
+	assert.Equal(t, int64(7), message.Offset)
+
+	message, ok = tracker.done(p0[0])
+	assert.True(t, ok)
+	assert.Equal(t, int64(12), message.Offset)
+
+	message, ok = tracker.done(p0[3])
+	assert.True(t, ok)
+	assert.Equal(t, int64(13), message.Offset)
+	assert.Empty(t, tracker.pending)
+}
+
+func TestWorkerIndex_StablePerKey(t *testing.T) {
+	for _, key := range []string{"", "order1", "b563feb7b2b84b6test"} {
+		index := workerIndex(key, 8)
+		assert.Equal(t, index, workerIndex(key, 8))
+		assert.GreaterOrEqual(t, index, 0)
+		assert.Less(t, index, 8)
+	}
+}
+
+func TestOrderKey(t *testing.T) {
+	assert.Equal(t, "order1", orderKey(transport.Message{Value: []byte(`{"order_uid":"order1"}`), Key: []byte("key")}))
+	assert.Equal(t, "key", orderKey(transport.Message{Value: []byte(`{not json`), Key: []byte("key")}))
+}
@@ -0,0 +1,85 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"sync"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/transport"
+)
+
+// workerPool runs the consumer's workers. Each worker owns a bounded queue,
+// and every message of an order goes to the same worker.
+type workerPool struct {
+	consumer *Consumer
+	queues   []chan *trackedMessage
+	wg       sync.WaitGroup
+}
+
+func (c *Consumer) startWorkers(ctx context.Context) *workerPool {
+	pool := &workerPool{
+		consumer: c,
+		queues:   make([]chan *trackedMessage, c.workers),
+	}
+
+	for i := range pool.queues {
+		queue := make(chan *trackedMessage, c.queueSize)
+		pool.queues[i] = queue
+
+		pool.wg.Add(1)
+		go func() {
+			defer pool.wg.Done()
+			if c.batchSize > 1 {
+				c.runBatches(ctx, queue)
+				return
+			}
+			for tracked := range queue {
+				c.processMessage(ctx, tracked)
+			}
+		}()
+	}
+	return pool
+}
+
+// dispatch queues message on the worker owning its order. It blocks while
+// that worker's queue is full and gives up when ctx is done.
+func (p *workerPool) dispatch(ctx context.Context, message transport.Message) {
+	key := orderKey(message)
+	queue := p.queues[workerIndex(key, len(p.queues))]
+	tracked := p.consumer.offsets.add(message)
+	tracked.key = key
+
+	select {
+	case queue <- tracked:
+	case <-ctx.Done():
+	}
+}
+
+// wait closes the queues and waits for the workers to exit.
+func (p *workerPool) wait() {
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.wg.Wait()
+}
+
+// orderKey returns the order_uid of the message, falling back to the
+// message key for payloads that cannot be decoded. Such messages are
+// dead-lettered anyway, so their placement only needs to be stable.
+func orderKey(message transport.Message) string {
+	var order struct {
+		OrderUID string `json:"order_uid"`
+	}
+	err := json.Unmarshal(message.Value, &order)
+	if err != nil || order.OrderUID == "" {
+		return string(message.Key)
+	}
+	return order.OrderUID
+}
+
+func workerIndex(key string, workers int) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(workers))
+}
@@ -0,0 +1,232 @@
+package consumer_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/cache"
+	"github.com/ArtemKVD/WB-TechL0/internal/config"
+	"github.com/ArtemKVD/WB-TechL0/internal/consumer"
+	database "github.com/ArtemKVD/WB-TechL0/internal/storage"
+	"github.com/ArtemKVD/WB-TechL0/internal/transport"
+	"github.com/ArtemKVD/WB-TechL0/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStorage is an OrderStorage that keeps orders in a map and takes
+// latency per call, standing in for a Postgres round trip. Orders for which
+// fail returns an error are not stored.
+type memoryStorage struct {
+	database.OrderStorage
+
+	latency func() time.Duration
+	onSave  func(order models.Order)
+	fail    func(order models.Order) error
+
+	mu      sync.Mutex
+	orders  map[string]models.Order
+	saves   atomic.Int64
+	batches atomic.Int64
+}
+
+func newMemoryStorage(latency func() time.Duration) *memoryStorage {
+	return &memoryStorage{latency: latency, orders: make(map[string]models.Order)}
+}
+
+func (s *memoryStorage) SaveOrder(ctx context.Context, order models.Order) error {
+	err := s.wait(ctx)
+	if err != nil {
+		return err
+	}
+	return s.save(order)
+}
+
+func (s *memoryStorage) SaveOrders(ctx context.Context, orders []models.Order) ([]error, error) {
+	err := s.wait(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.batches.Add(1)
+	results := make([]error, len(orders))
+	for i, order := range orders {
+		results[i] = s.save(order)
+	}
+	return results, nil
+}
+
+func (s *memoryStorage) wait(ctx context.Context) error {
+	if s.latency == nil {
+		return nil
+	}
+
+	timer := time.NewTimer(s.latency())
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *memoryStorage) save(order models.Order) error {
+	if s.fail != nil {
+		err := s.fail(order)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	s.orders[order.OrderUID] = order
+	s.mu.Unlock()
+	if s.onSave != nil {
+		s.onSave(order)
+	}
+	s.saves.Add(1)
+	return nil
+}
+
+// versionedMessages returns one message per order UID in uids, on a single
+// partition. SMID carries the offset so saves can be matched to messages.
+func versionedMessages(t testing.TB, uids []string) []transport.Message {
+	t.Helper()
+	messages := make([]transport.Message, 0, len(uids))
+	for i, uid := range uids {
+		order := testOrder(uid)
+		order.SMID = i + 1
+		value, err := json.Marshal(order)
+		require.NoError(t, err)
+		messages = append(messages, transport.Message{Topic: "orders", Offset: int64(i), Value: value})
+	}
+	return messages
+}
+
+func newTestCache() *cache.Cache {
+	return cache.NewCache(config.CacheConfig{MaxSize: 1000, TTL: time.Hour})
+}
+
+func TestConsumer_WorkersKeepPerOrderOrdering(t *testing.T) {
+	for _, batchSize := range []int{1, 10} {
+		t.Run(fmt.Sprintf("batch=%d", batchSize), func(t *testing.T) {
+			uids := make([]string, 200)
+			for i := range uids {
+				uids[i] = fmt.Sprintf("order%d", i%37)
+			}
+
+			events := &eventLog{}
+			subscriber := &fakeSubscriber{messages: versionedMessages(t, uids), events: events}
+			storage := newMemoryStorage(func() time.Duration {
+				return time.Duration(rand.Intn(300)) * time.Microsecond
+			})
+			storage.onSave = func(order models.Order) {
+				events.add(fmt.Sprintf("save:%s:%d", order.OrderUID, order.SMID-1))
+			}
+
+			cfg := testKafkaConfig
+			cfg.Workers = 4
+			cfg.WorkerQueueSize = 3
+			cfg.BatchSize = batchSize
+			cfg.BatchWindow = time.Millisecond
+			c := consumer.NewConsumer(subscriber, newTestCache(), storage, cfg)
+			subscriber.onDrained = c.Stop
+			runConsumer(context.Background(), t, c)
+
+			last := map[string]int64{}
+			saved := map[int64]bool{}
+			committed := int64(-1)
+			for _, event := range events.list() {
+				parts := strings.Split(event, ":")
+				offset, err := strconv.ParseInt(parts[len(parts)-1], 10, 64)
+				require.NoError(t, err)
+
+				switch parts[0] {
+				case "commit":
+					assert.Greater(t, offset, committed, "commits only move forward")
+					for o := committed + 1; o <= offset; o++ {
+						assert.True(t, saved[o], "offset %d committed before it was saved", o)
+					}
+					committed = offset
+				case "save":
+					uid := parts[1]
+					previous, seen := last[uid]
+					if seen {
+						assert.Greater(t, offset, previous, "%s saved out of order", uid)
+					}
+					last[uid] = offset
+					saved[offset] = true
+				}
+			}
+
+			assert.Equal(t, int64(len(uids)), storage.saves.Load())
+			assert.Equal(t, int64(len(uids)-1), committed)
+		})
+	}
+}
+
+// countingSubscriber counts fetches so tests can observe backpressure.
+type countingSubscriber struct {
+	fakeSubscriber
+	fetched atomic.Int64
+}
+
+func (r *countingSubscriber) Fetch(ctx context.Context) (transport.Message, error) {
+	message, err := r.fakeSubscriber.Fetch(ctx)
+	if err == nil {
+		r.fetched.Add(1)
+	}
+	return message, err
+}
+
+func TestConsumer_BackpressureWhenWorkersSaturated(t *testing.T) {
+	uids := make([]string, 20)
+	for i := range uids {
+		uids[i] = fmt.Sprintf("order%d", i)
+	}
+
+	subscriber := &countingSubscriber{fakeSubscriber: fakeSubscriber{messages: versionedMessages(t, uids), events: &eventLog{}}}
+
+	release := make(chan struct{})
+	storage := newMemoryStorage(nil)
+	storage.latency = func() time.Duration {
+		<-release
+		return 0
+	}
+
+	cfg := testKafkaConfig
+	cfg.Workers = 2
+	cfg.WorkerQueueSize = 1
+
+	c := consumer.NewConsumer(subscriber, newTestCache(), storage, cfg)
+	subscriber.onDrained = c.Stop
+
+	done := make(chan struct{})
+	go func() {
+		c.Run(context.Background())
+		close(done)
+	}()
+
+	// Both workers are blocked: at most one message in flight and one
+	// queued per worker, plus the one waiting to be dispatched.
+	time.Sleep(50 * time.Millisecond)
+	assert.LessOrEqual(t, subscriber.fetched.Load(), int64(5))
+
+	close(release)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("consumer did not stop")
+	}
+	assert.Equal(t, int64(len(uids)), storage.saves.Load())
+	assert.Equal(t, int64(len(uids)-1), subscriber.committed[len(subscriber.committed)-1])
+}
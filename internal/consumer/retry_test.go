@@ -0,0 +1,84 @@
+package consumer_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/consumer"
+	"github.com/ArtemKVD/WB-TechL0/internal/mocks"
+	"github.com/ArtemKVD/WB-TechL0/pkg/models"
+	"github.com/golang/mock/gomock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsumer_PersistRetries(t *testing.T) {
+	tests := []struct {
+		name        string
+		errs        []error
+		saveCalls   int
+		cached      int
+		deadLetters int
+	}{
+		{
+			name:      "transient failure recovers",
+			errs:      []error{&pq.Error{Code: "40001"}, &pq.Error{Code: "40P01"}, nil},
+			saveCalls: 3,
+			cached:    1,
+		},
+		{
+			name:        "transient failure exhausts attempts",
+			errs:        []error{&pq.Error{Code: "08006"}, &pq.Error{Code: "08006"}, &pq.Error{Code: "08006"}},
+			saveCalls:   3,
+			deadLetters: 1,
+		},
+		{
+			name:        "permanent failure is not retried",
+			errs:        []error{&pq.Error{Code: "23505", Message: "duplicate key value"}},
+			saveCalls:   1,
+			deadLetters: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			subscriber := &fakeSubscriber{
+				messages: orderMessages(t, 5, "order1"),
+				events:   &eventLog{},
+			}
+			publisher := &fakePublisher{}
+
+			mockStorage := mocks.NewMockOrderStorage(ctrl)
+			mockCache := mocks.NewMockCacheService(ctrl)
+
+			calls := 0
+			mockStorage.EXPECT().
+				SaveOrder(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, _ models.Order) error {
+					err := tt.errs[calls]
+					calls++
+					return err
+				}).
+				Times(tt.saveCalls)
+			mockCache.EXPECT().Set(gomock.Any()).Times(tt.cached)
+
+			c := consumer.NewConsumer(subscriber, mockCache, mockStorage, testKafkaConfig, consumer.WithDeadLetter(publisher))
+			subscriber.onDrained = c.Stop
+			runConsumer(ctx, t, c)
+
+			assert.Equal(t, []int64{5}, subscriber.committed)
+			require.Len(t, publisher.messages, tt.deadLetters)
+			if tt.deadLetters > 0 {
+				assert.Equal(t, consumer.StagePersist, header(t, publisher.messages[0], consumer.HeaderStage))
+				assert.Equal(t, "5", header(t, publisher.messages[0], consumer.HeaderOriginalOffset))
+			}
+		})
+	}
+}
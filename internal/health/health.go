@@ -0,0 +1,171 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+)
+
+// Check reports whether a dependency is usable. It must honor ctx.
+type Check func(ctx context.Context) error
+
+// Report is the body returned by the probe endpoints.
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+type CheckResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Health tracks whether the service may receive traffic. The service is
+// ready once MarkReady was called, every dependency check passes and
+// shutdown has not started.
+type Health struct {
+	timeout time.Duration
+
+	mu     sync.RWMutex
+	checks map[string]Check
+
+	ready        atomic.Bool
+	shuttingDown atomic.Bool
+}
+
+func New(timeout time.Duration) *Health {
+	return &Health{
+		timeout: timeout,
+		checks:  make(map[string]Check),
+	}
+}
+
+// AddCheck registers a dependency check run on every readiness probe.
+func (h *Health) AddCheck(name string, check Check) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks[name] = check
+}
+
+// MarkReady records that startup work, such as warming the cache, is done.
+func (h *Health) MarkReady() {
+	h.ready.Store(true)
+}
+
+// MarkShuttingDown makes readiness fail so traffic is drained before the
+// process stops.
+func (h *Health) MarkShuttingDown() {
+	h.shuttingDown.Store(true)
+}
+
+// Status runs all checks concurrently, each bounded by the configured
+// timeout, and reports the result per dependency.
+func (h *Health) Status(ctx context.Context) Report {
+	report := Report{
+		Status: StatusOK,
+		Checks: make(map[string]CheckResult),
+	}
+
+	switch {
+	case h.shuttingDown.Load():
+		report.Checks["shutdown"] = CheckResult{Status: StatusUnavailable, Error: "shutting down"}
+	case !h.ready.Load():
+		report.Checks["startup"] = CheckResult{Status: StatusUnavailable, Error: "cache is not loaded yet"}
+	default:
+		report.Checks["startup"] = CheckResult{Status: StatusOK}
+	}
+
+	h.mu.RLock()
+	names := make([]string, 0, len(h.checks))
+	for name := range h.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	results := make([]CheckResult, len(names))
+
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			results[i] = h.run(ctx, check)
+		}(i, h.checks[name])
+	}
+	h.mu.RUnlock()
+	wg.Wait()
+
+	for i, name := range names {
+		report.Checks[name] = results[i]
+	}
+	for _, result := range report.Checks {
+		if result.Status != StatusOK {
+			report.Status = StatusUnavailable
+		}
+	}
+	return report
+}
+
+func (h *Health) run(ctx context.Context, check Check) CheckResult {
+	if h.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+	}
+
+	err := check(ctx)
+	if err != nil {
+		return CheckResult{Status: StatusUnavailable, Error: err.Error()}
+	}
+	return CheckResult{Status: StatusOK}
+}
+
+// Healthz answers the liveness probe. It only reports that the process is
+// serving requests; dependencies are not checked so that an outage does not
+// get the container restarted.
+func (h *Health) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, Report{Status: StatusOK})
+}
+
+// Readyz answers the readiness probe with 200 when ready and 503 otherwise.
+func (h *Health) Readyz(c *gin.Context) {
+	report := h.Status(c.Request.Context())
+	status := http.StatusOK
+	if report.Status != StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
+
+// KafkaCheck verifies that the broker accepts connections and serves
+// metadata for topic.
+func KafkaCheck(broker, topic string) Check {
+	return func(ctx context.Context) error {
+		conn, err := kafka.DialContext(ctx, "tcp", broker)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		deadline, ok := ctx.Deadline()
+		if ok {
+			err = conn.SetDeadline(deadline)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = conn.ReadPartitions(topic)
+		return err
+	}
+}
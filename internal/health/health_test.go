@@ -0,0 +1,128 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/health"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	m.Run()
+}
+
+func probe(t *testing.T, probes *health.Health, path string) (int, health.Report) {
+	t.Helper()
+
+	router := gin.New()
+	router.GET("/healthz", probes.Healthz)
+	router.GET("/readyz", probes.Readyz)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+	var report health.Report
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	return w.Code, report
+}
+
+func ok(context.Context) error { return nil }
+
+func TestReadyz_NotReadyUntilMarked(t *testing.T) {
+	probes := health.New(time.Second)
+	probes.AddCheck("postgres", ok)
+
+	code, report := probe(t, probes, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, health.StatusUnavailable, report.Status)
+	assert.Equal(t, health.StatusUnavailable, report.Checks["startup"].Status)
+	assert.Equal(t, health.StatusOK, report.Checks["postgres"].Status)
+
+	probes.MarkReady()
+
+	code, report = probe(t, probes, "/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, health.Report{
+		Status: health.StatusOK,
+		Checks: map[string]health.CheckResult{
+			"startup":  {Status: health.StatusOK},
+			"postgres": {Status: health.StatusOK},
+		},
+	}, report)
+}
+
+func TestReadyz_FailingDependency(t *testing.T) {
+	probes := health.New(time.Second)
+	probes.AddCheck("postgres", ok)
+	probes.AddCheck("kafka", func(context.Context) error {
+		return errors.New("connection refused")
+	})
+	probes.MarkReady()
+
+	code, report := probe(t, probes, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, health.StatusOK, report.Checks["postgres"].Status)
+	assert.Equal(t, health.CheckResult{Status: health.StatusUnavailable, Error: "connection refused"}, report.Checks["kafka"])
+}
+
+func TestReadyz_CheckTimeout(t *testing.T) {
+	probes := health.New(20 * time.Millisecond)
+	probes.AddCheck("postgres", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	probes.MarkReady()
+
+	start := time.Now()
+	code, report := probe(t, probes, "/readyz")
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks["postgres"].Error)
+}
+
+func TestReadyz_ShuttingDown(t *testing.T) {
+	probes := health.New(time.Second)
+	probes.MarkReady()
+	probes.MarkShuttingDown()
+
+	code, report := probe(t, probes, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, health.StatusUnavailable, report.Checks["shutdown"].Status)
+
+	code, report = probe(t, probes, "/healthz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, health.StatusOK, report.Status)
+}
+
+func TestHealthz_IgnoresDependencies(t *testing.T) {
+	probes := health.New(time.Second)
+	probes.AddCheck("postgres", func(context.Context) error {
+		return errors.New("down")
+	})
+
+	code, report := probe(t, probes, "/healthz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, health.Report{Status: health.StatusOK}, report)
+}
+
+func TestKafkaCheck_Unreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	assert.Error(t, health.KafkaCheck(addr, "orders")(ctx))
+}
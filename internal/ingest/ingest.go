@@ -0,0 +1,95 @@
+// Package ingest hands orders received over HTTP to the rest of the
+// service: either straight to storage or to the orders topic, where they are
+// processed like any other order.
+package ingest
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/cache"
+	"github.com/ArtemKVD/WB-TechL0/internal/config"
+	"github.com/ArtemKVD/WB-TechL0/internal/producer"
+	database "github.com/ArtemKVD/WB-TechL0/internal/storage"
+	"github.com/ArtemKVD/WB-TechL0/internal/transport"
+	"github.com/ArtemKVD/WB-TechL0/pkg/models"
+)
+
+// Ingestion modes, as used in configuration.
+const (
+	Store   = "store"
+	Publish = "publish"
+)
+
+// Sink accepts validated orders. Accept returns one error per order, or an
+// error for the whole batch if none of them could be handled.
+type Sink interface {
+	Accept(ctx context.Context, orders []models.Order) ([]error, error)
+}
+
+// New returns the sink selected by cfg.Ingest.Mode. A publishing sink sends
+// to cfg.Kafka.Topic through broker and has to be closed.
+func New(cfg *config.Config, storage database.OrderStorage, c cache.CacheService, broker transport.Broker) (Sink, error) {
+	switch cfg.Ingest.Mode {
+	case Store:
+		return NewStoreSink(storage, c), nil
+	case Publish:
+		return NewPublishSink(broker.Publisher(cfg.Kafka.Topic)), nil
+	default:
+		return nil, fmt.Errorf("unknown ingest mode %q", cfg.Ingest.Mode)
+	}
+}
+
+// StoreSink saves orders in one batch and caches those that were saved.
+type StoreSink struct {
+	storage database.OrderStorage
+	cache   cache.CacheService
+}
+
+func NewStoreSink(storage database.OrderStorage, c cache.CacheService) *StoreSink {
+	return &StoreSink{storage: storage, cache: c}
+}
+
+func (s *StoreSink) Accept(ctx context.Context, orders []models.Order) ([]error, error) {
+	results, err := s.storage.SaveOrders(ctx, orders)
+	if err != nil {
+		return nil, err
+	}
+	for i, order := range orders {
+		if results[i] == nil {
+			s.cache.Set(order)
+		}
+	}
+	return results, nil
+}
+
+// PublishSink publishes orders to the orders topic. The batch is published
+// as a whole, so it either succeeds or fails for every order.
+type PublishSink struct {
+	publisher transport.Publisher
+}
+
+func NewPublishSink(publisher transport.Publisher) *PublishSink {
+	return &PublishSink{publisher: publisher}
+}
+
+func (s *PublishSink) Accept(ctx context.Context, orders []models.Order) ([]error, error) {
+	messages := make([]transport.Message, len(orders))
+	for i, order := range orders {
+		message, err := producer.OrderMessage(order)
+		if err != nil {
+			return nil, err
+		}
+		messages[i] = message
+	}
+
+	err := s.publisher.Publish(ctx, messages...)
+	if err != nil {
+		return nil, err
+	}
+	return make([]error, len(orders)), nil
+}
+
+func (s *PublishSink) Close() error {
+	return s.publisher.Close()
+}
@@ -0,0 +1,116 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/logger"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+)
+
+// RunFunc runs a component until it is stopped or ctx is canceled. ctx is
+// only canceled when the shutdown deadline is exceeded, so a component
+// should treat it as a request to abort rather than to drain.
+type RunFunc func(ctx context.Context) error
+
+// StopFunc asks a component to stop gracefully. It may block until the
+// component is drained, but must give up when ctx is done.
+type StopFunc func(ctx context.Context) error
+
+type component struct {
+	name string
+	run  RunFunc
+	stop StopFunc
+	done chan struct{}
+}
+
+// Manager runs components concurrently and stops them in the order they
+// were added, waiting for each one to finish before stopping the next.
+type Manager struct {
+	components      []*component
+	shutdownTimeout time.Duration
+}
+
+func New(shutdownTimeout time.Duration) *Manager {
+	return &Manager{shutdownTimeout: shutdownTimeout}
+}
+
+// Add registers a component. Either function may be nil: a component
+// without run is only a shutdown hook, such as closing a connection.
+func (m *Manager) Add(name string, run RunFunc, stop StopFunc) {
+	m.components = append(m.components, &component{
+		name: name,
+		run:  run,
+		stop: stop,
+		done: make(chan struct{}),
+	})
+}
+
+// Run starts all components and blocks until ctx is canceled or one of
+// them fails, then shuts everything down within the shutdown timeout.
+// Components still running when the timeout expires are aborted.
+func (m *Manager) Run(ctx context.Context) error {
+	runCtx, abort := context.WithCancel(context.WithoutCancel(ctx))
+	defer abort()
+
+	group, failed := errgroup.WithContext(runCtx)
+	for _, c := range m.components {
+		if c.run == nil {
+			close(c.done)
+			continue
+		}
+
+		group.Go(func() error {
+			defer close(c.done)
+			err := c.run(runCtx)
+			if err != nil {
+				return fmt.Errorf("%s: %w", c.name, err)
+			}
+			return nil
+		})
+	}
+
+	select {
+	case <-ctx.Done():
+		logger.Log.Info("Shutdown requested")
+	case <-failed.Done():
+		logger.Log.Error("Component failed, shutting down")
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), m.shutdownTimeout)
+	defer cancel()
+
+	stopErr := m.shutdown(shutdownCtx, abort)
+	runErr := group.Wait()
+	return errors.Join(runErr, stopErr)
+}
+
+func (m *Manager) shutdown(ctx context.Context, abort context.CancelFunc) error {
+	var errs []error
+	for _, c := range m.components {
+		start := time.Now()
+		if c.stop != nil {
+			err := c.stop(ctx)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("stop %s: %w", c.name, err))
+			}
+		}
+
+		select {
+		case <-c.done:
+		case <-ctx.Done():
+			logger.Log.WithField("component", c.name).Error("Shutdown deadline exceeded, aborting")
+			abort()
+			<-c.done
+		}
+
+		logger.Log.WithFields(logrus.Fields{
+			"component": c.name,
+			"duration":  time.Since(start).String(),
+		}).Info("Component stopped")
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,145 @@
+package lifecycle_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/lifecycle"
+	"github.com/ArtemKVD/WB-TechL0/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	logger.Log.SetOutput(io.Discard)
+	m.Run()
+}
+
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) add(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) list() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.events...)
+}
+
+// worker runs until stopped and takes drain to finish once asked to stop.
+func worker(name string, events *recorder, drain time.Duration) (lifecycle.RunFunc, lifecycle.StopFunc) {
+	stop := make(chan struct{})
+	run := func(ctx context.Context) error {
+		select {
+		case <-stop:
+		case <-ctx.Done():
+			events.add(name + ":aborted")
+			return nil
+		}
+
+		select {
+		case <-time.After(drain):
+			events.add(name + ":drained")
+		case <-ctx.Done():
+			events.add(name + ":aborted")
+		}
+		return nil
+	}
+	return run, func(context.Context) error {
+		events.add(name + ":stop")
+		close(stop)
+		return nil
+	}
+}
+
+func runManager(t *testing.T, ctx context.Context, m *lifecycle.Manager) error {
+	t.Helper()
+
+	result := make(chan error, 1)
+	go func() {
+		result <- m.Run(ctx)
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("manager did not stop")
+		return nil
+	}
+}
+
+func TestManager_StopsInOrder(t *testing.T) {
+	events := &recorder{}
+	m := lifecycle.New(time.Second)
+
+	httpRun, httpStop := worker("http", events, 20*time.Millisecond)
+	consumerRun, consumerStop := worker("consumer", events, 10*time.Millisecond)
+	m.Add("http", httpRun, httpStop)
+	m.Add("consumer", consumerRun, consumerStop)
+	m.Add("db", nil, func(context.Context) error {
+		events.add("db:close")
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	require.NoError(t, runManager(t, ctx, m))
+	assert.Equal(t, []string{
+		"http:stop", "http:drained",
+		"consumer:stop", "consumer:drained",
+		"db:close",
+	}, events.list())
+}
+
+func TestManager_FailureStopsOthers(t *testing.T) {
+	events := &recorder{}
+	m := lifecycle.New(time.Second)
+
+	consumerRun, consumerStop := worker("consumer", events, 0)
+	m.Add("http", func(context.Context) error {
+		return errors.New("address already in use")
+	}, nil)
+	m.Add("consumer", consumerRun, consumerStop)
+	m.Add("db", nil, func(context.Context) error {
+		events.add("db:close")
+		return errors.New("close failed")
+	})
+
+	err := runManager(t, context.Background(), m)
+	assert.ErrorContains(t, err, "http: address already in use")
+	assert.ErrorContains(t, err, "stop db: close failed")
+	assert.Equal(t, []string{"consumer:stop", "consumer:drained", "db:close"}, events.list())
+}
+
+func TestManager_DeadlineAbortsSlowComponents(t *testing.T) {
+	events := &recorder{}
+	m := lifecycle.New(30 * time.Millisecond)
+
+	consumerRun, consumerStop := worker("consumer", events, time.Hour)
+	m.Add("consumer", consumerRun, consumerStop)
+	m.Add("db", nil, func(ctx context.Context) error {
+		events.add("db:close")
+		assert.Error(t, ctx.Err(), "the shutdown deadline is shared")
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	require.NoError(t, runManager(t, ctx, m))
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, []string{"consumer:stop", "consumer:aborted", "db:close"}, events.list())
+}
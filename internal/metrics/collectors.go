@@ -0,0 +1,125 @@
+package metrics
+
+import (
+	"sync"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/cache"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/segmentio/kafka-go"
+)
+
+// CacheStatser is implemented by cache.Cache.
+type CacheStatser interface {
+	Stats() cache.Stats
+}
+
+type cacheCollector struct {
+	source CacheStatser
+
+	hits      *prometheus.Desc
+	misses    *prometheus.Desc
+	evictions *prometheus.Desc
+	size      *prometheus.Desc
+}
+
+// NewCacheCollector exposes the cache counters, read on every scrape.
+func NewCacheCollector(source CacheStatser) prometheus.Collector {
+	return &cacheCollector{
+		source: source,
+		hits: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "cache", "hits_total"),
+			"Cache lookups that found a live order.", nil, nil),
+		misses: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "cache", "misses_total"),
+			"Cache lookups that found nothing or an expired order.", nil, nil),
+		evictions: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "cache", "evictions_total"),
+			"Orders removed from the cache, by reason.", []string{"reason"}, nil),
+		size: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "cache", "size"),
+			"Orders currently held in the cache.", nil, nil),
+	}
+}
+
+func (c *cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.hits
+	ch <- c.misses
+	ch <- c.evictions
+	ch <- c.size
+}
+
+func (c *cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.source.Stats()
+
+	ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(c.evictions, prometheus.CounterValue, float64(stats.Evictions), "capacity")
+	ch <- prometheus.MustNewConstMetric(c.evictions, prometheus.CounterValue, float64(stats.Expirations), "expired")
+	ch <- prometheus.MustNewConstMetric(c.size, prometheus.GaugeValue, float64(stats.Size))
+}
+
+// KafkaStatser is implemented by transport.KafkaSubscriber.
+type KafkaStatser interface {
+	Stats() kafka.ReaderStats
+}
+
+type kafkaCollector struct {
+	source KafkaStatser
+
+	// ReaderStats counters reset on every call, so totals are accumulated
+	// here. The collector must be the only caller of Stats.
+	mu         sync.Mutex
+	messages   int64
+	errors     int64
+	rebalances int64
+
+	lagDesc        *prometheus.Desc
+	messagesDesc   *prometheus.Desc
+	errorsDesc     *prometheus.Desc
+	rebalancesDesc *prometheus.Desc
+}
+
+// NewKafkaCollector exposes kafka.Reader statistics. For a consumer group
+// reader kafka-go reports partition "-1" and the lag of the most recently
+// read partition; per-partition lag is recorded by the consumer instead.
+func NewKafkaCollector(source KafkaStatser) prometheus.Collector {
+	labels := []string{"topic", "partition"}
+	return &kafkaCollector{
+		source: source,
+		lagDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "kafka_reader", "lag"),
+			"Reader lag as reported by kafka-go.", labels, nil),
+		messagesDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "kafka_reader", "messages_total"),
+			"Messages read from Kafka.", labels, nil),
+		errorsDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "kafka_reader", "errors_total"),
+			"Errors reported by the Kafka reader.", labels, nil),
+		rebalancesDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "kafka_reader", "rebalances_total"),
+			"Consumer group rebalances.", labels, nil),
+	}
+}
+
+func (c *kafkaCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.lagDesc
+	ch <- c.messagesDesc
+	ch <- c.errorsDesc
+	ch <- c.rebalancesDesc
+}
+
+func (c *kafkaCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.source.Stats()
+	c.messages += stats.Messages
+	c.errors += stats.Errors
+	c.rebalances += stats.Rebalances
+
+	labels := []string{stats.Topic, stats.Partition}
+	ch <- prometheus.MustNewConstMetric(c.lagDesc, prometheus.GaugeValue, float64(stats.Lag), labels...)
+	ch <- prometheus.MustNewConstMetric(c.messagesDesc, prometheus.CounterValue, float64(c.messages), labels...)
+	ch <- prometheus.MustNewConstMetric(c.errorsDesc, prometheus.CounterValue, float64(c.errors), labels...)
+	ch <- prometheus.MustNewConstMetric(c.rebalancesDesc, prometheus.CounterValue, float64(c.rebalances), labels...)
+}
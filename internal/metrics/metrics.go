@@ -0,0 +1,126 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "order_service"
+
+// Metrics holds the service's Prometheus collectors. All methods are safe to
+// call on a nil *Metrics, so components can be built without instrumentation.
+type Metrics struct {
+	gatherer prometheus.Gatherer
+
+	messagesConsumed   *prometheus.CounterVec
+	messagesFailed     *prometheus.CounterVec
+	processingDuration *prometheus.HistogramVec
+	partitionLag       *prometheus.GaugeVec
+
+	dbQueryDuration *prometheus.HistogramVec
+
+	httpRequests        *prometheus.CounterVec
+	httpRequestDuration *prometheus.HistogramVec
+}
+
+// New creates the service metrics and registers them in reg. Collectors for
+// other components (cache, Kafka reader, sql.DB) are registered separately.
+func New(reg *prometheus.Registry) *Metrics {
+	m := &Metrics{
+		gatherer: reg,
+		messagesConsumed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "consumer",
+			Name:      "messages_consumed_total",
+			Help:      "Messages processed, by topic.",
+		}, []string{"topic"}),
+		messagesFailed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "consumer",
+			Name:      "messages_failed_total",
+			Help:      "Message processing failures, by stage.",
+		}, []string{"stage"}),
+		processingDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "consumer",
+			Name:      "processing_duration_seconds",
+			Help:      "Time from fetching a message until it is processed, including time spent queued.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 14),
+		}, []string{"topic"}),
+		partitionLag: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "consumer",
+			Name:      "partition_lag",
+			Help:      "Messages behind the high water mark as of the last committed message, by partition.",
+		}, []string{"topic", "partition"}),
+		dbQueryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "db",
+			Name:      "query_duration_seconds",
+			Help:      "Duration of storage operations, by operation and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation", "status"}),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "HTTP requests, by method, route and status code.",
+		}, []string{"method", "route", "status"}),
+		httpRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "HTTP request latency, by method and route.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+	}
+
+	reg.MustRegister(
+		m.messagesConsumed,
+		m.messagesFailed,
+		m.processingDuration,
+		m.partitionLag,
+		m.dbQueryDuration,
+		m.httpRequests,
+		m.httpRequestDuration,
+	)
+	return m
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	if m == nil {
+		return http.NotFoundHandler()
+	}
+	return promhttp.HandlerFor(m.gatherer, promhttp.HandlerOpts{})
+}
+
+// MessageConsumed records a message that was fetched at start and has now
+// been processed.
+func (m *Metrics) MessageConsumed(topic string, start time.Time) {
+	if m == nil {
+		return
+	}
+	m.messagesConsumed.WithLabelValues(topic).Inc()
+	m.processingDuration.WithLabelValues(topic).Observe(time.Since(start).Seconds())
+}
+
+// MessageFailed records a processing failure at stage.
+func (m *Metrics) MessageFailed(stage string) {
+	if m == nil {
+		return
+	}
+	m.messagesFailed.WithLabelValues(stage).Inc()
+}
+
+// SetPartitionLag records how far the consumer is behind on a partition.
+func (m *Metrics) SetPartitionLag(topic string, partition int, lag int64) {
+	if m == nil {
+		return
+	}
+	m.partitionLag.WithLabelValues(topic, strconv.Itoa(partition)).Set(float64(lag))
+}
@@ -0,0 +1,216 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/cache"
+	"github.com/ArtemKVD/WB-TechL0/internal/config"
+	"github.com/ArtemKVD/WB-TechL0/internal/logger"
+	"github.com/ArtemKVD/WB-TechL0/internal/metrics"
+	"github.com/ArtemKVD/WB-TechL0/internal/mocks"
+	database "github.com/ArtemKVD/WB-TechL0/internal/storage"
+	"github.com/ArtemKVD/WB-TechL0/pkg/models"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	logger.Log.SetOutput(io.Discard)
+	logger.Log.SetLevel(logrus.WarnLevel)
+	m.Run()
+}
+
+func TestMetrics_NilIsNoop(t *testing.T) {
+	var m *metrics.Metrics
+
+	assert.NotPanics(t, func() {
+		m.MessageConsumed("orders", time.Now())
+		m.MessageFailed("decode")
+		m.SetPartitionLag("orders", 0, 1)
+	})
+
+	router := gin.New()
+	router.Use(m.Middleware())
+	router.GET("/ping", func(c *gin.Context) { c.Status(http.StatusOK) })
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ping", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	storage := mocks.NewMockOrderStorage(gomock.NewController(t))
+	assert.Same(t, storage, m.InstrumentStorage(storage))
+}
+
+func TestMiddleware_LabelsByRouteTemplate(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	m := metrics.New(registry)
+
+	router := gin.New()
+	router.Use(m.Middleware())
+	router.GET("/api/v1/orders/:uid", func(c *gin.Context) {
+		if c.Param("uid") == "missing" {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+
+	for _, path := range []string{"/api/v1/orders/a", "/api/v1/orders/b", "/api/v1/orders/missing", "/nope"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	err := testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP order_service_http_requests_total HTTP requests, by method, route and status code.
+# TYPE order_service_http_requests_total counter
+order_service_http_requests_total{method="GET",route="/api/v1/orders/:uid",status="200"} 2
+order_service_http_requests_total{method="GET",route="/api/v1/orders/:uid",status="404"} 1
+order_service_http_requests_total{method="GET",route="unmatched",status="404"} 1
+`), "order_service_http_requests_total")
+	assert.NoError(t, err)
+	count, err := testutil.GatherAndCount(registry, "order_service_http_request_duration_seconds")
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
+
+func TestHandler_ServesRegistry(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	m := metrics.New(registry)
+	m.MessageFailed("persist")
+
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `order_service_consumer_messages_failed_total{stage="persist"} 1`)
+}
+
+func TestInstrumentStorage_RecordsStatus(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	registry := prometheus.NewRegistry()
+	m := metrics.New(registry)
+
+	mockStorage := mocks.NewMockOrderStorage(ctrl)
+	mockStorage.EXPECT().GetOrder(gomock.Any(), "a").Return(models.Order{OrderUID: "a"}, nil)
+	mockStorage.EXPECT().GetOrder(gomock.Any(), "b").Return(models.Order{}, database.ErrNotFound)
+	mockStorage.EXPECT().SaveOrder(gomock.Any(), gomock.Any()).Return(errors.New("connection refused"))
+	mockStorage.EXPECT().Close().Return(nil)
+
+	storage := m.InstrumentStorage(mockStorage)
+	ctx := context.Background()
+
+	order, err := storage.GetOrder(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, "a", order.OrderUID)
+	_, err = storage.GetOrder(ctx, "b")
+	assert.ErrorIs(t, err, database.ErrNotFound)
+	assert.Error(t, storage.SaveOrder(ctx, models.Order{}))
+	assert.NoError(t, storage.Close())
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+
+	counts := map[string]uint64{}
+	for _, family := range families {
+		if family.GetName() != "order_service_db_query_duration_seconds" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			counts[labels["operation"]+"/"+labels["status"]] = metric.GetHistogram().GetSampleCount()
+		}
+	}
+	assert.Equal(t, map[string]uint64{
+		"get_order/ok":        1,
+		"get_order/not_found": 1,
+		"save_order/error":    1,
+	}, counts)
+}
+
+func TestCacheCollector(t *testing.T) {
+	c := cache.NewCache(config.CacheConfig{MaxSize: 1, TTL: time.Hour})
+	c.Set(models.Order{OrderUID: "a"})
+	c.Set(models.Order{OrderUID: "b"})
+	c.Get("b")
+	c.Get("a")
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(metrics.NewCacheCollector(c))
+
+	err := testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP order_service_cache_evictions_total Orders removed from the cache, by reason.
+# TYPE order_service_cache_evictions_total counter
+order_service_cache_evictions_total{reason="capacity"} 1
+order_service_cache_evictions_total{reason="expired"} 0
+# HELP order_service_cache_hits_total Cache lookups that found a live order.
+# TYPE order_service_cache_hits_total counter
+order_service_cache_hits_total 1
+# HELP order_service_cache_misses_total Cache lookups that found nothing or an expired order.
+# TYPE order_service_cache_misses_total counter
+order_service_cache_misses_total 1
+# HELP order_service_cache_size Orders currently held in the cache.
+# TYPE order_service_cache_size gauge
+order_service_cache_size 1
+`))
+	assert.NoError(t, err)
+}
+
+// fakeReaderStats returns counters that reset on every call, like
+// kafka.Reader.Stats.
+type fakeReaderStats struct {
+	snapshots []kafka.ReaderStats
+}
+
+func (f *fakeReaderStats) Stats() kafka.ReaderStats {
+	stats := f.snapshots[0]
+	if len(f.snapshots) > 1 {
+		f.snapshots = f.snapshots[1:]
+	}
+	return stats
+}
+
+func TestKafkaCollector_AccumulatesCounters(t *testing.T) {
+	source := &fakeReaderStats{snapshots: []kafka.ReaderStats{
+		{Topic: "orders", Partition: "-1", Messages: 5, Errors: 1, Lag: 40},
+		{Topic: "orders", Partition: "-1", Messages: 3, Rebalances: 1, Lag: 12},
+	}}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(metrics.NewKafkaCollector(source))
+
+	_, err := registry.Gather()
+	require.NoError(t, err)
+
+	err = testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP order_service_kafka_reader_errors_total Errors reported by the Kafka reader.
+# TYPE order_service_kafka_reader_errors_total counter
+order_service_kafka_reader_errors_total{partition="-1",topic="orders"} 1
+# HELP order_service_kafka_reader_lag Reader lag as reported by kafka-go.
+# TYPE order_service_kafka_reader_lag gauge
+order_service_kafka_reader_lag{partition="-1",topic="orders"} 12
+# HELP order_service_kafka_reader_messages_total Messages read from Kafka.
+# TYPE order_service_kafka_reader_messages_total counter
+order_service_kafka_reader_messages_total{partition="-1",topic="orders"} 8
+# HELP order_service_kafka_reader_rebalances_total Consumer group rebalances.
+# TYPE order_service_kafka_reader_rebalances_total counter
+order_service_kafka_reader_rebalances_total{partition="-1",topic="orders"} 1
+`))
+	assert.NoError(t, err)
+}
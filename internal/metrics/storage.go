@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	database "github.com/ArtemKVD/WB-TechL0/internal/storage"
+	"github.com/ArtemKVD/WB-TechL0/pkg/models"
+)
+
+// InstrumentedStorage records the latency and outcome of each storage
+// operation. Connection management is passed through untouched.
+type InstrumentedStorage struct {
+	database.OrderStorage
+	metrics *Metrics
+}
+
+// InstrumentStorage wraps storage so its operations are timed. With nil
+// metrics the storage is returned as is.
+func (m *Metrics) InstrumentStorage(storage database.OrderStorage) database.OrderStorage {
+	if m == nil {
+		return storage
+	}
+	return &InstrumentedStorage{OrderStorage: storage, metrics: m}
+}
+
+func (s *InstrumentedStorage) SaveOrder(ctx context.Context, order models.Order) error {
+	start := time.Now()
+	err := s.OrderStorage.SaveOrder(ctx, order)
+	s.observe("save_order", start, err)
+	return err
+}
+
+func (s *InstrumentedStorage) SaveOrders(ctx context.Context, orders []models.Order) ([]error, error) {
+	start := time.Now()
+	results, err := s.OrderStorage.SaveOrders(ctx, orders)
+	s.observe("save_orders", start, err)
+	return results, err
+}
+
+func (s *InstrumentedStorage) GetOrder(ctx context.Context, orderUID string) (models.Order, error) {
+	start := time.Now()
+	order, err := s.OrderStorage.GetOrder(ctx, orderUID)
+	s.observe("get_order", start, err)
+	return order, err
+}
+
+func (s *InstrumentedStorage) LoadOrdersFromDB(ctx context.Context) (map[string]models.Order, error) {
+	start := time.Now()
+	orders, err := s.OrderStorage.LoadOrdersFromDB(ctx)
+	s.observe("load_orders", start, err)
+	return orders, err
+}
+
+func (s *InstrumentedStorage) ListOrders(ctx context.Context, filter database.OrderFilter) (database.OrderPage, error) {
+	start := time.Now()
+	page, err := s.OrderStorage.ListOrders(ctx, filter)
+	s.observe("list_orders", start, err)
+	return page, err
+}
+
+func (s *InstrumentedStorage) observe(operation string, start time.Time, err error) {
+	s.metrics.dbQueryDuration.WithLabelValues(operation, queryStatus(err)).Observe(time.Since(start).Seconds())
+}
+
+func queryStatus(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, database.ErrNotFound):
+		return "not_found"
+	case errors.Is(err, database.ErrOrderConflict):
+		return "conflict"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	default:
+		return "error"
+	}
+}
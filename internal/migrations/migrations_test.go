@@ -0,0 +1,94 @@
+package migrations
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad_Embedded(t *testing.T) {
+	migrations, err := load(files, "sql")
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+
+	for i, migration := range migrations {
+		assert.Equal(t, i+1, migration.Version)
+		assert.NotEmpty(t, migration.Up)
+		assert.NotEmpty(t, migration.Down)
+	}
+	assert.Contains(t, migrations[1].Up, "ON DELETE CASCADE")
+}
+
+func TestLoad(t *testing.T) {
+	file := func(content string) *fstest.MapFile {
+		return &fstest.MapFile{Data: []byte(content)}
+	}
+
+	tests := []struct {
+		name    string
+		fsys    fstest.MapFS
+		want    []Migration
+		wantErr string
+	}{
+		{
+			name: "sorted by version",
+			fsys: fstest.MapFS{
+				"sql/0002_second.up.sql":   file("up2"),
+				"sql/0002_second.down.sql": file("down2"),
+				"sql/0001_first.up.sql":    file("up1"),
+				"sql/0001_first.down.sql":  file("down1"),
+			},
+			want: []Migration{
+				{Version: 1, Name: "first", Up: "up1", Down: "down1"},
+				{Version: 2, Name: "second", Up: "up2", Down: "down2"},
+			},
+		},
+		{
+			name: "missing down",
+			fsys: fstest.MapFS{
+				"sql/0001_first.up.sql": file("up1"),
+			},
+			wantErr: "needs both up and down",
+		},
+		{
+			name: "gap in versions",
+			fsys: fstest.MapFS{
+				"sql/0001_first.up.sql":   file("up1"),
+				"sql/0001_first.down.sql": file("down1"),
+				"sql/0003_third.up.sql":   file("up3"),
+				"sql/0003_third.down.sql": file("down3"),
+			},
+			wantErr: "sequential",
+		},
+		{
+			name: "bad file name",
+			fsys: fstest.MapFS{
+				"sql/first.sql": file("up1"),
+			},
+			wantErr: "invalid migration file name",
+		},
+		{
+			name: "conflicting names",
+			fsys: fstest.MapFS{
+				"sql/0001_first.up.sql":   file("up1"),
+				"sql/0001_other.down.sql": file("down1"),
+			},
+			wantErr: "conflicting names",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := load(tt.fsys, "sql")
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, migrations)
+		})
+	}
+}
@@ -1,3 +1,5 @@
+-- Baseline schema. Written to be a no-op on databases that were created
+-- from the former init.sql, so existing deployments can adopt migrations.
 CREATE TABLE IF NOT EXISTS orders (
     order_uid TEXT PRIMARY KEY,
     track_number TEXT,
@@ -9,7 +11,8 @@ CREATE TABLE IF NOT EXISTS orders (
     shardkey TEXT,
     sm_id INTEGER,
     date_created TIMESTAMP,
-    oof_shard TEXT
+    oof_shard TEXT,
+    payload_hash TEXT
 );
 
 CREATE TABLE IF NOT EXISTS delivery (
@@ -52,4 +55,19 @@ CREATE TABLE IF NOT EXISTS items (
     nm_id INTEGER,
     brand TEXT,
     status INTEGER
-);
\ No newline at end of file
+);
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS payload_hash TEXT;
+
+-- Databases created by init.sql could hold repeated rows for the same
+-- order; keep the latest one so the unique indexes can be built.
+DELETE FROM delivery older
+USING delivery newer
+WHERE older.order_uid = newer.order_uid AND older.id < newer.id;
+
+DELETE FROM items older
+USING items newer
+WHERE older.order_uid = newer.order_uid AND older.rid = newer.rid AND older.id < newer.id;
+
+CREATE UNIQUE INDEX IF NOT EXISTS delivery_order_uid_key ON delivery (order_uid);
+CREATE UNIQUE INDEX IF NOT EXISTS items_order_uid_rid_key ON items (order_uid, rid);
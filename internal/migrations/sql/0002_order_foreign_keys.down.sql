@@ -0,0 +1,6 @@
+ALTER TABLE items DROP CONSTRAINT IF EXISTS items_order_uid_fkey;
+ALTER TABLE payment DROP CONSTRAINT IF EXISTS payment_order_uid_fkey;
+ALTER TABLE delivery DROP CONSTRAINT IF EXISTS delivery_order_uid_fkey;
+
+ALTER TABLE items ALTER COLUMN order_uid DROP NOT NULL;
+ALTER TABLE delivery ALTER COLUMN order_uid DROP NOT NULL;
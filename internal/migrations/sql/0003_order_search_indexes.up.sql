@@ -0,0 +1,8 @@
+CREATE INDEX IF NOT EXISTS orders_date_created_order_uid_idx ON orders (date_created DESC, order_uid DESC);
+CREATE INDEX IF NOT EXISTS orders_customer_id_idx ON orders (customer_id);
+CREATE INDEX IF NOT EXISTS orders_track_number_idx ON orders (track_number);
+CREATE INDEX IF NOT EXISTS orders_delivery_service_idx ON orders (delivery_service);
+CREATE INDEX IF NOT EXISTS payment_currency_idx ON payment (currency);
+CREATE INDEX IF NOT EXISTS payment_provider_idx ON payment (provider);
+CREATE INDEX IF NOT EXISTS items_brand_idx ON items (brand);
+CREATE INDEX IF NOT EXISTS items_nm_id_idx ON items (nm_id);
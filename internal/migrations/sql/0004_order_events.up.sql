@@ -0,0 +1,15 @@
+-- Transactional outbox: events are written in the same transaction as the
+-- order and published to Kafka by the relay, which then sets sent_at.
+CREATE TABLE IF NOT EXISTS order_events (
+    id BIGSERIAL PRIMARY KEY,
+    event_id UUID NOT NULL UNIQUE,
+    event_type TEXT NOT NULL,
+    schema_version INTEGER NOT NULL,
+    order_uid TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    sent_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS order_events_pending_idx ON order_events (id) WHERE sent_at IS NULL;
+CREATE INDEX IF NOT EXISTS order_events_sent_at_idx ON order_events (sent_at) WHERE sent_at IS NOT NULL;
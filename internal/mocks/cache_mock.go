@@ -5,6 +5,7 @@
 package mocks
 
 import (
+	context "context"
 	reflect "reflect"
 
 	database "github.com/ArtemKVD/WB-TechL0/internal/storage"
@@ -75,17 +76,17 @@ func (mr *MockCacheServiceMockRecorder) Get(arg0 interface{}) *gomock.Call {
 }
 
 // LoadCacheFromDB mocks base method.
-func (m *MockCacheService) LoadCacheFromDB(arg0 database.OrderStorage) error {
+func (m *MockCacheService) LoadCacheFromDB(arg0 context.Context, arg1 database.OrderStorage) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "LoadCacheFromDB", arg0)
+	ret := m.ctrl.Call(m, "LoadCacheFromDB", arg0, arg1)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // LoadCacheFromDB indicates an expected call of LoadCacheFromDB.
-func (mr *MockCacheServiceMockRecorder) LoadCacheFromDB(arg0 interface{}) *gomock.Call {
+func (mr *MockCacheServiceMockRecorder) LoadCacheFromDB(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadCacheFromDB", reflect.TypeOf((*MockCacheService)(nil).LoadCacheFromDB), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadCacheFromDB", reflect.TypeOf((*MockCacheService)(nil).LoadCacheFromDB), arg0, arg1)
 }
 
 // Set mocks base method.
@@ -5,8 +5,10 @@
 package mocks
 
 import (
+	context "context"
 	reflect "reflect"
 
+	database "github.com/ArtemKVD/WB-TechL0/internal/storage"
 	models "github.com/ArtemKVD/WB-TechL0/pkg/models"
 	gomock "github.com/golang/mock/gomock"
 )
@@ -49,17 +51,17 @@ func (mr *MockOrderStorageMockRecorder) Close() *gomock.Call {
 }
 
 // Connect mocks base method.
-func (m *MockOrderStorage) Connect() error {
+func (m *MockOrderStorage) Connect(arg0 context.Context) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Connect")
+	ret := m.ctrl.Call(m, "Connect", arg0)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Connect indicates an expected call of Connect.
-func (mr *MockOrderStorageMockRecorder) Connect() *gomock.Call {
+func (mr *MockOrderStorageMockRecorder) Connect(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Connect", reflect.TypeOf((*MockOrderStorage)(nil).Connect))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Connect", reflect.TypeOf((*MockOrderStorage)(nil).Connect), arg0)
 }
 
 // GetConnString mocks base method.
@@ -77,45 +79,75 @@ func (mr *MockOrderStorageMockRecorder) GetConnString() *gomock.Call {
 }
 
 // GetOrder mocks base method.
-func (m *MockOrderStorage) GetOrder(arg0 string) (models.Order, error) {
+func (m *MockOrderStorage) GetOrder(arg0 context.Context, arg1 string) (models.Order, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetOrder", arg0)
+	ret := m.ctrl.Call(m, "GetOrder", arg0, arg1)
 	ret0, _ := ret[0].(models.Order)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetOrder indicates an expected call of GetOrder.
-func (mr *MockOrderStorageMockRecorder) GetOrder(arg0 interface{}) *gomock.Call {
+func (mr *MockOrderStorageMockRecorder) GetOrder(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrder", reflect.TypeOf((*MockOrderStorage)(nil).GetOrder), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrder", reflect.TypeOf((*MockOrderStorage)(nil).GetOrder), arg0, arg1)
+}
+
+// ListOrders mocks base method.
+func (m *MockOrderStorage) ListOrders(arg0 context.Context, arg1 database.OrderFilter) (database.OrderPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListOrders", arg0, arg1)
+	ret0, _ := ret[0].(database.OrderPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListOrders indicates an expected call of ListOrders.
+func (mr *MockOrderStorageMockRecorder) ListOrders(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListOrders", reflect.TypeOf((*MockOrderStorage)(nil).ListOrders), arg0, arg1)
 }
 
 // LoadOrdersFromDB mocks base method.
-func (m *MockOrderStorage) LoadOrdersFromDB() (map[string]models.Order, error) {
+func (m *MockOrderStorage) LoadOrdersFromDB(arg0 context.Context) (map[string]models.Order, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "LoadOrdersFromDB")
+	ret := m.ctrl.Call(m, "LoadOrdersFromDB", arg0)
 	ret0, _ := ret[0].(map[string]models.Order)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // LoadOrdersFromDB indicates an expected call of LoadOrdersFromDB.
-func (mr *MockOrderStorageMockRecorder) LoadOrdersFromDB() *gomock.Call {
+func (mr *MockOrderStorageMockRecorder) LoadOrdersFromDB(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadOrdersFromDB", reflect.TypeOf((*MockOrderStorage)(nil).LoadOrdersFromDB))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadOrdersFromDB", reflect.TypeOf((*MockOrderStorage)(nil).LoadOrdersFromDB), arg0)
 }
 
 // SaveOrder mocks base method.
-func (m *MockOrderStorage) SaveOrder(arg0 models.Order) error {
+func (m *MockOrderStorage) SaveOrder(arg0 context.Context, arg1 models.Order) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SaveOrder", arg0)
+	ret := m.ctrl.Call(m, "SaveOrder", arg0, arg1)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // SaveOrder indicates an expected call of SaveOrder.
-func (mr *MockOrderStorageMockRecorder) SaveOrder(arg0 interface{}) *gomock.Call {
+func (mr *MockOrderStorageMockRecorder) SaveOrder(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveOrder", reflect.TypeOf((*MockOrderStorage)(nil).SaveOrder), arg0, arg1)
+}
+
+// SaveOrders mocks base method.
+func (m *MockOrderStorage) SaveOrders(arg0 context.Context, arg1 []models.Order) ([]error, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveOrders", arg0, arg1)
+	ret0, _ := ret[0].([]error)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SaveOrders indicates an expected call of SaveOrders.
+func (mr *MockOrderStorageMockRecorder) SaveOrders(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveOrder", reflect.TypeOf((*MockOrderStorage)(nil).SaveOrder), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveOrders", reflect.TypeOf((*MockOrderStorage)(nil).SaveOrders), arg0, arg1)
 }
@@ -0,0 +1,155 @@
+// Package outbox publishes the events recorded in the order_events table.
+package outbox
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/config"
+	"github.com/ArtemKVD/WB-TechL0/internal/logger"
+	database "github.com/ArtemKVD/WB-TechL0/internal/storage"
+	"github.com/ArtemKVD/WB-TechL0/internal/transport"
+	"github.com/ArtemKVD/WB-TechL0/pkg/events"
+	"github.com/sirupsen/logrus"
+)
+
+// Store is the part of the database the relay works on.
+type Store interface {
+	PublishPendingEvents(ctx context.Context, limit int, publish func(context.Context, []database.OutboxEvent) error) (int, error)
+	DeleteSentEvents(ctx context.Context, sentBefore time.Time) (int64, error)
+}
+
+// Relay polls the outbox for unsent events, publishes them and marks them
+// sent. An event is marked only after the publisher accepted it, so it is
+// published at least once, and possibly again after a failure in between.
+type Relay struct {
+	store           Store
+	publisher       transport.Publisher
+	batchSize       int
+	pollInterval    time.Duration
+	retention       time.Duration
+	cleanupInterval time.Duration
+
+	stopOnce sync.Once
+	stopping chan struct{}
+}
+
+func NewRelay(store Store, publisher transport.Publisher, cfg config.OutboxConfig) *Relay {
+	r := &Relay{
+		store:           store,
+		publisher:       publisher,
+		batchSize:       cfg.BatchSize,
+		pollInterval:    cfg.PollInterval,
+		retention:       cfg.Retention,
+		cleanupInterval: cfg.CleanupInterval,
+		stopping:        make(chan struct{}),
+	}
+	if r.batchSize < 1 {
+		r.batchSize = 1
+	}
+	if r.pollInterval <= 0 {
+		r.pollInterval = time.Second
+	}
+	return r
+}
+
+// Run publishes pending events every poll interval and deletes old sent
+// events every cleanup interval, until Stop is called or ctx is canceled.
+// A non-positive cleanup interval or retention disables the cleanup.
+func (r *Relay) Run(ctx context.Context) {
+	poll := time.NewTicker(r.pollInterval)
+	defer poll.Stop()
+
+	var cleanup <-chan time.Time
+	if r.cleanupInterval > 0 && r.retention > 0 {
+		ticker := time.NewTicker(r.cleanupInterval)
+		defer ticker.Stop()
+		cleanup = ticker.C
+	}
+
+	for {
+		r.publishPending(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.stopping:
+			return
+		case <-poll.C:
+		case <-cleanup:
+			r.cleanup(ctx)
+		}
+	}
+}
+
+// Stop makes Run return once the current batch is published. It does not
+// wait for Run to return.
+func (r *Relay) Stop() {
+	r.stopOnce.Do(func() {
+		close(r.stopping)
+	})
+}
+
+// publishPending publishes batches until the outbox is drained or
+// publishing fails.
+func (r *Relay) publishPending(ctx context.Context) {
+	for {
+		published, err := r.store.PublishPendingEvents(ctx, r.batchSize, r.publish)
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.Log.Error("Publishing order events failed: ", err)
+			}
+			return
+		}
+		if published > 0 {
+			logger.Log.WithField("events", published).Info("Order events published")
+		}
+		if published < r.batchSize {
+			return
+		}
+
+		select {
+		case <-r.stopping:
+			return
+		default:
+		}
+	}
+}
+
+func (r *Relay) publish(ctx context.Context, pending []database.OutboxEvent) error {
+	messages := make([]transport.Message, len(pending))
+	for i, event := range pending {
+		messages[i] = eventMessage(event)
+	}
+	return r.publisher.Publish(ctx, messages...)
+}
+
+func (r *Relay) cleanup(ctx context.Context) {
+	deleted, err := r.store.DeleteSentEvents(ctx, time.Now().Add(-r.retention))
+	if err != nil {
+		logger.Log.Error("Deleting sent order events failed: ", err)
+		return
+	}
+	if deleted > 0 {
+		logger.Log.WithFields(logrus.Fields{
+			"events":    deleted,
+			"retention": r.retention.String(),
+		}).Info("Sent order events deleted")
+	}
+}
+
+// eventMessage keys the event by order_uid, so events of one order stay in
+// one partition and keep their order.
+func eventMessage(event database.OutboxEvent) transport.Message {
+	return transport.Message{
+		Key:   []byte(event.OrderUID),
+		Value: event.Payload,
+		Headers: []transport.Header{
+			{Key: events.HeaderEventID, Value: []byte(event.EventID)},
+			{Key: events.HeaderEventType, Value: []byte(event.EventType)},
+			{Key: events.HeaderSchemaVersion, Value: []byte(strconv.Itoa(event.SchemaVersion))},
+		},
+	}
+}
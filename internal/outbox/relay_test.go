@@ -0,0 +1,159 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/config"
+	"github.com/ArtemKVD/WB-TechL0/internal/outbox"
+	database "github.com/ArtemKVD/WB-TechL0/internal/storage"
+	"github.com/ArtemKVD/WB-TechL0/internal/transport"
+	"github.com/ArtemKVD/WB-TechL0/pkg/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore is an outbox table in memory. Events are sent in id order.
+type memoryStore struct {
+	mu         sync.Mutex
+	pending    []database.OutboxEvent
+	sent       []database.OutboxEvent
+	sentBefore []time.Time
+}
+
+func (s *memoryStore) PublishPendingEvents(ctx context.Context, limit int, publish func(context.Context, []database.OutboxEvent) error) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	batch := s.pending[:min(limit, len(s.pending))]
+	if len(batch) == 0 {
+		return 0, nil
+	}
+	err := publish(ctx, batch)
+	if err != nil {
+		return 0, err
+	}
+
+	s.sent = append(s.sent, batch...)
+	s.pending = s.pending[len(batch):]
+	return len(batch), nil
+}
+
+func (s *memoryStore) DeleteSentEvents(ctx context.Context, sentBefore time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sentBefore = append(s.sentBefore, sentBefore)
+	return 0, nil
+}
+
+func (s *memoryStore) pendingCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pending)
+}
+
+type fakePublisher struct {
+	mu       sync.Mutex
+	messages []transport.Message
+	failures int
+}
+
+func (w *fakePublisher) Publish(ctx context.Context, msgs ...transport.Message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.failures > 0 {
+		w.failures--
+		return errors.New("broker not available")
+	}
+	w.messages = append(w.messages, msgs...)
+	return nil
+}
+
+func (w *fakePublisher) Close() error {
+	return nil
+}
+
+func outboxEvents(n int) []database.OutboxEvent {
+	pending := make([]database.OutboxEvent, n)
+	for i := range pending {
+		pending[i] = database.OutboxEvent{
+			ID:            int64(i + 1),
+			EventID:       fmt.Sprintf("event%d", i),
+			EventType:     events.TypeOrderAccepted,
+			SchemaVersion: events.OrderAcceptedVersion,
+			OrderUID:      fmt.Sprintf("order%d", i),
+			Payload:       []byte(fmt.Sprintf(`{"order_uid":"order%d"}`, i)),
+		}
+	}
+	return pending
+}
+
+func runRelay(t *testing.T, relay *outbox.Relay, until func() bool) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		relay.Run(context.Background())
+		close(done)
+	}()
+
+	require.Eventually(t, until, 2*time.Second, time.Millisecond)
+	relay.Stop()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("relay did not stop")
+	}
+}
+
+func TestRelay_PublishesPendingEvents(t *testing.T) {
+	store := &memoryStore{pending: outboxEvents(25)}
+	publisher := &fakePublisher{}
+	relay := outbox.NewRelay(store, publisher, config.OutboxConfig{BatchSize: 10, PollInterval: time.Hour})
+
+	runRelay(t, relay, func() bool { return store.pendingCount() == 0 })
+
+	require.Len(t, publisher.messages, 25)
+	message := publisher.messages[3]
+	assert.Equal(t, []byte("order3"), message.Key)
+	assert.Equal(t, []byte(`{"order_uid":"order3"}`), message.Value)
+	assert.Equal(t, []transport.Header{
+		{Key: events.HeaderEventID, Value: []byte("event3")},
+		{Key: events.HeaderEventType, Value: []byte(events.TypeOrderAccepted)},
+		{Key: events.HeaderSchemaVersion, Value: []byte("1")},
+	}, message.Headers)
+}
+
+func TestRelay_RetriesFailedPublish(t *testing.T) {
+	store := &memoryStore{pending: outboxEvents(3)}
+	publisher := &fakePublisher{failures: 2}
+	relay := outbox.NewRelay(store, publisher, config.OutboxConfig{BatchSize: 10, PollInterval: time.Millisecond})
+
+	runRelay(t, relay, func() bool { return store.pendingCount() == 0 })
+
+	require.Len(t, publisher.messages, 3)
+	assert.Len(t, store.sent, 3)
+}
+
+func TestRelay_DeletesOldSentEvents(t *testing.T) {
+	store := &memoryStore{}
+	relay := outbox.NewRelay(store, &fakePublisher{}, config.OutboxConfig{
+		BatchSize:       10,
+		PollInterval:    time.Hour,
+		Retention:       time.Hour,
+		CleanupInterval: time.Millisecond,
+	})
+
+	start := time.Now()
+	runRelay(t, relay, func() bool {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		return len(store.sentBefore) > 0
+	})
+
+	cutoff := store.sentBefore[0]
+	assert.WithinDuration(t, start.Add(-time.Hour), cutoff, time.Second)
+}
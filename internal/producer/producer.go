@@ -0,0 +1,50 @@
+// Package producer publishes orders to the orders topic.
+package producer
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/logger"
+	"github.com/ArtemKVD/WB-TechL0/internal/transport"
+	"github.com/ArtemKVD/WB-TechL0/pkg/models"
+)
+
+// OrderMessage encodes order as JSON. Keying by order_uid keeps every
+// version of an order on one partition, which the consumer relies on for
+// per-order ordering.
+func OrderMessage(order models.Order) (transport.Message, error) {
+	value, err := json.Marshal(order)
+	if err != nil {
+		return transport.Message{}, err
+	}
+	return transport.Message{Key: []byte(order.OrderUID), Value: value}, nil
+}
+
+// Send publishes orders one at a time, logging and skipping those that
+// fail, until ctx is canceled. It returns the number of orders sent.
+func Send(ctx context.Context, publisher transport.Publisher, orders []models.Order) int {
+	sent := 0
+	for _, order := range orders {
+		if ctx.Err() != nil {
+			logger.Log.Info("Shutting down producer")
+			return sent
+		}
+
+		message, err := OrderMessage(order)
+		if err != nil {
+			logger.Log.Error("marshal order error: ", err)
+			continue
+		}
+
+		err = publisher.Publish(ctx, message)
+		if err != nil {
+			logger.Log.Error("write message error: ", err)
+			continue
+		}
+		sent++
+
+		logger.Log.WithField("order_uid", order.OrderUID).Info("order written to topic")
+	}
+	return sent
+}
@@ -0,0 +1,116 @@
+// Package rules keeps the declarative validation rules in sync with their
+// file, so they can be changed without restarting the service.
+package rules
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/config"
+	"github.com/ArtemKVD/WB-TechL0/internal/logger"
+	"github.com/ArtemKVD/WB-TechL0/pkg/validator"
+	"github.com/sirupsen/logrus"
+)
+
+// Reloader applies the rules file to the validator and re-applies it when
+// its content changes.
+type Reloader struct {
+	path     string
+	interval time.Duration
+	loaded   [sha256.Size]byte
+	// failed is the content that last failed to parse and readErr the
+	// last error reading the file, so a broken or missing file is reported
+	// once rather than on every check.
+	failed  [sha256.Size]byte
+	readErr string
+
+	stopOnce sync.Once
+	stopping chan struct{}
+}
+
+func NewReloader(cfg config.ValidationConfig) *Reloader {
+	r := &Reloader{
+		path:     cfg.RulesPath,
+		interval: cfg.ReloadInterval,
+		stopping: make(chan struct{}),
+	}
+	if r.interval <= 0 {
+		r.interval = 10 * time.Second
+	}
+	return r
+}
+
+// Load applies the rules file. It is meant for startup, where an invalid
+// file should stop the service rather than leave it running without rules.
+func (r *Reloader) Load() error {
+	return r.reload()
+}
+
+// Run checks the file every interval until Stop is called or ctx is
+// canceled. A file that became invalid is logged and the rules in use are
+// kept until it is fixed.
+func (r *Reloader) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.stopping:
+			return
+		case <-ticker.C:
+		}
+
+		err := r.reload()
+		if err != nil {
+			logger.Log.Error("Reloading validation rules failed, keeping current rules: ", err)
+		}
+	}
+}
+
+// Stop makes Run return. It does not wait for Run to return.
+func (r *Reloader) Stop() {
+	r.stopOnce.Do(func() {
+		close(r.stopping)
+	})
+}
+
+// reload applies the file if its content changed since it was last
+// applied.
+func (r *Reloader) reload() error {
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		if err.Error() == r.readErr {
+			return nil
+		}
+		r.readErr = err.Error()
+		return err
+	}
+	r.readErr = ""
+
+	sum := sha256.Sum256(data)
+	if sum == r.loaded || sum == r.failed {
+		return nil
+	}
+
+	rules, err := validator.ParseRules(data)
+	if err != nil {
+		r.failed = sum
+		return fmt.Errorf("%s: %w", r.path, err)
+	}
+	validator.SetRules(rules)
+	r.loaded = sum
+	// The broken content may come back and must be reported again.
+	r.failed = [sha256.Size]byte{}
+
+	logger.Log.WithFields(logrus.Fields{
+		"path":  r.path,
+		"rules": len(rules),
+	}).Info("Validation rules loaded")
+	return nil
+}
@@ -0,0 +1,119 @@
+package rules_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/config"
+	"github.com/ArtemKVD/WB-TechL0/internal/rules"
+	"github.com/ArtemKVD/WB-TechL0/pkg/faker"
+	"github.com/ArtemKVD/WB-TechL0/pkg/validator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	allowMeest = "rules:\n  - name: delivery\n    field: delivery_service\n    in: [meest]\n"
+	allowCdek  = "rules:\n  - name: delivery\n    field: delivery_service\n    in: [cdek]\n"
+)
+
+// writeRules replaces the file at once, so a running reloader never reads
+// it half-written.
+func writeRules(t *testing.T, path, content string) {
+	t.Helper()
+	tmp := path + ".tmp"
+	require.NoError(t, os.WriteFile(tmp, []byte(content), 0o644))
+	require.NoError(t, os.Rename(tmp, path))
+}
+
+func newReloader(t *testing.T, content string) (*rules.Reloader, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	writeRules(t, path, content)
+	t.Cleanup(func() { validator.SetRules(nil) })
+
+	return rules.NewReloader(config.ValidationConfig{RulesPath: path, ReloadInterval: 10 * time.Millisecond}), path
+}
+
+func TestReloader_Load(t *testing.T) {
+	reloader, _ := newReloader(t, allowCdek)
+
+	require.NoError(t, reloader.Load())
+
+	_, ok := validator.Violations(validator.ValidateOrder(faker.GenerateTestOrders(1)[0]))
+	assert.True(t, ok)
+}
+
+func TestReloader_LoadInvalidFile(t *testing.T) {
+	reloader, path := newReloader(t, "rules:\n  - name: delivery\n    field: delivery\n    in: [cdek]\n")
+
+	err := reloader.Load()
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), path)
+}
+
+func TestReloader_Run(t *testing.T) {
+	reloader, path := newReloader(t, allowMeest)
+	require.NoError(t, reloader.Load())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		reloader.Run(ctx)
+		close(done)
+	}()
+
+	order := faker.GenerateTestOrders(1)[0]
+	require.NoError(t, validator.ValidateOrder(order))
+
+	writeRules(t, path, allowCdek)
+	assert.Eventually(t, func() bool {
+		return validator.ValidateOrder(order) != nil
+	}, time.Second, 5*time.Millisecond)
+
+	writeRules(t, path, "rules: [")
+	time.Sleep(50 * time.Millisecond)
+	assert.Error(t, validator.ValidateOrder(order), "an invalid file must keep the rules in use")
+
+	reloader.Stop()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after Stop")
+	}
+}
+
+func TestReloader_BrokenFileReportedAgainAfterFix(t *testing.T) {
+	const broken = "rules: ["
+	reloader, path := newReloader(t, broken)
+	assert.Error(t, reloader.Load())
+	assert.NoError(t, reloader.Load())
+
+	writeRules(t, path, allowMeest)
+	require.NoError(t, reloader.Load())
+
+	writeRules(t, path, broken)
+	assert.Error(t, reloader.Load())
+}
+
+func TestReloader_MissingFileReportedOnce(t *testing.T) {
+	reloader, path := newReloader(t, allowMeest)
+	require.NoError(t, reloader.Load())
+
+	require.NoError(t, os.Remove(path))
+	assert.Error(t, reloader.Load())
+	assert.NoError(t, reloader.Load())
+
+	writeRules(t, path, allowCdek)
+	require.NoError(t, reloader.Load())
+	_, ok := validator.Violations(validator.ValidateOrder(faker.GenerateTestOrders(1)[0]))
+	assert.True(t, ok)
+
+	require.NoError(t, os.Remove(path))
+	assert.Error(t, reloader.Load())
+}
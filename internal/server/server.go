@@ -1,48 +1,89 @@
 package server
 
 import (
+	"context"
+	"errors"
+	"net/http"
+
 	"github.com/ArtemKVD/WB-TechL0/internal/api"
 	"github.com/ArtemKVD/WB-TechL0/internal/cache"
 	"github.com/ArtemKVD/WB-TechL0/internal/config"
+	"github.com/ArtemKVD/WB-TechL0/internal/health"
 	"github.com/ArtemKVD/WB-TechL0/internal/logger"
+	"github.com/ArtemKVD/WB-TechL0/internal/metrics"
 	database "github.com/ArtemKVD/WB-TechL0/internal/storage"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 )
 
 type Server struct {
-	router  *gin.Engine
-	cache   *cache.Cache
-	storage database.OrderStorage
-	cfg     config.HTTPConfig
+	router     *gin.Engine
+	httpServer *http.Server
+	cache      *cache.Cache
+	storage    database.OrderStorage
+	cfg        config.HTTPConfig
 }
 
-func NewServer(cache *cache.Cache, db database.OrderStorage, cfg config.HTTPConfig) *Server {
+func NewServer(cache *cache.Cache, db database.OrderStorage, cfg config.HTTPConfig, m *metrics.Metrics, probes *health.Health, ingestHandler *api.IngestHandler) *Server {
 	router := gin.Default()
+	router.Use(m.Middleware())
 	handler := api.NewHandler(cache, db)
 
 	router.LoadHTMLGlob("web/templates/*.html")
 	router.GET("/", handler.IndexPage)
 	router.GET("/order", handler.GetOrder)
+	router.GET("/orders", handler.ListOrders)
+
+	v1 := router.Group("/api/v1")
+	v1.GET("/orders", handler.ListOrdersJSON)
+	v1.GET("/orders/:uid", handler.GetOrderJSON)
+	v1.POST("/orders", ingestHandler.CreateOrders)
+
+	router.GET("/metrics", gin.WrapH(m.Handler()))
+	router.GET("/healthz", probes.Healthz)
+	router.GET("/readyz", probes.Readyz)
 
 	return &Server{
-		router:  router,
+		router: router,
+		httpServer: &http.Server{
+			Addr:              ":" + cfg.Port,
+			Handler:           router,
+			ReadTimeout:       cfg.ReadTimeout,
+			ReadHeaderTimeout: cfg.ReadTimeout,
+			WriteTimeout:      cfg.WriteTimeout,
+			IdleTimeout:       cfg.IdleTimeout,
+		},
 		cache:   cache,
 		storage: db,
 		cfg:     cfg,
 	}
 }
 
-func (s *Server) Start() {
+// Run serves HTTP until Shutdown is called. Canceling ctx closes all
+// connections immediately, without waiting for requests in flight.
+func (s *Server) Run(ctx context.Context) error {
+	stop := context.AfterFunc(ctx, func() {
+		err := s.httpServer.Close()
+		if err != nil {
+			logger.Log.Error("Close HTTP server error: ", err)
+		}
+	})
+	defer stop()
+
 	logger.Log.WithFields(logrus.Fields{
 		"port": s.cfg.Port,
 	}).Info("Starting HTTP server")
 
-	err := s.router.Run(":" + s.cfg.Port)
-	if err != nil {
-		logger.Log.WithFields(logrus.Fields{
-			"error": err.Error(),
-			"port":  s.cfg.Port,
-		}).Fatal("HTTP server failed to start")
+	err := s.httpServer.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
 	}
+	return err
+}
+
+// Shutdown stops accepting connections and waits for requests in flight to
+// complete or ctx to expire.
+func (s *Server) Shutdown(ctx context.Context) error {
+	logger.Log.Info("Stopping HTTP server")
+	return s.httpServer.Shutdown(ctx)
 }
@@ -0,0 +1,340 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/logger"
+	"github.com/ArtemKVD/WB-TechL0/pkg/events"
+	"github.com/ArtemKVD/WB-TechL0/pkg/models"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+// maxParams is the number of bind parameters Postgres accepts in one
+// statement; larger bulk inserts are split.
+const maxParams = 65535
+
+// SaveOrders persists a batch of orders in one transaction, applying the
+// same idempotency and conflict rules as SaveOrder to each of them. New
+// orders are written with multi-row inserts; if that fails, the orders are
+// retried one by one so that a bad order only fails on its own.
+//
+// The returned slice holds the outcome of every order, nil meaning it was
+// saved or was a duplicate. A non-nil error means the batch as a whole
+// failed, for example because the connection was lost, and nothing was
+// saved.
+func (d *Database) SaveOrders(ctx context.Context, orders []models.Order) ([]error, error) {
+	ctx, cancel := withTimeout(ctx, d.cfg.WriteTimeout)
+	defer cancel()
+	return saveOrders(ctx, d.db, orders, d.cfg.ConflictPolicy)
+}
+
+func saveOrders(ctx context.Context, db *sql.DB, orders []models.Order, policy string) ([]error, error) {
+	results := make([]error, len(orders))
+	if len(orders) == 0 {
+		return results, nil
+	}
+
+	hashes := make([]string, len(orders))
+	dates := make([]time.Time, len(orders))
+	for i, order := range orders {
+		hashes[i], results[i] = orderHash(order)
+		if results[i] == nil {
+			dates[i], results[i] = storedDateCreated(order)
+		}
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Log.Error("Begin transaction error ", err)
+		return nil, err
+	}
+	defer func() {
+		err := tx.Rollback()
+		if err != nil && !errors.Is(err, sql.ErrTxDone) {
+			logger.Log.Error("Rollback error: ", err)
+		}
+	}()
+
+	uids := make([]string, len(orders))
+	for i, order := range orders {
+		uids[i] = order.OrderUID
+	}
+	stored, err := lockOrders(ctx, tx, uids)
+	if err != nil {
+		return nil, err
+	}
+
+	// Orders not stored yet go through the bulk path. Everything else, and
+	// any later version of an order already in the batch, needs the
+	// per-order rules and is saved one by one, in batch order.
+	var fresh, single []int
+	seen := make(map[string]bool, len(orders))
+	for i, order := range orders {
+		if results[i] != nil {
+			continue
+		}
+		if stored[order.OrderUID] || seen[order.OrderUID] {
+			single = append(single, i)
+		} else {
+			fresh = append(fresh, i)
+		}
+		seen[order.OrderUID] = true
+	}
+
+	if len(fresh) > 0 {
+		bulkErr, err := withSavepoint(ctx, tx, func() error {
+			return insertOrders(ctx, tx, orders, hashes, dates, fresh, results)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if bulkErr != nil {
+			if batchFailed(bulkErr) {
+				return nil, bulkErr
+			}
+			logger.Log.WithField("error", bulkErr.Error()).Warn("Bulk insert failed, saving orders one by one")
+			single = mergeSorted(fresh, single)
+		}
+	}
+
+	for _, i := range single {
+		orderErr, err := withSavepoint(ctx, tx, func() error {
+			return saveOrderTx(ctx, tx, orders[i], hashes[i], policy)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if batchFailed(orderErr) {
+			return nil, orderErr
+		}
+		results[i] = orderErr
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logger.Log.Error("Commit transaction error", err)
+		return nil, err
+	}
+	return results, nil
+}
+
+// batchFailed reports whether err affects the whole transaction rather
+// than the data of a single order.
+func batchFailed(err error) bool {
+	if err == nil || errors.Is(err, errConcurrentWrite) {
+		return false
+	}
+	return IsTransient(err) || errors.Is(err, context.Canceled)
+}
+
+// withSavepoint runs fn inside a savepoint and rolls back to it if fn
+// fails, so the transaction stays usable. fnErr is the error of fn; err is
+// only set if the savepoint itself could not be managed.
+func withSavepoint(ctx context.Context, tx *sql.Tx, fn func() error) (fnErr error, err error) {
+	_, err = tx.ExecContext(ctx, `SAVEPOINT save_orders`)
+	if err != nil {
+		return nil, err
+	}
+
+	fnErr = fn()
+	if fnErr != nil {
+		_, err = tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT save_orders`)
+		return fnErr, err
+	}
+
+	_, err = tx.ExecContext(ctx, `RELEASE SAVEPOINT save_orders`)
+	return nil, err
+}
+
+// lockOrders locks the stored rows of orderUIDs and reports which exist.
+func lockOrders(ctx context.Context, tx *sql.Tx, orderUIDs []string) (map[string]bool, error) {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT order_uid FROM orders WHERE order_uid = ANY($1) ORDER BY order_uid FOR UPDATE`,
+		pq.Array(orderUIDs),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	stored := make(map[string]bool)
+	for rows.Next() {
+		var orderUID string
+		err := rows.Scan(&orderUID)
+		if err != nil {
+			return nil, err
+		}
+		stored[orderUID] = true
+	}
+	return stored, rows.Err()
+}
+
+// insertOrders inserts the orders at indexes, and their outbox events, with
+// multi-row statements.
+// Orders another transaction inserted in the meantime are skipped and get
+// errConcurrentWrite in results, like SaveOrder would return.
+func insertOrders(ctx context.Context, tx *sql.Tx, orders []models.Order, hashes []string, dates []time.Time, indexes []int, results []error) error {
+	rows := make([][]any, 0, len(indexes))
+	for _, i := range indexes {
+		order := orders[i]
+		rows = append(rows, []any{
+			order.OrderUID, order.TrackNumber, order.Entry, order.Locale, order.InternalSignature, order.CustomerID,
+			order.DeliveryService, order.ShardKey, order.SMID, dates[i], order.OOFShard, hashes[i],
+		})
+	}
+
+	inserted := make(map[string]bool, len(indexes))
+	for _, stmt := range bulkInsert(
+		`INSERT INTO orders (order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, payload_hash)`,
+		rows,
+		`ON CONFLICT (order_uid) DO NOTHING RETURNING order_uid`,
+	) {
+		err := queryInserted(ctx, tx, stmt, inserted)
+		if err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	var deliveries, payments, items, orderEvents [][]any
+	for _, i := range indexes {
+		order := orders[i]
+		if !inserted[order.OrderUID] {
+			results[i] = fmt.Errorf("%w: %s", errConcurrentWrite, order.OrderUID)
+			continue
+		}
+
+		event, err := orderEventRow(order, events.ActionCreated, now)
+		if err != nil {
+			return err
+		}
+		orderEvents = append(orderEvents, event)
+
+		deliveries = append(deliveries, []any{
+			order.OrderUID, order.Delivery.Name, order.Delivery.Phone, order.Delivery.Zip, order.Delivery.City,
+			order.Delivery.Address, order.Delivery.Region, order.Delivery.Email,
+		})
+		payments = append(payments, []any{
+			order.OrderUID, order.Payment.Transaction, order.Payment.RequestID, order.Payment.Currency, order.Payment.Provider,
+			order.Payment.Amount, order.Payment.PaymentDt, order.Payment.Bank, order.Payment.DeliveryCost,
+			order.Payment.GoodsTotal, order.Payment.CustomFee,
+		})
+		for _, item := range order.Items {
+			items = append(items, []any{
+				order.OrderUID, item.ChrtID, item.TrackNumber, item.Price, item.RID, item.Name, item.Sale, item.Size,
+				item.TotalPrice, item.NmID, item.Brand, item.Status,
+			})
+		}
+	}
+
+	var stmts []bulkStatement
+	stmts = append(stmts, bulkInsert(
+		`INSERT INTO delivery (order_uid, name, phone, zip, city, address, region, email)`, deliveries, ``)...)
+	stmts = append(stmts, bulkInsert(
+		`INSERT INTO payment (order_uid, transaction, request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee)`, payments, ``)...)
+	stmts = append(stmts, bulkInsert(
+		`INSERT INTO items (order_uid, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status)`, items, ``)...)
+	stmts = append(stmts, bulkInsert(orderEventsInsert, orderEvents, ``)...)
+
+	for _, stmt := range stmts {
+		_, err := tx.ExecContext(ctx, stmt.query, stmt.args...)
+		if err != nil {
+			return err
+		}
+	}
+
+	logger.Log.WithFields(logrus.Fields{
+		"orders": len(inserted),
+		"items":  len(items),
+	}).Info("Orders inserted in bulk")
+	return nil
+}
+
+func queryInserted(ctx context.Context, tx *sql.Tx, stmt bulkStatement, inserted map[string]bool) error {
+	rows, err := tx.QueryContext(ctx, stmt.query, stmt.args...)
+	if err != nil {
+		return err
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var orderUID string
+		err := rows.Scan(&orderUID)
+		if err != nil {
+			return err
+		}
+		inserted[orderUID] = true
+	}
+	return rows.Err()
+}
+
+type bulkStatement struct {
+	query string
+	args  []any
+}
+
+// bulkInsert builds "head VALUES (...), (...) tail" statements for rows,
+// splitting them so that no statement exceeds maxParams parameters.
+func bulkInsert(head string, rows [][]any, tail string) []bulkStatement {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	columns := len(rows[0])
+	perStatement := maxParams / columns
+
+	var stmts []bulkStatement
+	for start := 0; start < len(rows); start += perStatement {
+		end := min(start+perStatement, len(rows))
+
+		var query strings.Builder
+		query.WriteString(head)
+		query.WriteString(" VALUES ")
+		args := make([]any, 0, (end-start)*columns)
+		for r, row := range rows[start:end] {
+			if r > 0 {
+				query.WriteString(", ")
+			}
+			query.WriteByte('(')
+			for c, value := range row {
+				if c > 0 {
+					query.WriteString(", ")
+				}
+				args = append(args, value)
+				query.WriteByte('$')
+				query.WriteString(strconv.Itoa(len(args)))
+			}
+			query.WriteByte(')')
+		}
+		if tail != "" {
+			query.WriteByte(' ')
+			query.WriteString(tail)
+		}
+
+		stmts = append(stmts, bulkStatement{query: query.String(), args: args})
+	}
+	return stmts
+}
+
+// mergeSorted merges two ascending index lists.
+func mergeSorted(a, b []int) []int {
+	merged := make([]int, 0, len(a)+len(b))
+	for len(a) > 0 && len(b) > 0 {
+		if a[0] < b[0] {
+			merged = append(merged, a[0])
+			a = a[1:]
+		} else {
+			merged = append(merged, b[0])
+			b = b[1:]
+		}
+	}
+	merged = append(merged, a...)
+	return append(merged, b...)
+}
@@ -0,0 +1,41 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBulkInsert(t *testing.T) {
+	t.Run("no rows", func(t *testing.T) {
+		assert.Empty(t, bulkInsert(`INSERT INTO t (a, b)`, nil, ``))
+	})
+
+	t.Run("single statement", func(t *testing.T) {
+		stmts := bulkInsert(`INSERT INTO t (a, b)`, [][]any{{"x", 1}, {"y", 2}}, `ON CONFLICT (a) DO NOTHING`)
+
+		assert.Equal(t, []bulkStatement{{
+			query: `INSERT INTO t (a, b) VALUES ($1, $2), ($3, $4) ON CONFLICT (a) DO NOTHING`,
+			args:  []any{"x", 1, "y", 2},
+		}}, stmts)
+	})
+
+	t.Run("split at parameter limit", func(t *testing.T) {
+		rows := make([][]any, maxParams/3+1)
+		for i := range rows {
+			rows[i] = []any{i, i, i}
+		}
+
+		stmts := bulkInsert(`INSERT INTO t (a, b, c)`, rows, ``)
+
+		assert.Len(t, stmts, 2)
+		assert.Len(t, stmts[0].args, maxParams/3*3)
+		assert.Equal(t, `INSERT INTO t (a, b, c) VALUES ($1, $2, $3)`, stmts[1].query)
+		assert.Equal(t, []any{maxParams / 3, maxParams / 3, maxParams / 3}, stmts[1].args)
+	})
+}
+
+func TestMergeSorted(t *testing.T) {
+	assert.Equal(t, []int{0, 1, 2, 4, 5, 7}, mergeSorted([]int{0, 2, 5}, []int{1, 4, 7}))
+	assert.Equal(t, []int{3}, mergeSorted(nil, []int{3}))
+}
@@ -1,25 +1,50 @@
 package database
 
 import (
+	"cmp"
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
+	"time"
 
 	"github.com/ArtemKVD/WB-TechL0/internal/config"
 	"github.com/ArtemKVD/WB-TechL0/internal/logger"
+	"github.com/ArtemKVD/WB-TechL0/pkg/events"
 	"github.com/ArtemKVD/WB-TechL0/pkg/models"
 	"github.com/joho/godotenv"
+	"github.com/lib/pq"
 )
 
-var ErrNotFound = errors.New("order not found")
+// Policies for an incoming order whose order_uid is already stored with a
+// different payload.
+const (
+	ConflictReject = "reject"
+	ConflictUpdate = "update"
+)
+
+var (
+	ErrNotFound      = errors.New("order not found")
+	ErrOrderConflict = errors.New("order already exists with different content")
+
+	// errConcurrentWrite means another transaction inserted the same order
+	// first; retrying sees the committed row and resolves as a duplicate.
+	errConcurrentWrite = errors.New("order was written concurrently")
+)
 
 //go:generate mockgen -destination=../mocks/storage_mock.go -package=mocks github.com/ArtemKVD/WB-TechL0/internal/storage OrderStorage
 type OrderStorage interface {
-	SaveOrder(order models.Order) error
-	GetOrder(orderUID string) (models.Order, error)
-	LoadOrdersFromDB() (map[string]models.Order, error)
+	SaveOrder(ctx context.Context, order models.Order) error
+	SaveOrders(ctx context.Context, orders []models.Order) ([]error, error)
+	GetOrder(ctx context.Context, orderUID string) (models.Order, error)
+	LoadOrdersFromDB(ctx context.Context) (map[string]models.Order, error)
+	ListOrders(ctx context.Context, filter OrderFilter) (OrderPage, error)
 	GetConnString() string
-	Connect() error
+	Connect(ctx context.Context) error
 	Close() error
 }
 
@@ -29,9 +54,23 @@ type Database struct {
 }
 
 func NewDatabase(cfg config.DatabaseConfig) *Database {
+	cfg.ConflictPolicy = conflictPolicy(cfg.ConflictPolicy)
 	return &Database{cfg: cfg}
 }
 
+// conflictPolicy validates a configured conflict policy, falling back to
+// reject.
+func conflictPolicy(policy string) string {
+	switch policy {
+	case ConflictReject, ConflictUpdate:
+		return policy
+	case "":
+	default:
+		logger.Log.WithField("policy", policy).Error("Unknown order conflict policy, using reject")
+	}
+	return ConflictReject
+}
+
 func (d *Database) GetConnString() string {
 	err := godotenv.Load()
 	if err != nil {
@@ -40,20 +79,42 @@ func (d *Database) GetConnString() string {
 	return getConnString(d.cfg)
 }
 
-func (d *Database) SaveOrder(order models.Order) error {
-	return saveOrder(d.db, order)
+func (d *Database) SaveOrder(ctx context.Context, order models.Order) error {
+	ctx, cancel := withTimeout(ctx, d.cfg.WriteTimeout)
+	defer cancel()
+	return saveOrder(ctx, d.db, order, d.cfg.ConflictPolicy)
 }
 
-func (d *Database) GetOrder(orderUID string) (models.Order, error) {
-	return getOrderFromDB(d.db, orderUID)
+func (d *Database) GetOrder(ctx context.Context, orderUID string) (models.Order, error) {
+	ctx, cancel := withTimeout(ctx, d.cfg.ReadTimeout)
+	defer cancel()
+	return getOrderFromDB(ctx, d.db, orderUID)
 }
 
-func (d *Database) LoadOrdersFromDB() (map[string]models.Order, error) {
+func (d *Database) LoadOrdersFromDB(ctx context.Context) (map[string]models.Order, error) {
+	ctx, cancel := withTimeout(ctx, d.cfg.ReadTimeout)
+	defer cancel()
 	cache := make(map[string]models.Order)
-	err := loadOrdersFromDB(d.db, cache)
+	err := loadOrdersFromDB(ctx, d.db, cache)
 	return cache, err
 }
 
+// Ping checks that the database is reachable.
+func (d *Database) Ping(ctx context.Context) error {
+	if d.db == nil {
+		return errors.New("database is not connected")
+	}
+
+	ctx, cancel := withTimeout(ctx, d.cfg.ReadTimeout)
+	defer cancel()
+	return d.db.PingContext(ctx)
+}
+
+// DB exposes the underlying pool for schema migrations and diagnostics.
+func (d *Database) DB() *sql.DB {
+	return d.db
+}
+
 func (d *Database) Close() error {
 	if d.db != nil {
 		return d.db.Close()
@@ -71,17 +132,34 @@ func getConnString(cfg config.DatabaseConfig) string {
 		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.Name, cfg.SSLMode)
 }
 
-func (d *Database) Connect() error {
+func (d *Database) Connect(ctx context.Context) error {
 	db, err := sql.Open("postgres", d.GetConnString())
 	if err != nil {
 		return err
 	}
 	d.db = db
-	return db.Ping()
+
+	ctx, cancel := withTimeout(ctx, d.cfg.ConnectTimeout)
+	defer cancel()
+	return db.PingContext(ctx)
 }
 
-func saveOrder(db *sql.DB, order models.Order) error {
-	tx, err := db.Begin()
+// withTimeout bounds a single storage operation; a non-positive timeout
+// leaves the caller's deadline as the only limit.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+func saveOrder(ctx context.Context, db *sql.DB, order models.Order, policy string) error {
+	hash, err := orderHash(order)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		logger.Log.Error("Begin transaction error ", err)
 		return err
@@ -93,27 +171,141 @@ func saveOrder(db *sql.DB, order models.Order) error {
 		}
 	}()
 
-	_, err = tx.Exec(
-		`INSERT INTO orders (order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
-		order.OrderUID, order.TrackNumber, order.Entry, order.Locale, order.InternalSignature, order.CustomerID, order.DeliveryService, order.ShardKey, order.SMID, order.DateCreated, order.OOFShard,
+	err = saveOrderTx(ctx, tx, order, hash, policy)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logger.Log.Error("Commit transaction error", err)
+		return err
+	}
+	return nil
+}
+
+// saveOrderTx applies the idempotency and conflict rules to a single order
+// within tx: new orders are inserted, redeliveries ignored and changed
+// versions updated or rejected depending on policy. Every insert or update
+// is recorded in the outbox.
+func saveOrderTx(ctx context.Context, tx *sql.Tx, order models.Order, hash string, policy string) error {
+	var storedHash sql.NullString
+	err := tx.QueryRowContext(ctx,
+		`SELECT payload_hash FROM orders WHERE order_uid = $1 FOR UPDATE`,
+		order.OrderUID,
+	).Scan(&storedHash)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		err = insertOrder(ctx, tx, order, hash)
+		if err != nil {
+			return err
+		}
+		return insertOrderEvent(ctx, tx, order, events.ActionCreated)
+	case err != nil:
+		return err
+	case storedHash.Valid && storedHash.String == hash:
+		logger.Log.WithField("order_uid", order.OrderUID).Info("Duplicate order ignored")
+		return nil
+	// Rows written before payload_hash existed have nothing to compare
+	// against, so the delivered version is taken as current rather than
+	// rejected as a conflict.
+	case policy == ConflictUpdate || !storedHash.Valid:
+		err = updateOrder(ctx, tx, order, hash)
+		if err != nil {
+			return err
+		}
+		return insertOrderEvent(ctx, tx, order, events.ActionUpdated)
+	default:
+		return fmt.Errorf("%w: %s", ErrOrderConflict, order.OrderUID)
+	}
+}
+
+func insertOrder(ctx context.Context, tx *sql.Tx, order models.Order, hash string) error {
+	created, err := storedDateCreated(order)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx,
+		`INSERT INTO orders (order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, payload_hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT (order_uid) DO NOTHING`,
+		order.OrderUID, order.TrackNumber, order.Entry, order.Locale, order.InternalSignature, order.CustomerID, order.DeliveryService, order.ShardKey, order.SMID, created, order.OOFShard, hash,
+	)
+	if err != nil {
+		return err
+	}
+
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if inserted == 0 {
+		return fmt.Errorf("%w: %s", errConcurrentWrite, order.OrderUID)
+	}
+
+	return upsertOrderDetails(ctx, tx, order)
+}
+
+func updateOrder(ctx context.Context, tx *sql.Tx, order models.Order, hash string) error {
+	created, err := storedDateCreated(order)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE orders SET track_number = $2, entry = $3, locale = $4, internal_signature = $5, customer_id = $6, delivery_service = $7,
+			shardkey = $8, sm_id = $9, date_created = $10, oof_shard = $11, payload_hash = $12
+		WHERE order_uid = $1`,
+		order.OrderUID, order.TrackNumber, order.Entry, order.Locale, order.InternalSignature, order.CustomerID, order.DeliveryService, order.ShardKey, order.SMID, created, order.OOFShard, hash,
+	)
+	if err != nil {
+		return err
+	}
+
+	rids := make([]string, 0, len(order.Items))
+	for _, item := range order.Items {
+		rids = append(rids, item.RID)
+	}
+	_, err = tx.ExecContext(ctx,
+		`DELETE FROM items WHERE order_uid = $1 AND NOT (rid = ANY($2))`,
+		order.OrderUID, pq.Array(rids),
 	)
 	if err != nil {
 		return err
 	}
 
-	_, err = tx.Exec(
+	err = upsertOrderDetails(ctx, tx, order)
+	if err != nil {
+		return err
+	}
+
+	logger.Log.WithField("order_uid", order.OrderUID).Info("Order updated")
+	return nil
+}
+
+// upsertOrderDetails writes delivery, payment and items of an order, relying
+// on the unique constraints on order_uid and (order_uid, rid) so that a
+// replayed write never leaves duplicate rows behind.
+func upsertOrderDetails(ctx context.Context, tx *sql.Tx, order models.Order) error {
+	_, err := tx.ExecContext(ctx,
 		`INSERT INTO delivery (order_uid, name, phone, zip, city, address, region, email)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (order_uid) DO UPDATE SET name = EXCLUDED.name, phone = EXCLUDED.phone, zip = EXCLUDED.zip, city = EXCLUDED.city,
+			address = EXCLUDED.address, region = EXCLUDED.region, email = EXCLUDED.email`,
 		order.OrderUID, order.Delivery.Name, order.Delivery.Phone, order.Delivery.Zip, order.Delivery.City, order.Delivery.Address, order.Delivery.Region, order.Delivery.Email,
 	)
 	if err != nil {
 		return err
 	}
 
-	_, err = tx.Exec(
+	_, err = tx.ExecContext(ctx,
 		`INSERT INTO payment (order_uid, transaction, request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (order_uid) DO UPDATE SET transaction = EXCLUDED.transaction, request_id = EXCLUDED.request_id, currency = EXCLUDED.currency,
+			provider = EXCLUDED.provider, amount = EXCLUDED.amount, payment_dt = EXCLUDED.payment_dt, bank = EXCLUDED.bank,
+			delivery_cost = EXCLUDED.delivery_cost, goods_total = EXCLUDED.goods_total, custom_fee = EXCLUDED.custom_fee`,
 		order.OrderUID, order.Payment.Transaction, order.Payment.RequestID, order.Payment.Currency, order.Payment.Provider, order.Payment.Amount, order.Payment.PaymentDt, order.Payment.Bank, order.Payment.DeliveryCost, order.Payment.GoodsTotal, order.Payment.CustomFee,
 	)
 	if err != nil {
@@ -121,159 +313,78 @@ func saveOrder(db *sql.DB, order models.Order) error {
 	}
 
 	for _, item := range order.Items {
-		_, err = tx.Exec(
+		_, err = tx.ExecContext(ctx,
 			`INSERT INTO items (order_uid, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+			ON CONFLICT (order_uid, rid) DO UPDATE SET chrt_id = EXCLUDED.chrt_id, track_number = EXCLUDED.track_number, price = EXCLUDED.price,
+				name = EXCLUDED.name, sale = EXCLUDED.sale, size = EXCLUDED.size, total_price = EXCLUDED.total_price, nm_id = EXCLUDED.nm_id,
+				brand = EXCLUDED.brand, status = EXCLUDED.status`,
 			order.OrderUID, item.ChrtID, item.TrackNumber, item.Price, item.RID, item.Name, item.Sale, item.Size, item.TotalPrice, item.NmID, item.Brand, item.Status,
 		)
 		if err != nil {
 			return err
 		}
 	}
-	err = tx.Commit()
-	if err != nil {
-		logger.Log.Error("Commit transaction error", err)
-		return err
-	}
+
 	return nil
 }
 
-func getOrderFromDB(db *sql.DB, orderUID string) (models.Order, error) {
-	tx, err := db.Begin()
+// orderHash fingerprints the order payload so redeliveries can be told
+// apart from changed versions of the same order.
+func orderHash(order models.Order) (string, error) {
+	payload, err := json.Marshal(order)
 	if err != nil {
-		logger.Log.Error("Begin transaction error", err)
-		return models.Order{}, err
+		return "", err
 	}
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:]), nil
+}
 
-	query := `
-		SELECT 
-			o.order_uid, o.track_number, o.entry, o.locale, o.internal_signature,
-			o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard,
-			d.name, d.phone, d.zip, d.city, d.address, d.region, d.email,
-			p.transaction, p.request_id, p.currency, p.provider, p.amount, p.payment_dt,
-			p.bank, p.delivery_cost, p.goods_total, p.custom_fee,
-			i.chrt_id, i.track_number as item_track_number, i.price, i.rid, i.name as item_name,
-			i.sale, i.size, i.total_price, i.nm_id, i.brand, i.status
-		FROM orders o
-		INNER JOIN delivery d ON o.order_uid = d.order_uid
-		INNER JOIN payment p ON o.order_uid = p.order_uid
-		LEFT JOIN items i ON o.order_uid = i.order_uid
-		WHERE o.order_uid = $1
-		ORDER BY i.chrt_id
-	`
-
-	rows, err := tx.Query(query, orderUID)
+// storedDateCreated returns date_created the way Postgres stores it in a
+// TIMESTAMP column: the offset is dropped, the wall clock kept and rounded to
+// microseconds, so dates compare the same in every storage.
+func storedDateCreated(order models.Order) (time.Time, error) {
+	created := order.DateCreated
+	if created.IsZero() {
+		return time.Time{}, fmt.Errorf("order %s has no date_created", order.OrderUID)
+	}
+	return storedTime(created.Time), nil
+}
+
+// storedTime drops the offset of t and keeps its wall clock, rounded to
+// microseconds, as storedDateCreated does.
+func storedTime(t time.Time) time.Time {
+	year, month, day := t.Date()
+	hour, minute, second := t.Clock()
+	return time.Date(year, month, day, hour, minute, second, t.Nanosecond(), time.UTC).Round(time.Microsecond)
+}
+
+// sortItems orders items by chrt_id, as Database returns them.
+func sortItems(items []models.Item) {
+	slices.SortStableFunc(items, func(a, b models.Item) int {
+		return cmp.Compare(a.ChrtID, b.ChrtID)
+	})
+}
+
+func getOrderFromDB(ctx context.Context, db *sql.DB, orderUID string) (models.Order, error) {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
 	if err != nil {
+		logger.Log.Error("Begin transaction error", err)
 		return models.Order{}, err
 	}
 	defer func() {
-		err := rows.Close()
-		if err != nil {
-			logger.Log.Error("Rows close error: ", err)
+		err := tx.Rollback()
+		if err != nil && !errors.Is(err, sql.ErrTxDone) {
+			logger.Log.Error("Rollback error: ", err)
 		}
 	}()
 
-	var order models.Order
-	var currentOrderUID string
-	itemsMap := make(map[int]models.Item)
-
-	for rows.Next() {
-		var (
-			orderUID, trackNumber, entry, locale, internalSignature string
-			customerID, deliveryService, shardkey, oofShard         string
-			smID                                                    int
-			dateCreated                                             string
-			name, phone, zip, city, address, region, email          string
-			transaction, requestID, currency, provider, bank        string
-			amount, deliveryCost, goodsTotal, customFee             int
-			paymentDt                                               int
-			chrtID                                                  int
-			itemTrackNumber, rid, itemName, size, brand             string
-			price, sale, totalPrice, nmID, status                   int
-		)
-
-		err := rows.Scan(
-			&orderUID, &trackNumber, &entry, &locale, &internalSignature,
-			&customerID, &deliveryService, &shardkey, &smID, &dateCreated, &oofShard,
-			&name, &phone, &zip, &city, &address, &region, &email,
-			&transaction, &requestID, &currency, &provider, &amount, &paymentDt,
-			&bank, &deliveryCost, &goodsTotal, &customFee,
-			&chrtID, &itemTrackNumber, &price, &rid, &itemName,
-			&sale, &size, &totalPrice, &nmID, &brand, &status,
-		)
-		err = rows.Err()
-		if err != nil {
-			err2 := tx.Rollback()
-			if err2 != nil {
-				logger.Log.Error("Rollback error: ", err)
-			}
-			if errors.Is(err, sql.ErrNoRows) {
-				logger.Log.Error("Order not found ", err)
-				return models.Order{}, ErrNotFound
-			}
-			logger.Log.Error("Iterating rows error ", err)
-			return models.Order{}, err
-		}
-
-		if currentOrderUID != orderUID {
-			order = models.Order{
-				OrderUID:          orderUID,
-				TrackNumber:       trackNumber,
-				Entry:             entry,
-				Locale:            locale,
-				InternalSignature: internalSignature,
-				CustomerID:        customerID,
-				DeliveryService:   deliveryService,
-				ShardKey:          shardkey,
-				SMID:              smID,
-				DateCreated:       dateCreated,
-				OOFShard:          oofShard,
-				Delivery: models.Delivery{
-					Name:    name,
-					Phone:   phone,
-					Zip:     zip,
-					City:    city,
-					Address: address,
-					Region:  region,
-					Email:   email,
-				},
-				Payment: models.Payment{
-					Transaction:  transaction,
-					RequestID:    requestID,
-					Currency:     currency,
-					Provider:     provider,
-					Bank:         bank,
-					Amount:       amount,
-					PaymentDt:    paymentDt,
-					DeliveryCost: deliveryCost,
-					GoodsTotal:   goodsTotal,
-					CustomFee:    customFee,
-				},
-				Items: []models.Item{},
-			}
-			currentOrderUID = orderUID
-		}
-
-		if chrtID != 0 {
-			item := models.Item{
-				ChrtID:      chrtID,
-				TrackNumber: itemTrackNumber,
-				Price:       price,
-				RID:         rid,
-				Name:        itemName,
-				Sale:        sale,
-				Size:        size,
-				TotalPrice:  totalPrice,
-				NmID:        nmID,
-				Brand:       brand,
-				Status:      status,
-			}
-			itemsMap[chrtID] = item
-		}
+	orders, err := getOrdersByUIDs(ctx, tx, []string{orderUID})
+	if err != nil {
+		return models.Order{}, err
 	}
-
-	for _, item := range itemsMap {
-		order.Items = append(order.Items, item)
+	if len(orders) == 0 {
+		return models.Order{}, ErrNotFound
 	}
 
 	err = tx.Commit()
@@ -281,133 +392,58 @@ func getOrderFromDB(db *sql.DB, orderUID string) (models.Order, error) {
 		logger.Log.Error("commit transaction error ", err)
 		return models.Order{}, err
 	}
-
-	return order, nil
+	return orders[0], nil
 }
 
-func loadOrdersFromDB(db *sql.DB, cache map[string]models.Order) error {
-	limit := 5
-	tx, err := db.Begin()
+// loadLimit is the number of most recent orders LoadOrdersFromDB returns to
+// warm the cache.
+const loadLimit = 5
+
+func loadOrdersFromDB(ctx context.Context, db *sql.DB, cache map[string]models.Order) error {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true, Isolation: sql.LevelRepeatableRead})
 	if err != nil {
 		logger.Log.Error("begin transaction error", err)
 		return err
 	}
 	defer func() {
 		err := tx.Rollback()
-		if err != nil {
+		if err != nil && !errors.Is(err, sql.ErrTxDone) {
 			logger.Log.Error("Rollback error: ", err)
 		}
 	}()
 
-	query := `
-		SELECT 
-			o.order_uid, o.track_number, o.entry, o.locale, o.internal_signature,
-			o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard,
-			d.name, d.phone, d.zip, d.city, d.address, d.region, d.email,
-			p.transaction, p.request_id, p.currency, p.provider, p.amount, p.payment_dt,
-			p.bank, p.delivery_cost, p.goods_total, p.custom_fee,
-			i.chrt_id, i.track_number as item_track_number, i.price, i.rid, i.name as item_name,
-			i.sale, i.size, i.total_price, i.nm_id, i.brand, i.status
-		FROM orders o
-		INNER JOIN delivery d ON o.order_uid = d.order_uid
-		INNER JOIN payment p ON o.order_uid = p.order_uid
-		LEFT JOIN items i ON o.order_uid = i.order_uid
-		WHERE o.order_uid IN (
-			SELECT order_uid 
-			FROM orders 
-			ORDER BY date_created DESC 
-			LIMIT $1
-		)
-		ORDER BY o.order_uid, i.chrt_id
-	`
-
-	rows, err := tx.Query(query, limit)
+	rows, err := tx.QueryContext(ctx,
+		`SELECT order_uid FROM orders ORDER BY date_created DESC NULLS LAST, order_uid DESC LIMIT $1`,
+		loadLimit,
+	)
 	if err != nil {
 		return err
 	}
-	defer func() {
-		err := rows.Close()
-		if err != nil {
-			logger.Log.Error("Rows close error: ", err)
-		}
-	}()
 
+	var orderUIDs []string
 	for rows.Next() {
-		var orderUID, trackNumber, entry, locale, internalSignature, customerID, deliveryService, shardkey, oofShard, dateCreated, name, phone, zip, city, address, region, email, transaction, requestID, currency, provider, bank, itemTrackNumber, rid, itemName, size, brand string
-		var smID, amount, deliveryCost, goodsTotal, customFee, paymentDt, chrtID, price, sale, totalPrice, nmID, status int
-
-		err := rows.Scan(
-			&orderUID, &trackNumber, &entry, &locale, &internalSignature, &customerID, &deliveryService, &shardkey, &smID, &dateCreated, &oofShard,
-			&name, &phone, &zip, &city, &address, &region, &email, &transaction, &requestID, &currency, &provider, &amount, &paymentDt,
-			&bank, &deliveryCost, &goodsTotal, &customFee, &chrtID, &itemTrackNumber, &price, &rid, &itemName, &sale, &size, &totalPrice, &nmID, &brand, &status,
-		)
+		var orderUID string
+		err = rows.Scan(&orderUID)
 		if err != nil {
+			closeRows(rows)
 			logger.Log.Error("Error scanning row ", err)
 			return err
 		}
-		err = rows.Err()
-		if err != nil {
-			logger.Log.Error("rows iterating error")
-			return fmt.Errorf("rows iterating error")
-		}
-
-		order, exists := cache[orderUID]
-		if !exists {
-			order = models.Order{
-				OrderUID:          orderUID,
-				TrackNumber:       trackNumber,
-				Entry:             entry,
-				Locale:            locale,
-				InternalSignature: internalSignature,
-				CustomerID:        customerID,
-				DeliveryService:   deliveryService,
-				ShardKey:          shardkey,
-				SMID:              smID,
-				DateCreated:       dateCreated,
-				OOFShard:          oofShard,
-				Delivery: models.Delivery{
-					Name:    name,
-					Phone:   phone,
-					Zip:     zip,
-					City:    city,
-					Address: address,
-					Region:  region,
-					Email:   email,
-				},
-				Payment: models.Payment{
-					Transaction:  transaction,
-					RequestID:    requestID,
-					Currency:     currency,
-					Provider:     provider,
-					Bank:         bank,
-					Amount:       amount,
-					PaymentDt:    paymentDt,
-					DeliveryCost: deliveryCost,
-					GoodsTotal:   goodsTotal,
-					CustomFee:    customFee,
-				},
-				Items: []models.Item{},
-			}
-		}
-
-		{
-			item := models.Item{
-				ChrtID:      chrtID,
-				TrackNumber: itemTrackNumber,
-				Price:       price,
-				RID:         rid,
-				Name:        itemName,
-				Sale:        sale,
-				Size:        size,
-				TotalPrice:  totalPrice,
-				NmID:        nmID,
-				Brand:       brand,
-				Status:      status,
-			}
-			order.Items = append(order.Items, item)
-		}
+		orderUIDs = append(orderUIDs, orderUID)
+	}
+	err = rows.Err()
+	closeRows(rows)
+	if err != nil {
+		logger.Log.Error("rows iterating error ", err)
+		return err
+	}
 
-		cache[orderUID] = order
+	orders, err := getOrdersByUIDs(ctx, tx, orderUIDs)
+	if err != nil {
+		return err
+	}
+	for _, order := range orders {
+		cache[order.OrderUID] = order
 	}
 
 	err = tx.Commit()
@@ -0,0 +1,60 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+
+	"github.com/lib/pq"
+)
+
+// transientCodes lists SQLSTATE codes that describe a temporary condition
+// of the server or of the transaction rather than a problem with the data.
+var transientCodes = map[pq.ErrorCode]bool{
+	"40001": true, // serialization_failure
+	"40P01": true, // deadlock_detected
+	"55P03": true, // lock_not_available
+	"57P01": true, // admin_shutdown
+	"57P02": true, // crash_shutdown
+	"57P03": true, // cannot_connect_now
+	"58030": true, // io_error
+}
+
+// transientClasses lists SQLSTATE classes that are transient as a whole.
+var transientClasses = map[pq.ErrorClass]bool{
+	"08": true, // connection_exception
+	"53": true, // insufficient_resources
+}
+
+// IsTransient reports whether err is worth retrying: connection failures,
+// timeouts, serialization failures and deadlocks. Constraint violations,
+// data errors and cancellation are permanent.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, errConcurrentWrite) {
+		return true
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return transientCodes[pqErr.Code] || transientClasses[pqErr.Code.Class()]
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,52 @@
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+
+	database "github.com/ArtemKVD/WB-TechL0/internal/storage"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"serialization failure", &pq.Error{Code: "40001"}, true},
+		{"deadlock", &pq.Error{Code: "40P01"}, true},
+		{"connection failure", &pq.Error{Code: "08006"}, true},
+		{"too many connections", &pq.Error{Code: "53300"}, true},
+		{"admin shutdown", &pq.Error{Code: "57P01"}, true},
+		{"wrapped deadlock", fmt.Errorf("insert items: %w", &pq.Error{Code: "40P01"}), true},
+		{"unique violation", &pq.Error{Code: "23505"}, false},
+		{"foreign key violation", &pq.Error{Code: "23503"}, false},
+		{"invalid datetime", &pq.Error{Code: "22007"}, false},
+		{"undefined table", &pq.Error{Code: "42P01"}, false},
+		{"connection reset", &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}, true},
+		{"connection refused", fmt.Errorf("dial: %w", syscall.ECONNREFUSED), true},
+		{"bad connection", driver.ErrBadConn, true},
+		{"unexpected eof", io.ErrUnexpectedEOF, true},
+		{"operation timeout", fmt.Errorf("save order: %w", context.DeadlineExceeded), true},
+		{"canceled", context.Canceled, false},
+		{"tx done", sql.ErrTxDone, false},
+		{"not found", database.ErrNotFound, false},
+		{"plain error", errors.New("something went wrong"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, database.IsTransient(tt.err))
+		})
+	}
+}
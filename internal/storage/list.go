@@ -0,0 +1,305 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/logger"
+	"github.com/ArtemKVD/WB-TechL0/pkg/models"
+	"github.com/lib/pq"
+)
+
+const (
+	DefaultListLimit = 20
+	MaxListLimit     = 100
+)
+
+// OrderFilter selects orders for ListOrders. Zero values are ignored. Brand
+// and NmID must match the same item of an order. CreatedFrom and CreatedTo
+// are compared by wall clock, ignoring their offset, as date_created is
+// stored.
+type OrderFilter struct {
+	CustomerID      string
+	TrackNumber     string
+	DeliveryService string
+	CreatedFrom     time.Time
+	CreatedTo       time.Time
+	Currency        string
+	Provider        string
+	Brand           string
+	NmID            int
+	After           *Cursor
+	Limit           int
+}
+
+// Cursor points at the last order of a page. Orders are listed newest
+// first, ordered by (date_created, order_uid) descending.
+type Cursor struct {
+	DateCreated time.Time
+	OrderUID    string
+}
+
+type OrderPage struct {
+	Orders []models.Order
+	Next   *Cursor
+}
+
+func (d *Database) ListOrders(ctx context.Context, filter OrderFilter) (OrderPage, error) {
+	ctx, cancel := withTimeout(ctx, d.cfg.ReadTimeout)
+	defer cancel()
+	return listOrders(ctx, d.db, filter)
+}
+
+func listOrders(ctx context.Context, db *sql.DB, filter OrderFilter) (OrderPage, error) {
+	limit := listLimit(filter.Limit)
+
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true, Isolation: sql.LevelRepeatableRead})
+	if err != nil {
+		logger.Log.Error("Begin transaction error", err)
+		return OrderPage{}, err
+	}
+	defer func() {
+		err := tx.Rollback()
+		if err != nil {
+			logger.Log.Error("Rollback error: ", err)
+		}
+	}()
+
+	query, args := buildListQuery(filter, limit+1)
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return OrderPage{}, err
+	}
+
+	var cursors []Cursor
+	for rows.Next() {
+		var cursor Cursor
+		err = rows.Scan(&cursor.OrderUID, &cursor.DateCreated)
+		if err != nil {
+			closeRows(rows)
+			return OrderPage{}, err
+		}
+		cursors = append(cursors, cursor)
+	}
+	err = rows.Err()
+	closeRows(rows)
+	if err != nil {
+		return OrderPage{}, err
+	}
+
+	var page OrderPage
+	if len(cursors) > limit {
+		cursors = cursors[:limit]
+		next := cursors[limit-1]
+		page.Next = &next
+	}
+	if len(cursors) == 0 {
+		page.Orders = []models.Order{}
+		return page, nil
+	}
+
+	orderUIDs := make([]string, 0, len(cursors))
+	for _, cursor := range cursors {
+		orderUIDs = append(orderUIDs, cursor.OrderUID)
+	}
+
+	orders, err := getOrdersByUIDs(ctx, tx, orderUIDs)
+	if err != nil {
+		return OrderPage{}, err
+	}
+	page.Orders = orders
+
+	return page, nil
+}
+
+// listLimit applies the default and maximum page size.
+func listLimit(limit int) int {
+	if limit <= 0 {
+		return DefaultListLimit
+	}
+	return min(limit, MaxListLimit)
+}
+
+// listDialect holds what the list query needs to differ in between
+// databases: how timestamps are passed and compared.
+type listDialect struct {
+	timestamp func(time.Time) any
+	// cursor formats the (date_created, order_uid) row value of a cursor
+	// from the placeholders of its two parts.
+	cursor string
+}
+
+var postgresList = listDialect{
+	timestamp: func(t time.Time) any { return storedTime(t) },
+	cursor:    "(%s::timestamp, %s::text)",
+}
+
+func buildListQuery(filter OrderFilter, limit int) (string, []any) {
+	return postgresList.buildListQuery(filter, limit)
+}
+
+func (d listDialect) buildListQuery(filter OrderFilter, limit int) (string, []any) {
+	var (
+		conditions []string
+		args       []any
+	)
+	arg := func(value any) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if filter.CustomerID != "" {
+		conditions = append(conditions, "o.customer_id = "+arg(filter.CustomerID))
+	}
+	if filter.TrackNumber != "" {
+		conditions = append(conditions, "o.track_number = "+arg(filter.TrackNumber))
+	}
+	if filter.DeliveryService != "" {
+		conditions = append(conditions, "o.delivery_service = "+arg(filter.DeliveryService))
+	}
+	if !filter.CreatedFrom.IsZero() {
+		conditions = append(conditions, "o.date_created >= "+arg(d.timestamp(filter.CreatedFrom)))
+	}
+	if !filter.CreatedTo.IsZero() {
+		conditions = append(conditions, "o.date_created <= "+arg(d.timestamp(filter.CreatedTo)))
+	}
+	if filter.Currency != "" {
+		conditions = append(conditions, "p.currency = "+arg(filter.Currency))
+	}
+	if filter.Provider != "" {
+		conditions = append(conditions, "p.provider = "+arg(filter.Provider))
+	}
+	if filter.Brand != "" || filter.NmID != 0 {
+		var itemConditions []string
+		if filter.Brand != "" {
+			itemConditions = append(itemConditions, "i.brand = "+arg(filter.Brand))
+		}
+		if filter.NmID != 0 {
+			itemConditions = append(itemConditions, "i.nm_id = "+arg(filter.NmID))
+		}
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM items i WHERE i.order_uid = o.order_uid AND "+
+			strings.Join(itemConditions, " AND ")+")")
+	}
+	if filter.After != nil {
+		conditions = append(conditions, "(o.date_created, o.order_uid) < "+fmt.Sprintf(d.cursor,
+			arg(d.timestamp(filter.After.DateCreated)), arg(filter.After.OrderUID)))
+	}
+
+	query := `SELECT o.order_uid, o.date_created FROM orders o`
+	if filter.Currency != "" || filter.Provider != "" {
+		query += ` INNER JOIN payment p ON o.order_uid = p.order_uid`
+	}
+	conditions = append(conditions, "o.date_created IS NOT NULL")
+	query += " WHERE " + strings.Join(conditions, " AND ")
+	query += " ORDER BY o.date_created DESC, o.order_uid DESC LIMIT " + arg(limit)
+
+	return query, args
+}
+
+// orderColumns selects a full order, one row per item, from orders o,
+// delivery d, payment p and items i, in the order scanOrders expects.
+const orderColumns = `
+		SELECT
+			o.order_uid, o.track_number, o.entry, o.locale, o.internal_signature,
+			o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard,
+			d.name, d.phone, d.zip, d.city, d.address, d.region, d.email,
+			p.transaction, p.request_id, p.currency, p.provider, p.amount, p.payment_dt,
+			p.bank, p.delivery_cost, p.goods_total, p.custom_fee,
+			i.chrt_id, i.track_number as item_track_number, i.price, i.rid, i.name as item_name,
+			i.sale, i.size, i.total_price, i.nm_id, i.brand, i.status
+		FROM orders o
+		INNER JOIN delivery d ON o.order_uid = d.order_uid
+		INNER JOIN payment p ON o.order_uid = p.order_uid
+		LEFT JOIN items i ON o.order_uid = i.order_uid`
+
+// getOrdersByUIDs loads full orders and returns them in the order of
+// orderUIDs. Unknown ids are skipped.
+func getOrdersByUIDs(ctx context.Context, tx *sql.Tx, orderUIDs []string) ([]models.Order, error) {
+	rows, err := tx.QueryContext(ctx,
+		orderColumns+` WHERE o.order_uid = ANY($1) ORDER BY o.order_uid, i.chrt_id`,
+		pq.Array(orderUIDs),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+	return scanOrders(rows, orderUIDs)
+}
+
+// scanOrders reads the rows of an orderColumns query, ordered by order_uid
+// and chrt_id, and returns the orders in the order of orderUIDs.
+func scanOrders(rows *sql.Rows, orderUIDs []string) ([]models.Order, error) {
+	byUID := make(map[string]*models.Order, len(orderUIDs))
+	for rows.Next() {
+		var (
+			order models.Order
+			item  struct {
+				chrtID, price, sale, totalPrice, nmID, status sql.NullInt64
+				trackNumber, rid, name, size, brand           sql.NullString
+			}
+		)
+
+		err := rows.Scan(
+			&order.OrderUID, &order.TrackNumber, &order.Entry, &order.Locale, &order.InternalSignature,
+			&order.CustomerID, &order.DeliveryService, &order.ShardKey, &order.SMID, &order.DateCreated, &order.OOFShard,
+			&order.Delivery.Name, &order.Delivery.Phone, &order.Delivery.Zip, &order.Delivery.City,
+			&order.Delivery.Address, &order.Delivery.Region, &order.Delivery.Email,
+			&order.Payment.Transaction, &order.Payment.RequestID, &order.Payment.Currency, &order.Payment.Provider,
+			&order.Payment.Amount, &order.Payment.PaymentDt, &order.Payment.Bank, &order.Payment.DeliveryCost,
+			&order.Payment.GoodsTotal, &order.Payment.CustomFee,
+			&item.chrtID, &item.trackNumber, &item.price, &item.rid, &item.name,
+			&item.sale, &item.size, &item.totalPrice, &item.nmID, &item.brand, &item.status,
+		)
+		if err != nil {
+			logger.Log.Error("Error scanning row ", err)
+			return nil, err
+		}
+
+		existing, ok := byUID[order.OrderUID]
+		if !ok {
+			order.Items = []models.Item{}
+			existing = &order
+			byUID[order.OrderUID] = existing
+		}
+
+		if item.chrtID.Valid {
+			existing.Items = append(existing.Items, models.Item{
+				ChrtID:      int(item.chrtID.Int64),
+				TrackNumber: item.trackNumber.String,
+				Price:       models.Money(item.price.Int64),
+				RID:         item.rid.String,
+				Name:        item.name.String,
+				Sale:        int(item.sale.Int64),
+				Size:        item.size.String,
+				TotalPrice:  models.Money(item.totalPrice.Int64),
+				NmID:        int(item.nmID.Int64),
+				Brand:       item.brand.String,
+				Status:      models.ItemStatus(item.status.Int64),
+			})
+		}
+	}
+	err := rows.Err()
+	if err != nil {
+		logger.Log.Error("Iterating rows error ", err)
+		return nil, err
+	}
+
+	orders := make([]models.Order, 0, len(orderUIDs))
+	for _, orderUID := range orderUIDs {
+		order, ok := byUID[orderUID]
+		if ok {
+			orders = append(orders, *order)
+		}
+	}
+	return orders, nil
+}
+
+func closeRows(rows *sql.Rows) {
+	err := rows.Close()
+	if err != nil {
+		logger.Log.Error("Rows close error: ", err)
+	}
+}
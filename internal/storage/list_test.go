@@ -0,0 +1,47 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildListQuery(t *testing.T) {
+	created := time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC)
+
+	t.Run("no filters", func(t *testing.T) {
+		query, args := buildListQuery(OrderFilter{}, 21)
+
+		assert.Equal(t, `SELECT o.order_uid, o.date_created FROM orders o`+
+			` WHERE o.date_created IS NOT NULL ORDER BY o.date_created DESC, o.order_uid DESC LIMIT $1`, query)
+		assert.Equal(t, []any{21}, args)
+	})
+
+	t.Run("all filters", func(t *testing.T) {
+		query, args := buildListQuery(OrderFilter{
+			CustomerID:      "customer",
+			TrackNumber:     "track",
+			DeliveryService: "meest",
+			CreatedFrom:     created,
+			CreatedTo:       created.Add(time.Hour),
+			Currency:        "USD",
+			Provider:        "wbpay",
+			Brand:           "brand",
+			NmID:            42,
+			After:           &Cursor{DateCreated: created, OrderUID: "order1"},
+		}, 11)
+
+		assert.Equal(t, `SELECT o.order_uid, o.date_created FROM orders o`+
+			` INNER JOIN payment p ON o.order_uid = p.order_uid`+
+			` WHERE o.customer_id = $1 AND o.track_number = $2 AND o.delivery_service = $3`+
+			` AND o.date_created >= $4 AND o.date_created <= $5 AND p.currency = $6 AND p.provider = $7`+
+			` AND EXISTS (SELECT 1 FROM items i WHERE i.order_uid = o.order_uid AND i.brand = $8 AND i.nm_id = $9)`+
+			` AND (o.date_created, o.order_uid) < ($10::timestamp, $11::text)`+
+			` AND o.date_created IS NOT NULL ORDER BY o.date_created DESC, o.order_uid DESC LIMIT $12`, query)
+		assert.Equal(t, []any{
+			"customer", "track", "meest", created, created.Add(time.Hour), "USD", "wbpay", "brand", 42,
+			created, "order1", 11,
+		}, args)
+	})
+}
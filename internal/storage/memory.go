@@ -0,0 +1,307 @@
+package database
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/config"
+	"github.com/ArtemKVD/WB-TechL0/internal/logger"
+	"github.com/ArtemKVD/WB-TechL0/pkg/events"
+	"github.com/ArtemKVD/WB-TechL0/pkg/models"
+)
+
+// MemoryStorage keeps orders and their outbox events in memory, applying
+// the same idempotency, conflict and listing rules as Database. It is safe
+// for concurrent use and meant for tests and demos: nothing survives a
+// restart.
+type MemoryStorage struct {
+	policy string
+
+	mu          sync.RWMutex
+	orders      map[string]memoryOrder
+	events      []memoryEvent
+	nextEventID int64
+
+	// publishing serializes PublishPendingEvents, so an event is never
+	// handed to two publishers at once.
+	publishing sync.Mutex
+}
+
+type memoryOrder struct {
+	order   models.Order
+	hash    string
+	created time.Time
+}
+
+type memoryEvent struct {
+	OutboxEvent
+	sentAt time.Time
+}
+
+func NewMemoryStorage(cfg config.DatabaseConfig) *MemoryStorage {
+	return &MemoryStorage{
+		policy: conflictPolicy(cfg.ConflictPolicy),
+		orders: make(map[string]memoryOrder),
+	}
+}
+
+func (m *MemoryStorage) GetConnString() string {
+	return Memory
+}
+
+func (m *MemoryStorage) Connect(ctx context.Context) error {
+	return nil
+}
+
+// Ping always succeeds: there is nothing to reach.
+func (m *MemoryStorage) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (m *MemoryStorage) Close() error {
+	return nil
+}
+
+func (m *MemoryStorage) SaveOrder(ctx context.Context, order models.Order) error {
+	err := ctx.Err()
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.save(order)
+}
+
+// SaveOrders saves the orders one by one under a single lock, so the batch
+// is applied atomically with respect to readers.
+func (m *MemoryStorage) SaveOrders(ctx context.Context, orders []models.Order) ([]error, error) {
+	err := ctx.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	results := make([]error, len(orders))
+	for i, order := range orders {
+		results[i] = m.save(order)
+	}
+	return results, nil
+}
+
+// save applies the rules of saveOrderTx. Must be called with mu held.
+func (m *MemoryStorage) save(order models.Order) error {
+	hash, err := orderHash(order)
+	if err != nil {
+		return err
+	}
+	created, err := storedDateCreated(order)
+	if err != nil {
+		return err
+	}
+
+	action := events.ActionCreated
+	stored, exists := m.orders[order.OrderUID]
+	switch {
+	case !exists:
+	case stored.hash == hash:
+		logger.Log.WithField("order_uid", order.OrderUID).Info("Duplicate order ignored")
+		return nil
+	case m.policy == ConflictUpdate:
+		action = events.ActionUpdated
+	default:
+		return fmt.Errorf("%w: %s", ErrOrderConflict, order.OrderUID)
+	}
+
+	event, err := m.newEvent(order, action)
+	if err != nil {
+		return err
+	}
+
+	order = copyOrder(order)
+	order.DateCreated = models.Timestamp{Time: created}
+	m.orders[order.OrderUID] = memoryOrder{order: order, hash: hash, created: created}
+	m.events = append(m.events, event)
+	return nil
+}
+
+func (m *MemoryStorage) newEvent(order models.Order, action string) (memoryEvent, error) {
+	now := time.Now()
+	event, err := events.NewOrderAccepted(order, action, now)
+	if err != nil {
+		return memoryEvent{}, err
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return memoryEvent{}, err
+	}
+
+	m.nextEventID++
+	return memoryEvent{OutboxEvent: OutboxEvent{
+		ID:            m.nextEventID,
+		EventID:       event.EventID,
+		EventType:     event.EventType,
+		SchemaVersion: event.SchemaVersion,
+		OrderUID:      event.OrderUID,
+		Payload:       payload,
+		CreatedAt:     now,
+	}}, nil
+}
+
+func (m *MemoryStorage) GetOrder(ctx context.Context, orderUID string) (models.Order, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stored, ok := m.orders[orderUID]
+	if !ok {
+		return models.Order{}, ErrNotFound
+	}
+	return copyOrder(stored.order), nil
+}
+
+func (m *MemoryStorage) LoadOrdersFromDB(ctx context.Context) (map[string]models.Order, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	orders := make(map[string]models.Order, loadLimit)
+	for _, stored := range m.newestFirst(func(memoryOrder) bool { return true }) {
+		if len(orders) == loadLimit {
+			break
+		}
+		orders[stored.order.OrderUID] = copyOrder(stored.order)
+	}
+	return orders, nil
+}
+
+func (m *MemoryStorage) ListOrders(ctx context.Context, filter OrderFilter) (OrderPage, error) {
+	limit := listLimit(filter.Limit)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	matching := m.newestFirst(func(stored memoryOrder) bool {
+		return matchesFilter(stored, filter)
+	})
+
+	page := OrderPage{Orders: []models.Order{}}
+	if len(matching) > limit {
+		matching = matching[:limit]
+		last := matching[limit-1]
+		page.Next = &Cursor{DateCreated: last.created, OrderUID: last.order.OrderUID}
+	}
+	for _, stored := range matching {
+		page.Orders = append(page.Orders, copyOrder(stored.order))
+	}
+	return page, nil
+}
+
+// newestFirst returns the orders accepted by keep ordered by (date_created,
+// order_uid) descending. Must be called with mu held.
+func (m *MemoryStorage) newestFirst(keep func(memoryOrder) bool) []memoryOrder {
+	var orders []memoryOrder
+	for _, stored := range m.orders {
+		if keep(stored) {
+			orders = append(orders, stored)
+		}
+	}
+	slices.SortFunc(orders, func(a, b memoryOrder) int {
+		return -compareOrderKeys(a.created, a.order.OrderUID, b.created, b.order.OrderUID)
+	})
+	return orders
+}
+
+func compareOrderKeys(aCreated time.Time, aUID string, bCreated time.Time, bUID string) int {
+	return cmp.Or(aCreated.Compare(bCreated), cmp.Compare(aUID, bUID))
+}
+
+func matchesFilter(stored memoryOrder, filter OrderFilter) bool {
+	order := stored.order
+	switch {
+	case filter.CustomerID != "" && order.CustomerID != filter.CustomerID,
+		filter.TrackNumber != "" && order.TrackNumber != filter.TrackNumber,
+		filter.DeliveryService != "" && order.DeliveryService != filter.DeliveryService,
+		!filter.CreatedFrom.IsZero() && stored.created.Before(storedTime(filter.CreatedFrom)),
+		!filter.CreatedTo.IsZero() && stored.created.After(storedTime(filter.CreatedTo)),
+		filter.Currency != "" && order.Payment.Currency != filter.Currency,
+		filter.Provider != "" && order.Payment.Provider != filter.Provider:
+		return false
+	}
+
+	if filter.After != nil &&
+		compareOrderKeys(stored.created, order.OrderUID, filter.After.DateCreated, filter.After.OrderUID) >= 0 {
+		return false
+	}
+
+	if filter.Brand == "" && filter.NmID == 0 {
+		return true
+	}
+	return slices.ContainsFunc(order.Items, func(item models.Item) bool {
+		return (filter.Brand == "" || item.Brand == filter.Brand) &&
+			(filter.NmID == 0 || item.NmID == filter.NmID)
+	})
+}
+
+// PublishPendingEvents hands up to limit unsent events, oldest first, to
+// publish and marks them sent if it succeeds.
+func (m *MemoryStorage) PublishPendingEvents(ctx context.Context, limit int, publish func(context.Context, []OutboxEvent) error) (int, error) {
+	m.publishing.Lock()
+	defer m.publishing.Unlock()
+
+	var pending []OutboxEvent
+	m.mu.RLock()
+	for _, event := range m.events {
+		if len(pending) == limit {
+			break
+		}
+		if event.sentAt.IsZero() {
+			pending = append(pending, event.OutboxEvent)
+		}
+	}
+	m.mu.RUnlock()
+
+	if len(pending) == 0 {
+		return 0, nil
+	}
+	err := publish(ctx, pending)
+	if err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	sent := len(pending)
+	for i := range m.events {
+		if len(pending) > 0 && m.events[i].ID == pending[0].ID {
+			m.events[i].sentAt = now
+			pending = pending[1:]
+		}
+	}
+	return sent, nil
+}
+
+// DeleteSentEvents removes events published before sentBefore.
+func (m *MemoryStorage) DeleteSentEvents(ctx context.Context, sentBefore time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	before := len(m.events)
+	m.events = slices.DeleteFunc(m.events, func(event memoryEvent) bool {
+		return !event.sentAt.IsZero() && event.sentAt.Before(sentBefore)
+	})
+	return int64(before - len(m.events)), nil
+}
+
+// copyOrder returns order with its own items, ordered by chrt_id.
+func copyOrder(order models.Order) models.Order {
+	order.Items = append([]models.Item{}, order.Items...)
+	sortItems(order.Items)
+	return order
+}
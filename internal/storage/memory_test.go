@@ -0,0 +1,15 @@
+package database_test
+
+import (
+	"testing"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/config"
+	database "github.com/ArtemKVD/WB-TechL0/internal/storage"
+	"github.com/ArtemKVD/WB-TechL0/internal/storage/storagetest"
+)
+
+func TestMemoryStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T, policy string) database.OrderStorage {
+		return database.NewMemoryStorage(config.DatabaseConfig{ConflictPolicy: policy})
+	})
+}
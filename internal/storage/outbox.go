@@ -0,0 +1,143 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/logger"
+	"github.com/ArtemKVD/WB-TechL0/pkg/events"
+	"github.com/ArtemKVD/WB-TechL0/pkg/models"
+	"github.com/lib/pq"
+)
+
+// OutboxEvent is an event stored in order_events, waiting to be published.
+type OutboxEvent struct {
+	ID            int64
+	EventID       string
+	EventType     string
+	SchemaVersion int
+	OrderUID      string
+	Payload       []byte
+	CreatedAt     time.Time
+}
+
+// PublishPendingEvents locks up to limit unsent events, oldest first, hands
+// them to publish and marks them sent if it succeeds. Locked rows are
+// skipped by concurrent callers, so several relays can run side by side.
+// If marking fails after publish succeeded, the events are published again
+// later: delivery is at least once.
+func (d *Database) PublishPendingEvents(ctx context.Context, limit int, publish func(context.Context, []OutboxEvent) error) (int, error) {
+	ctx, cancel := withTimeout(ctx, d.cfg.WriteTimeout)
+	defer cancel()
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		err := tx.Rollback()
+		if err != nil && !errors.Is(err, sql.ErrTxDone) {
+			logger.Log.Error("Rollback error: ", err)
+		}
+	}()
+
+	pending, err := pendingEvents(ctx, tx, limit)
+	if err != nil || len(pending) == 0 {
+		return 0, err
+	}
+
+	err = publish(ctx, pending)
+	if err != nil {
+		return 0, err
+	}
+
+	ids := make([]int64, len(pending))
+	for i, event := range pending {
+		ids[i] = event.ID
+	}
+	_, err = tx.ExecContext(ctx,
+		`UPDATE order_events SET sent_at = now() WHERE id = ANY($1)`,
+		pq.Array(ids),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+	return len(pending), nil
+}
+
+// DeleteSentEvents removes events published before sentBefore.
+func (d *Database) DeleteSentEvents(ctx context.Context, sentBefore time.Time) (int64, error) {
+	ctx, cancel := withTimeout(ctx, d.cfg.WriteTimeout)
+	defer cancel()
+
+	result, err := d.db.ExecContext(ctx,
+		`DELETE FROM order_events WHERE sent_at IS NOT NULL AND sent_at < $1`,
+		sentBefore,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func pendingEvents(ctx context.Context, tx *sql.Tx, limit int) ([]OutboxEvent, error) {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id, event_id, event_type, schema_version, order_uid, payload, created_at
+		FROM order_events
+		WHERE sent_at IS NULL
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED`,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	var pending []OutboxEvent
+	for rows.Next() {
+		var event OutboxEvent
+		err := rows.Scan(&event.ID, &event.EventID, &event.EventType, &event.SchemaVersion, &event.OrderUID, &event.Payload, &event.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		pending = append(pending, event)
+	}
+	return pending, rows.Err()
+}
+
+// insertOrderEvent records that order was persisted, in the transaction
+// that persisted it.
+func insertOrderEvent(ctx context.Context, tx *sql.Tx, order models.Order, action string) error {
+	row, err := orderEventRow(order, action, time.Now())
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, orderEventsInsert+` VALUES ($1, $2, $3, $4, $5)`, row...)
+	return err
+}
+
+const orderEventsInsert = `INSERT INTO order_events (event_id, event_type, schema_version, order_uid, payload)`
+
+func orderEventRow(order models.Order, action string, now time.Time) ([]any, error) {
+	event, err := events.NewOrderAccepted(order, action, now)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	return []any{event.EventID, event.EventType, event.SchemaVersion, event.OrderUID, string(payload)}, nil
+}
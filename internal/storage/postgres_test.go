@@ -0,0 +1,45 @@
+package database_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/config"
+	"github.com/ArtemKVD/WB-TechL0/internal/migrations"
+	database "github.com/ArtemKVD/WB-TechL0/internal/storage"
+	"github.com/ArtemKVD/WB-TechL0/internal/storage/storagetest"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDatabase runs the suite against a Postgres database named by the
+// POSTGRES_* variables. It is skipped unless TEST_POSTGRES is set, and
+// truncates the order tables of that database.
+func TestDatabase(t *testing.T) {
+	if os.Getenv("TEST_POSTGRES") == "" {
+		t.Skip("set TEST_POSTGRES=1 and POSTGRES_* to run against Postgres")
+	}
+
+	storagetest.Run(t, func(t *testing.T, policy string) database.OrderStorage {
+		ctx := context.Background()
+		storage := database.NewDatabase(config.DatabaseConfig{
+			Host:           os.Getenv("POSTGRES_HOST"),
+			Port:           os.Getenv("POSTGRES_PORT"),
+			User:           os.Getenv("POSTGRES_USER"),
+			Password:       os.Getenv("POSTGRES_PASSWORD"),
+			Name:           os.Getenv("POSTGRES_DB"),
+			SSLMode:        os.Getenv("POSTGRES_SSLMODE"),
+			ConflictPolicy: policy,
+		})
+		require.NoError(t, storage.Connect(ctx))
+		t.Cleanup(func() { _ = storage.Close() })
+
+		migrator, err := migrations.New(storage.DB())
+		require.NoError(t, err)
+		_, err = migrator.Up(ctx)
+		require.NoError(t, err)
+		_, err = storage.DB().ExecContext(ctx, `TRUNCATE orders, delivery, payment, items, order_events`)
+		require.NoError(t, err)
+		return storage
+	})
+}
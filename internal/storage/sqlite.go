@@ -0,0 +1,522 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/config"
+	"github.com/ArtemKVD/WB-TechL0/internal/logger"
+	"github.com/ArtemKVD/WB-TechL0/pkg/events"
+	"github.com/ArtemKVD/WB-TechL0/pkg/models"
+	_ "modernc.org/sqlite"
+)
+
+//go:embed sqlite_schema.sql
+var sqliteSchema string
+
+// sqliteTimeLayout is fixed width, so stored timestamps sort as text.
+const sqliteTimeLayout = "2006-01-02T15:04:05.000000Z"
+
+var sqliteList = listDialect{
+	timestamp: func(t time.Time) any { return storedTime(t).Format(sqliteTimeLayout) },
+	cursor:    "(%s, %s)",
+}
+
+// SQLiteStorage stores orders in a SQLite database file, with the tables of
+// the Postgres schema and the same idempotency, conflict, listing and
+// outbox rules as Database. The schema is created on Connect.
+//
+// SQLite allows one writer at a time, so the storage uses a single
+// connection and suits a single consumer process.
+type SQLiteStorage struct {
+	path string
+	cfg  config.DatabaseConfig
+	db   *sql.DB
+
+	// publishing serializes PublishPendingEvents, which publishes outside
+	// of a transaction so the connection is not held while waiting for the
+	// broker.
+	publishing sync.Mutex
+}
+
+func NewSQLiteStorage(path string, cfg config.DatabaseConfig) *SQLiteStorage {
+	cfg.ConflictPolicy = conflictPolicy(cfg.ConflictPolicy)
+	return &SQLiteStorage{path: path, cfg: cfg}
+}
+
+func (s *SQLiteStorage) GetConnString() string {
+	return "file:" + s.path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+}
+
+func (s *SQLiteStorage) Connect(ctx context.Context) error {
+	db, err := sql.Open("sqlite", s.GetConnString())
+	if err != nil {
+		return err
+	}
+	db.SetMaxOpenConns(1)
+	s.db = db
+
+	ctx, cancel := withTimeout(ctx, s.cfg.ConnectTimeout)
+	defer cancel()
+	_, err = db.ExecContext(ctx, sqliteSchema)
+	if err != nil {
+		return fmt.Errorf("create sqlite schema: %w", err)
+	}
+	return nil
+}
+
+// Ping checks that the database file is usable.
+func (s *SQLiteStorage) Ping(ctx context.Context) error {
+	if s.db == nil {
+		return errors.New("database is not connected")
+	}
+
+	ctx, cancel := withTimeout(ctx, s.cfg.ReadTimeout)
+	defer cancel()
+	return s.db.PingContext(ctx)
+}
+
+// DB exposes the underlying pool for diagnostics.
+func (s *SQLiteStorage) DB() *sql.DB {
+	return s.db
+}
+
+func (s *SQLiteStorage) Close() error {
+	if s.db != nil {
+		return s.db.Close()
+	}
+	return nil
+}
+
+func (s *SQLiteStorage) SaveOrder(ctx context.Context, order models.Order) error {
+	ctx, cancel := withTimeout(ctx, s.cfg.WriteTimeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Log.Error("Begin transaction error ", err)
+		return err
+	}
+	defer rollback(tx)
+
+	err = saveSQLiteOrderTx(ctx, tx, order, s.cfg.ConflictPolicy)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SaveOrders saves the orders in one transaction, each in a savepoint of
+// its own, with the semantics of Database.SaveOrders.
+func (s *SQLiteStorage) SaveOrders(ctx context.Context, orders []models.Order) ([]error, error) {
+	ctx, cancel := withTimeout(ctx, s.cfg.WriteTimeout)
+	defer cancel()
+
+	results := make([]error, len(orders))
+	if len(orders) == 0 {
+		return results, nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Log.Error("Begin transaction error ", err)
+		return nil, err
+	}
+	defer rollback(tx)
+
+	for i, order := range orders {
+		orderErr, err := withSavepoint(ctx, tx, func() error {
+			return saveSQLiteOrderTx(ctx, tx, order, s.cfg.ConflictPolicy)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if batchFailed(orderErr) {
+			return nil, orderErr
+		}
+		results[i] = orderErr
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logger.Log.Error("Commit transaction error", err)
+		return nil, err
+	}
+	return results, nil
+}
+
+// saveSQLiteOrderTx is saveOrderTx in SQLite dialect.
+func saveSQLiteOrderTx(ctx context.Context, tx *sql.Tx, order models.Order, policy string) error {
+	hash, err := orderHash(order)
+	if err != nil {
+		return err
+	}
+	created, err := storedDateCreated(order)
+	if err != nil {
+		return err
+	}
+	columns := []any{
+		order.OrderUID, order.TrackNumber, order.Entry, order.Locale, order.InternalSignature, order.CustomerID,
+		order.DeliveryService, order.ShardKey, order.SMID, created.Format(sqliteTimeLayout), order.OOFShard, hash,
+	}
+
+	var storedHash sql.NullString
+	err = tx.QueryRowContext(ctx,
+		`SELECT payload_hash FROM orders WHERE order_uid = $1`,
+		order.OrderUID,
+	).Scan(&storedHash)
+
+	var action string
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		action = events.ActionCreated
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO orders (order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, payload_hash)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+			columns...,
+		)
+	case err != nil:
+		return err
+	case storedHash.Valid && storedHash.String == hash:
+		logger.Log.WithField("order_uid", order.OrderUID).Info("Duplicate order ignored")
+		return nil
+	case policy == ConflictUpdate || !storedHash.Valid:
+		action = events.ActionUpdated
+		err = updateSQLiteOrder(ctx, tx, order, columns)
+	default:
+		return fmt.Errorf("%w: %s", ErrOrderConflict, order.OrderUID)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = upsertSQLiteOrderDetails(ctx, tx, order)
+	if err != nil {
+		return err
+	}
+	return insertSQLiteOrderEvent(ctx, tx, order, action)
+}
+
+func updateSQLiteOrder(ctx context.Context, tx *sql.Tx, order models.Order, columns []any) error {
+	_, err := tx.ExecContext(ctx,
+		`UPDATE orders SET track_number = $2, entry = $3, locale = $4, internal_signature = $5, customer_id = $6, delivery_service = $7,
+			shardkey = $8, sm_id = $9, date_created = $10, oof_shard = $11, payload_hash = $12
+		WHERE order_uid = $1`,
+		columns...,
+	)
+	if err != nil {
+		return err
+	}
+
+	query := `DELETE FROM items WHERE order_uid = $1`
+	args := []any{order.OrderUID}
+	if len(order.Items) > 0 {
+		placeholders := make([]string, len(order.Items))
+		for i, item := range order.Items {
+			args = append(args, item.RID)
+			placeholders[i] = fmt.Sprintf("$%d", len(args))
+		}
+		query += ` AND rid NOT IN (` + strings.Join(placeholders, ", ") + `)`
+	}
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	logger.Log.WithField("order_uid", order.OrderUID).Info("Order updated")
+	return nil
+}
+
+func upsertSQLiteOrderDetails(ctx context.Context, tx *sql.Tx, order models.Order) error {
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO delivery (order_uid, name, phone, zip, city, address, region, email)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (order_uid) DO UPDATE SET name = excluded.name, phone = excluded.phone, zip = excluded.zip, city = excluded.city,
+			address = excluded.address, region = excluded.region, email = excluded.email`,
+		order.OrderUID, order.Delivery.Name, order.Delivery.Phone, order.Delivery.Zip, order.Delivery.City, order.Delivery.Address, order.Delivery.Region, order.Delivery.Email,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO payment (order_uid, "transaction", request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (order_uid) DO UPDATE SET "transaction" = excluded."transaction", request_id = excluded.request_id, currency = excluded.currency,
+			provider = excluded.provider, amount = excluded.amount, payment_dt = excluded.payment_dt, bank = excluded.bank,
+			delivery_cost = excluded.delivery_cost, goods_total = excluded.goods_total, custom_fee = excluded.custom_fee`,
+		order.OrderUID, order.Payment.Transaction, order.Payment.RequestID, order.Payment.Currency, order.Payment.Provider, order.Payment.Amount, order.Payment.PaymentDt, order.Payment.Bank, order.Payment.DeliveryCost, order.Payment.GoodsTotal, order.Payment.CustomFee,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range order.Items {
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO items (order_uid, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+			ON CONFLICT (order_uid, rid) DO UPDATE SET chrt_id = excluded.chrt_id, track_number = excluded.track_number, price = excluded.price,
+				name = excluded.name, sale = excluded.sale, size = excluded.size, total_price = excluded.total_price, nm_id = excluded.nm_id,
+				brand = excluded.brand, status = excluded.status`,
+			order.OrderUID, item.ChrtID, item.TrackNumber, item.Price, item.RID, item.Name, item.Sale, item.Size, item.TotalPrice, item.NmID, item.Brand, item.Status,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func insertSQLiteOrderEvent(ctx context.Context, tx *sql.Tx, order models.Order, action string) error {
+	now := time.Now()
+	row, err := orderEventRow(order, action, now)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO order_events (event_id, event_type, schema_version, order_uid, payload, created_at) VALUES ($1, $2, $3, $4, $5, $6)`,
+		append(row, now.UTC().Format(sqliteTimeLayout))...,
+	)
+	return err
+}
+
+func (s *SQLiteStorage) GetOrder(ctx context.Context, orderUID string) (models.Order, error) {
+	ctx, cancel := withTimeout(ctx, s.cfg.ReadTimeout)
+	defer cancel()
+
+	orders, err := sqliteOrdersByUIDs(ctx, s.db, []string{orderUID})
+	if err != nil {
+		return models.Order{}, err
+	}
+	if len(orders) == 0 {
+		return models.Order{}, ErrNotFound
+	}
+	return orders[0], nil
+}
+
+func (s *SQLiteStorage) LoadOrdersFromDB(ctx context.Context) (map[string]models.Order, error) {
+	ctx, cancel := withTimeout(ctx, s.cfg.ReadTimeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer rollback(tx)
+
+	orderUIDs, _, err := queryOrderKeys(ctx, tx,
+		`SELECT order_uid, date_created FROM orders ORDER BY date_created DESC NULLS LAST, order_uid DESC LIMIT $1`,
+		loadLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	orders, err := sqliteOrdersByUIDs(ctx, tx, orderUIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	cache := make(map[string]models.Order, len(orders))
+	for _, order := range orders {
+		cache[order.OrderUID] = order
+	}
+	return cache, nil
+}
+
+func (s *SQLiteStorage) ListOrders(ctx context.Context, filter OrderFilter) (OrderPage, error) {
+	ctx, cancel := withTimeout(ctx, s.cfg.ReadTimeout)
+	defer cancel()
+	limit := listLimit(filter.Limit)
+
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return OrderPage{}, err
+	}
+	defer rollback(tx)
+
+	query, args := sqliteList.buildListQuery(filter, limit+1)
+	orderUIDs, dates, err := queryOrderKeys(ctx, tx, query, args...)
+	if err != nil {
+		return OrderPage{}, err
+	}
+
+	page := OrderPage{Orders: []models.Order{}}
+	if len(orderUIDs) > limit {
+		orderUIDs = orderUIDs[:limit]
+		page.Next = &Cursor{DateCreated: dates[limit-1], OrderUID: orderUIDs[limit-1]}
+	}
+	if len(orderUIDs) == 0 {
+		return page, nil
+	}
+
+	page.Orders, err = sqliteOrdersByUIDs(ctx, tx, orderUIDs)
+	if err != nil {
+		return OrderPage{}, err
+	}
+	return page, nil
+}
+
+// queryOrderKeys runs a query selecting order_uid and date_created.
+func queryOrderKeys(ctx context.Context, tx *sql.Tx, query string, args ...any) ([]string, []time.Time, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer closeRows(rows)
+
+	var (
+		orderUIDs []string
+		dates     []time.Time
+	)
+	for rows.Next() {
+		var (
+			orderUID    string
+			dateCreated sql.NullString
+		)
+		err := rows.Scan(&orderUID, &dateCreated)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var created time.Time
+		if dateCreated.Valid {
+			created, err = time.Parse(sqliteTimeLayout, dateCreated.String)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		orderUIDs = append(orderUIDs, orderUID)
+		dates = append(dates, created)
+	}
+	return orderUIDs, dates, rows.Err()
+}
+
+type sqliteQuerier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// sqliteOrdersByUIDs is getOrdersByUIDs in SQLite dialect.
+func sqliteOrdersByUIDs(ctx context.Context, db sqliteQuerier, orderUIDs []string) ([]models.Order, error) {
+	if len(orderUIDs) == 0 {
+		return []models.Order{}, nil
+	}
+
+	placeholders := make([]string, len(orderUIDs))
+	args := make([]any, len(orderUIDs))
+	for i, orderUID := range orderUIDs {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = orderUID
+	}
+	query := strings.Replace(orderColumns, "p.transaction", `p."transaction"`, 1) +
+		` WHERE o.order_uid IN (` + strings.Join(placeholders, ", ") + `) ORDER BY o.order_uid, i.chrt_id`
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	return scanOrders(rows, orderUIDs)
+}
+
+// PublishPendingEvents hands up to limit unsent events, oldest first, to
+// publish and marks them sent if it succeeds. If marking fails, the events
+// are published again later: delivery is at least once.
+func (s *SQLiteStorage) PublishPendingEvents(ctx context.Context, limit int, publish func(context.Context, []OutboxEvent) error) (int, error) {
+	s.publishing.Lock()
+	defer s.publishing.Unlock()
+
+	ctx, cancel := withTimeout(ctx, s.cfg.WriteTimeout)
+	defer cancel()
+
+	pending, err := s.pendingEvents(ctx, limit)
+	if err != nil || len(pending) == 0 {
+		return 0, err
+	}
+
+	err = publish(ctx, pending)
+	if err != nil {
+		return 0, err
+	}
+
+	args := []any{time.Now().UTC().Format(sqliteTimeLayout)}
+	placeholders := make([]string, len(pending))
+	for i, event := range pending {
+		args = append(args, event.ID)
+		placeholders[i] = fmt.Sprintf("$%d", len(args))
+	}
+	_, err = s.db.ExecContext(ctx,
+		`UPDATE order_events SET sent_at = $1 WHERE id IN (`+strings.Join(placeholders, ", ")+`)`,
+		args...,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return len(pending), nil
+}
+
+func (s *SQLiteStorage) pendingEvents(ctx context.Context, limit int) ([]OutboxEvent, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, event_id, event_type, schema_version, order_uid, payload, created_at
+		FROM order_events
+		WHERE sent_at IS NULL
+		ORDER BY id
+		LIMIT $1`,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	var pending []OutboxEvent
+	for rows.Next() {
+		var (
+			event     OutboxEvent
+			payload   string
+			createdAt string
+		)
+		err := rows.Scan(&event.ID, &event.EventID, &event.EventType, &event.SchemaVersion, &event.OrderUID, &payload, &createdAt)
+		if err != nil {
+			return nil, err
+		}
+		event.Payload = []byte(payload)
+		event.CreatedAt, err = time.Parse(sqliteTimeLayout, createdAt)
+		if err != nil {
+			return nil, err
+		}
+		pending = append(pending, event)
+	}
+	return pending, rows.Err()
+}
+
+// DeleteSentEvents removes events published before sentBefore.
+func (s *SQLiteStorage) DeleteSentEvents(ctx context.Context, sentBefore time.Time) (int64, error) {
+	ctx, cancel := withTimeout(ctx, s.cfg.WriteTimeout)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx,
+		`DELETE FROM order_events WHERE sent_at IS NOT NULL AND sent_at < $1`,
+		sentBefore.UTC().Format(sqliteTimeLayout),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func rollback(tx *sql.Tx) {
+	err := tx.Rollback()
+	if err != nil && !errors.Is(err, sql.ErrTxDone) {
+		logger.Log.Error("Rollback error: ", err)
+	}
+}
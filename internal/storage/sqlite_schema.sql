@@ -0,0 +1,82 @@
+-- Schema of SQLiteStorage: the tables of the Postgres migrations, with
+-- timestamps stored as fixed-width UTC text so they sort as strings.
+CREATE TABLE IF NOT EXISTS orders (
+    order_uid TEXT PRIMARY KEY,
+    track_number TEXT,
+    entry TEXT,
+    locale TEXT,
+    internal_signature TEXT,
+    customer_id TEXT,
+    delivery_service TEXT,
+    shardkey TEXT,
+    sm_id INTEGER,
+    date_created TEXT,
+    oof_shard TEXT,
+    payload_hash TEXT
+);
+
+CREATE TABLE IF NOT EXISTS delivery (
+    id INTEGER PRIMARY KEY,
+    order_uid TEXT NOT NULL UNIQUE REFERENCES orders (order_uid) ON DELETE CASCADE,
+    name TEXT,
+    phone TEXT,
+    zip TEXT,
+    city TEXT,
+    address TEXT,
+    region TEXT,
+    email TEXT
+);
+
+CREATE TABLE IF NOT EXISTS payment (
+    order_uid TEXT PRIMARY KEY REFERENCES orders (order_uid) ON DELETE CASCADE,
+    "transaction" TEXT,
+    request_id TEXT,
+    currency TEXT,
+    provider TEXT,
+    amount INTEGER,
+    payment_dt INTEGER,
+    bank TEXT,
+    delivery_cost INTEGER,
+    goods_total INTEGER,
+    custom_fee INTEGER
+);
+
+CREATE TABLE IF NOT EXISTS items (
+    id INTEGER PRIMARY KEY,
+    order_uid TEXT NOT NULL REFERENCES orders (order_uid) ON DELETE CASCADE,
+    chrt_id INTEGER,
+    track_number TEXT,
+    price INTEGER,
+    rid TEXT,
+    name TEXT,
+    sale INTEGER,
+    size TEXT,
+    total_price INTEGER,
+    nm_id INTEGER,
+    brand TEXT,
+    status INTEGER,
+    UNIQUE (order_uid, rid)
+);
+
+CREATE TABLE IF NOT EXISTS order_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_id TEXT NOT NULL UNIQUE,
+    event_type TEXT NOT NULL,
+    schema_version INTEGER NOT NULL,
+    order_uid TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    created_at TEXT NOT NULL,
+    sent_at TEXT
+);
+
+CREATE INDEX IF NOT EXISTS items_order_uid_idx ON items (order_uid);
+CREATE INDEX IF NOT EXISTS orders_date_created_order_uid_idx ON orders (date_created DESC, order_uid DESC);
+CREATE INDEX IF NOT EXISTS orders_customer_id_idx ON orders (customer_id);
+CREATE INDEX IF NOT EXISTS orders_track_number_idx ON orders (track_number);
+CREATE INDEX IF NOT EXISTS orders_delivery_service_idx ON orders (delivery_service);
+CREATE INDEX IF NOT EXISTS payment_currency_idx ON payment (currency);
+CREATE INDEX IF NOT EXISTS payment_provider_idx ON payment (provider);
+CREATE INDEX IF NOT EXISTS items_brand_idx ON items (brand);
+CREATE INDEX IF NOT EXISTS items_nm_id_idx ON items (nm_id);
+CREATE INDEX IF NOT EXISTS order_events_pending_idx ON order_events (id) WHERE sent_at IS NULL;
+CREATE INDEX IF NOT EXISTS order_events_sent_at_idx ON order_events (sent_at) WHERE sent_at IS NOT NULL;
@@ -0,0 +1,40 @@
+package database_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/config"
+	database "github.com/ArtemKVD/WB-TechL0/internal/storage"
+	"github.com/ArtemKVD/WB-TechL0/internal/storage/storagetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T, policy string) database.OrderStorage {
+		storage := database.NewSQLiteStorage(filepath.Join(t.TempDir(), "orders.db"), config.DatabaseConfig{ConflictPolicy: policy})
+		require.NoError(t, storage.Connect(context.Background()))
+		t.Cleanup(func() { _ = storage.Close() })
+		return storage
+	})
+}
+
+func TestSQLiteStorage_OrderWithoutHash(t *testing.T) {
+	ctx := context.Background()
+	storage := database.NewSQLiteStorage(filepath.Join(t.TempDir(), "orders.db"), config.DatabaseConfig{ConflictPolicy: database.ConflictReject})
+	require.NoError(t, storage.Connect(ctx))
+	t.Cleanup(func() { _ = storage.Close() })
+
+	order := storagetest.Order("order1", 0)
+	require.NoError(t, storage.SaveOrder(ctx, order))
+	_, err := storage.DB().ExecContext(ctx, `UPDATE orders SET payload_hash = NULL`)
+	require.NoError(t, err)
+
+	require.NoError(t, storage.SaveOrder(ctx, order))
+
+	var hashed bool
+	require.NoError(t, storage.DB().QueryRowContext(ctx, `SELECT payload_hash IS NOT NULL FROM orders`).Scan(&hashed))
+	assert.True(t, hashed)
+}
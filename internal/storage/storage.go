@@ -0,0 +1,28 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/config"
+)
+
+// Names of the supported storage backends, as used in configuration.
+const (
+	Postgres = "postgres"
+	SQLite   = "sqlite"
+	Memory   = "memory"
+)
+
+// New returns the storage selected by cfg.Storage. It is not connected yet.
+func New(cfg *config.Config) (OrderStorage, error) {
+	switch cfg.Storage.Kind {
+	case Postgres:
+		return NewDatabase(cfg.Database), nil
+	case SQLite:
+		return NewSQLiteStorage(cfg.Storage.SQLitePath, cfg.Database), nil
+	case Memory:
+		return NewMemoryStorage(cfg.Database), nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Storage.Kind)
+	}
+}
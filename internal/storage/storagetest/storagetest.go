@@ -0,0 +1,433 @@
+// Package storagetest is a conformance suite that every
+// database.OrderStorage implementation must pass.
+package storagetest
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	database "github.com/ArtemKVD/WB-TechL0/internal/storage"
+	"github.com/ArtemKVD/WB-TechL0/pkg/events"
+	"github.com/ArtemKVD/WB-TechL0/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Factory returns an empty, connected storage that resolves changed
+// versions of an order with the given conflict policy. It is called once
+// per test and should release the storage with t.Cleanup.
+type Factory func(t *testing.T, policy string) database.OrderStorage
+
+// OutboxStore is implemented by storages that record order events. The
+// suite checks the outbox of those that do.
+type OutboxStore interface {
+	PublishPendingEvents(ctx context.Context, limit int, publish func(context.Context, []database.OutboxEvent) error) (int, error)
+	DeleteSentEvents(ctx context.Context, sentBefore time.Time) (int64, error)
+}
+
+// Run runs the suite against the storages returned by newStorage.
+func Run(t *testing.T, newStorage Factory) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, newStorage Factory)
+	}{
+		{"SaveAndGet", testSaveAndGet},
+		{"GetUnknown", testGetUnknown},
+		{"OrderWithoutItems", testOrderWithoutItems},
+		{"DuplicateIgnored", testDuplicateIgnored},
+		{"ConflictRejected", testConflictRejected},
+		{"ConflictUpdated", testConflictUpdated},
+		{"MissingDate", testMissingDate},
+		{"SaveOrders", testSaveOrders},
+		{"LoadOrders", testLoadOrders},
+		{"ListOrders", testListOrders},
+		{"ConcurrentSaves", testConcurrentSaves},
+		{"Outbox", testOutbox},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.run(t, newStorage)
+		})
+	}
+}
+
+var baseDate = time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC)
+
+// Order returns a complete order created minutes after a fixed date, with
+// two items listed out of chrt_id order.
+func Order(orderUID string, minutes int) models.Order {
+	return models.Order{
+		OrderUID:    orderUID,
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery: models.Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "test@gmail.com",
+		},
+		Payment: models.Payment{
+			Transaction:  orderUID,
+			Currency:     "USD",
+			Provider:     "wbpay",
+			Amount:       1917,
+			PaymentDt:    models.UnixTime{Time: time.Unix(1637907727, 0).UTC()},
+			Bank:         "alpha",
+			DeliveryCost: 1500,
+			GoodsTotal:   417,
+		},
+		Items: []models.Item{
+			{ChrtID: 9934931, TrackNumber: "WBILMTESTTRACK", Price: 100, RID: orderUID + "-2", Name: "Brush",
+				Size: "0", TotalPrice: 100, NmID: 2389213, Brand: "Oral-B", Status: models.ItemStatusAccepted},
+			{ChrtID: 9934930, TrackNumber: "WBILMTESTTRACK", Price: 453, RID: orderUID + "-1", Name: "Mascaras",
+				Sale: 30, Size: "0", TotalPrice: 317, NmID: 2389212, Brand: "Vivienne Sabo", Status: models.ItemStatusAccepted},
+		},
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		ShardKey:        "9",
+		SMID:            99,
+		DateCreated:     models.Timestamp{Time: baseDate.Add(time.Duration(minutes) * time.Minute)},
+		OOFShard:        "1",
+	}
+}
+
+// stored is order as a storage returns it: items ordered by chrt_id.
+func stored(order models.Order) models.Order {
+	order.Items = slices.Clone(order.Items)
+	slices.SortStableFunc(order.Items, func(a, b models.Item) int {
+		return cmp.Compare(a.ChrtID, b.ChrtID)
+	})
+	return order
+}
+
+func save(t *testing.T, storage database.OrderStorage, orders ...models.Order) {
+	t.Helper()
+	for _, order := range orders {
+		require.NoError(t, storage.SaveOrder(context.Background(), order))
+	}
+}
+
+func assertStored(t *testing.T, storage database.OrderStorage, want models.Order) {
+	t.Helper()
+	got, err := storage.GetOrder(context.Background(), want.OrderUID)
+	require.NoError(t, err)
+	assert.Equal(t, stored(want), got)
+}
+
+func testSaveAndGet(t *testing.T, newStorage Factory) {
+	storage := newStorage(t, database.ConflictReject)
+	order := Order("order1", 0)
+
+	save(t, storage, order)
+
+	assertStored(t, storage, order)
+}
+
+func testGetUnknown(t *testing.T, newStorage Factory) {
+	storage := newStorage(t, database.ConflictReject)
+
+	_, err := storage.GetOrder(context.Background(), "unknown")
+
+	assert.ErrorIs(t, err, database.ErrNotFound)
+}
+
+func testOrderWithoutItems(t *testing.T, newStorage Factory) {
+	storage := newStorage(t, database.ConflictReject)
+	order := Order("order1", 0)
+	order.Items = []models.Item{}
+
+	save(t, storage, order)
+
+	assertStored(t, storage, order)
+	loaded, err := storage.LoadOrdersFromDB(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]models.Order{"order1": order}, loaded)
+}
+
+func testDuplicateIgnored(t *testing.T, newStorage Factory) {
+	storage := newStorage(t, database.ConflictReject)
+	order := Order("order1", 0)
+
+	save(t, storage, order, order)
+
+	assertStored(t, storage, order)
+	assertEvents(t, storage, events.ActionCreated)
+}
+
+func testConflictRejected(t *testing.T, newStorage Factory) {
+	storage := newStorage(t, database.ConflictReject)
+	order := Order("order1", 0)
+	save(t, storage, order)
+
+	changed := order
+	changed.TrackNumber = "CHANGED"
+	err := storage.SaveOrder(context.Background(), changed)
+
+	assert.ErrorIs(t, err, database.ErrOrderConflict)
+	assertStored(t, storage, order)
+	assertEvents(t, storage, events.ActionCreated)
+}
+
+func testConflictUpdated(t *testing.T, newStorage Factory) {
+	storage := newStorage(t, database.ConflictUpdate)
+	order := Order("order1", 0)
+	save(t, storage, order)
+
+	changed := order
+	changed.TrackNumber = "CHANGED"
+	changed.Delivery.City = "Moscow"
+	changed.Payment.Amount = 2000
+	changed.Items = []models.Item{order.Items[0]}
+	changed.Items[0].Price = 150
+	save(t, storage, changed)
+
+	assertStored(t, storage, changed)
+	assertEvents(t, storage, events.ActionCreated, events.ActionUpdated)
+}
+
+func testMissingDate(t *testing.T, newStorage Factory) {
+	storage := newStorage(t, database.ConflictReject)
+	order := Order("order1", 0)
+	order.DateCreated = models.Timestamp{}
+
+	err := storage.SaveOrder(context.Background(), order)
+
+	require.Error(t, err)
+	_, err = storage.GetOrder(context.Background(), order.OrderUID)
+	assert.ErrorIs(t, err, database.ErrNotFound)
+}
+
+func testSaveOrders(t *testing.T, newStorage Factory) {
+	storage := newStorage(t, database.ConflictReject)
+	existing := Order("existing", 0)
+	save(t, storage, existing)
+
+	conflicting := existing
+	conflicting.TrackNumber = "CHANGED"
+	fresh := Order("fresh", 1)
+	freshChanged := fresh
+	freshChanged.TrackNumber = "CHANGED"
+	other := Order("other", 2)
+
+	results, err := storage.SaveOrders(context.Background(), []models.Order{fresh, existing, conflicting, other, freshChanged})
+
+	require.NoError(t, err)
+	require.Len(t, results, 5)
+	assert.NoError(t, results[0])
+	assert.NoError(t, results[1])
+	assert.ErrorIs(t, results[2], database.ErrOrderConflict)
+	assert.NoError(t, results[3])
+	assert.ErrorIs(t, results[4], database.ErrOrderConflict)
+	assertStored(t, storage, existing)
+	assertStored(t, storage, fresh)
+	assertStored(t, storage, other)
+
+	results, err = storage.SaveOrders(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+}
+
+func testLoadOrders(t *testing.T, newStorage Factory) {
+	storage := newStorage(t, database.ConflictReject)
+
+	loaded, err := storage.LoadOrdersFromDB(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, loaded)
+
+	orders := []models.Order{Order("order1", 0), Order("order2", 1), Order("order3", 2)}
+	save(t, storage, orders...)
+
+	loaded, err = storage.LoadOrdersFromDB(context.Background())
+	require.NoError(t, err)
+	want := make(map[string]models.Order)
+	for _, order := range orders {
+		want[order.OrderUID] = stored(order)
+	}
+	assert.Equal(t, want, loaded)
+}
+
+func testListOrders(t *testing.T, newStorage Factory) {
+	storage := newStorage(t, database.ConflictReject)
+
+	var orders []models.Order
+	for i := range 7 {
+		order := Order(fmt.Sprintf("order%d", i), i)
+		if i%2 == 1 {
+			order.CustomerID = "odd"
+			order.Payment.Currency = "RUB"
+		}
+		if i == 3 {
+			order.Items[0].Brand = "Colgate"
+		}
+		orders = append(orders, order)
+	}
+	// Same date as order6, so the order_uid breaks the tie.
+	tie := Order("order7", 6)
+	orders = append(orders, tie)
+	save(t, storage, orders...)
+
+	list := func(filter database.OrderFilter) []string {
+		t.Helper()
+		page, err := storage.ListOrders(context.Background(), filter)
+		require.NoError(t, err)
+		uids := []string{}
+		for _, order := range page.Orders {
+			uids = append(uids, order.OrderUID)
+		}
+		return uids
+	}
+
+	assert.Equal(t, []string{"order7", "order6", "order5", "order4", "order3", "order2", "order1", "order0"},
+		list(database.OrderFilter{}))
+	assert.Equal(t, []string{"order5", "order3", "order1"}, list(database.OrderFilter{CustomerID: "odd"}))
+	assert.Equal(t, []string{"order5", "order3", "order1"}, list(database.OrderFilter{Currency: "RUB"}))
+	assert.Equal(t, []string{}, list(database.OrderFilter{Provider: "unknown"}))
+	assert.Equal(t, []string{"order3"}, list(database.OrderFilter{Brand: "Colgate"}))
+	// Brand and nm_id of different items do not match.
+	assert.Equal(t, []string{}, list(database.OrderFilter{Brand: "Colgate", NmID: 2389212}))
+	assert.Equal(t, []string{"order4", "order3", "order2"}, list(database.OrderFilter{
+		CreatedFrom: baseDate.Add(2 * time.Minute),
+		CreatedTo:   baseDate.Add(4 * time.Minute),
+	}))
+	// Bounds are compared by wall clock, as date_created is stored without
+	// its offset.
+	offset := time.FixedZone("", 3*60*60)
+	assert.Equal(t, []string{"order4", "order3", "order2"}, list(database.OrderFilter{
+		CreatedFrom: time.Date(2021, 11, 26, 6, 24, 19, 0, offset),
+		CreatedTo:   time.Date(2021, 11, 26, 6, 26, 19, 0, offset),
+	}))
+
+	page, err := storage.ListOrders(context.Background(), database.OrderFilter{Limit: 3})
+	require.NoError(t, err)
+	require.Len(t, page.Orders, 3)
+	assert.Equal(t, stored(tie), page.Orders[0])
+
+	var walked []string
+	filter := database.OrderFilter{Limit: 3}
+	for {
+		page, err := storage.ListOrders(context.Background(), filter)
+		require.NoError(t, err)
+		for _, order := range page.Orders {
+			walked = append(walked, order.OrderUID)
+		}
+		if page.Next == nil {
+			break
+		}
+		filter.After = page.Next
+	}
+	assert.Equal(t, list(database.OrderFilter{}), walked)
+}
+
+func testConcurrentSaves(t *testing.T, newStorage Factory) {
+	storage := newStorage(t, database.ConflictReject)
+
+	const writers, perWriter = 4, 10
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*perWriter)
+	for w := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range perWriter {
+				errs <- storage.SaveOrder(context.Background(), Order(fmt.Sprintf("order%d-%d", w, i), i))
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	for w := range writers {
+		for i := range perWriter {
+			assertStored(t, storage, Order(fmt.Sprintf("order%d-%d", w, i), i))
+		}
+	}
+}
+
+func testOutbox(t *testing.T, newStorage Factory) {
+	storage := newStorage(t, database.ConflictUpdate)
+	outbox, ok := storage.(OutboxStore)
+	if !ok {
+		t.Skip("storage has no outbox")
+	}
+
+	order := Order("order1", 0)
+	changed := order
+	changed.TrackNumber = "CHANGED"
+	save(t, storage, order, order, changed)
+
+	var published []database.OutboxEvent
+	sent, err := outbox.PublishPendingEvents(context.Background(), 10, func(_ context.Context, batch []database.OutboxEvent) error {
+		published = append(published, batch...)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, sent)
+	require.Len(t, published, 2)
+
+	for i, action := range []string{events.ActionCreated, events.ActionUpdated} {
+		event := published[i]
+		assert.NotEmpty(t, event.EventID)
+		assert.Equal(t, events.TypeOrderAccepted, event.EventType)
+		assert.Equal(t, events.OrderAcceptedVersion, event.SchemaVersion)
+		assert.Equal(t, "order1", event.OrderUID)
+		assert.False(t, event.CreatedAt.IsZero())
+
+		var payload events.OrderAccepted
+		require.NoError(t, json.Unmarshal(event.Payload, &payload))
+		assert.Equal(t, action, payload.Action)
+		assert.Equal(t, event.EventID, payload.EventID)
+	}
+	assert.Less(t, published[0].ID, published[1].ID)
+
+	sent, err = outbox.PublishPendingEvents(context.Background(), 10, func(context.Context, []database.OutboxEvent) error {
+		t.Error("sent events are published again")
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Zero(t, sent)
+
+	deleted, err := outbox.DeleteSentEvents(context.Background(), time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, deleted)
+	deleted, err = outbox.DeleteSentEvents(context.Background(), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+}
+
+// assertEvents checks the actions of the pending outbox events, if the
+// storage has an outbox.
+func assertEvents(t *testing.T, storage database.OrderStorage, actions ...string) {
+	t.Helper()
+	outbox, ok := storage.(OutboxStore)
+	if !ok {
+		return
+	}
+
+	_, err := outbox.PublishPendingEvents(context.Background(), 100, func(_ context.Context, batch []database.OutboxEvent) error {
+		got := make([]string, len(batch))
+		for i, event := range batch {
+			var payload events.OrderAccepted
+			err := json.Unmarshal(event.Payload, &payload)
+			if err != nil {
+				return err
+			}
+			got[i] = payload.Action
+		}
+		assert.Equal(t, actions, got)
+		return nil
+	})
+	require.NoError(t, err)
+}
@@ -0,0 +1,137 @@
+package transport
+
+import (
+	"context"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// KafkaBroker creates subscribers and publishers connected to a Kafka
+// broker.
+type KafkaBroker struct {
+	address string
+}
+
+func NewKafkaBroker(address string) *KafkaBroker {
+	return &KafkaBroker{address: address}
+}
+
+func (b *KafkaBroker) Subscriber(topic, group string) Subscriber {
+	return NewKafkaSubscriber(b.address, group, topic)
+}
+
+func (b *KafkaBroker) Publisher(topic string) Publisher {
+	return NewKafkaPublisher(b.address, topic)
+}
+
+// KafkaSubscriber reads a topic as a member of a Kafka consumer group and
+// commits offsets on Ack.
+type KafkaSubscriber struct {
+	reader *kafka.Reader
+}
+
+func NewKafkaSubscriber(broker, groupID, topic string) *KafkaSubscriber {
+	return &KafkaSubscriber{reader: kafka.NewReader(kafka.ReaderConfig{
+		Brokers: []string{broker},
+		GroupID: groupID,
+		Topic:   topic,
+	})}
+}
+
+func (s *KafkaSubscriber) Fetch(ctx context.Context) (Message, error) {
+	message, err := s.reader.FetchMessage(ctx)
+	if err != nil {
+		return Message{}, err
+	}
+	return fromKafka(message), nil
+}
+
+func (s *KafkaSubscriber) Ack(ctx context.Context, msgs ...Message) error {
+	messages := make([]kafka.Message, len(msgs))
+	for i, msg := range msgs {
+		messages[i] = kafka.Message{Topic: msg.Topic, Partition: msg.Partition, Offset: msg.Offset}
+	}
+	return s.reader.CommitMessages(ctx, messages...)
+}
+
+// Nack does nothing: offsets that are not committed are delivered again
+// after a restart or rebalance.
+func (s *KafkaSubscriber) Nack(ctx context.Context, msgs ...Message) error {
+	return nil
+}
+
+// Stats returns the reader statistics, resetting its counters.
+func (s *KafkaSubscriber) Stats() kafka.ReaderStats {
+	return s.reader.Stats()
+}
+
+func (s *KafkaSubscriber) Close() error {
+	return s.reader.Close()
+}
+
+// KafkaPublisher writes to a Kafka topic, waiting for all in-sync replicas.
+// Messages are partitioned by key.
+type KafkaPublisher struct {
+	writer *kafka.Writer
+}
+
+func NewKafkaPublisher(broker, topic string) *KafkaPublisher {
+	return &KafkaPublisher{writer: &kafka.Writer{
+		Addr:                   kafka.TCP(broker),
+		Topic:                  topic,
+		Balancer:               &kafka.Hash{},
+		RequiredAcks:           kafka.RequireAll,
+		AllowAutoTopicCreation: true,
+	}}
+}
+
+func (p *KafkaPublisher) Publish(ctx context.Context, msgs ...Message) error {
+	messages := make([]kafka.Message, len(msgs))
+	for i, msg := range msgs {
+		messages[i] = toKafka(msg)
+	}
+	return p.writer.WriteMessages(ctx, messages...)
+}
+
+func (p *KafkaPublisher) Close() error {
+	return p.writer.Close()
+}
+
+func fromKafka(message kafka.Message) Message {
+	var headers []Header
+	if len(message.Headers) > 0 {
+		headers = make([]Header, len(message.Headers))
+		for i, header := range message.Headers {
+			headers[i] = Header{Key: header.Key, Value: header.Value}
+		}
+	}
+
+	return Message{
+		Topic:         message.Topic,
+		Partition:     message.Partition,
+		Offset:        message.Offset,
+		HighWaterMark: message.HighWaterMark,
+		Key:           message.Key,
+		Value:         message.Value,
+		Headers:       headers,
+		Time:          message.Time,
+	}
+}
+
+// toKafka leaves the topic and partition to the writer.
+func toKafka(msg Message) kafka.Message {
+	var headers []kafka.Header
+	if len(msg.Headers) > 0 {
+		headers = make([]kafka.Header, len(msg.Headers))
+		for i, header := range msg.Headers {
+			headers[i] = kafka.Header{Key: header.Key, Value: header.Value}
+		}
+	}
+
+	return kafka.Message{
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: headers,
+		Time:    msg.Time,
+	}
+}
@@ -0,0 +1,52 @@
+package transport
+
+import (
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKafkaMessageConversion(t *testing.T) {
+	now := time.Now()
+	message := kafka.Message{
+		Topic:         "orders",
+		Partition:     2,
+		Offset:        41,
+		HighWaterMark: 50,
+		Key:           []byte("order1"),
+		Value:         []byte("{}"),
+		Headers:       []kafka.Header{{Key: "trace-id", Value: []byte("abc")}},
+		Time:          now,
+	}
+
+	converted := fromKafka(message)
+	assert.Equal(t, Message{
+		Topic:         "orders",
+		Partition:     2,
+		Offset:        41,
+		HighWaterMark: 50,
+		Key:           []byte("order1"),
+		Value:         []byte("{}"),
+		Headers:       []Header{{Key: "trace-id", Value: []byte("abc")}},
+		Time:          now,
+	}, converted)
+
+	// The writer owns topic and partition assignment.
+	assert.Equal(t, kafka.Message{
+		Key:     []byte("order1"),
+		Value:   []byte("{}"),
+		Headers: []kafka.Header{{Key: "trace-id", Value: []byte("abc")}},
+		Time:    now,
+	}, toKafka(converted))
+}
+
+func TestPartitionForMatchesKafkaHash(t *testing.T) {
+	balancer := &kafka.Hash{}
+	partitions := []int{0, 1, 2, 3, 4, 5, 6}
+	for _, key := range []string{"order1", "b563feb7b2b84b6test", "x", "another-order-uid"} {
+		want := balancer.Balance(kafka.Message{Key: []byte(key)}, partitions...)
+		assert.Equal(t, want, partitionFor([]byte(key), len(partitions)), key)
+	}
+}
@@ -0,0 +1,295 @@
+package transport
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// MemoryBroker is an in-process broker with Kafka-like semantics: topics
+// are split into partitions by message key, and every consumer group keeps
+// its own committed offset per partition. Messages live as long as the
+// broker.
+//
+// Subscribers of the same group share one position; there is no
+// rebalancing. Closing a subscriber rewinds its group to the committed
+// offsets, so unacked messages go to the next subscriber, as they would
+// after a Kafka consumer restarts.
+type MemoryBroker struct {
+	partitions int
+
+	mu      sync.Mutex
+	topics  map[string]*memoryTopic
+	publish chan struct{} // closed and replaced on every publish
+}
+
+type memoryTopic struct {
+	partitions [][]Message
+	groups     map[string]*memoryGroup
+}
+
+type memoryGroup struct {
+	next      []int64
+	committed []int64
+}
+
+// NewMemoryBroker creates a broker whose topics have the given number of
+// partitions, at least one.
+func NewMemoryBroker(partitions int) *MemoryBroker {
+	if partitions < 1 {
+		partitions = 1
+	}
+	return &MemoryBroker{
+		partitions: partitions,
+		topics:     make(map[string]*memoryTopic),
+		publish:    make(chan struct{}),
+	}
+}
+
+// Publisher returns a publisher for topic.
+func (b *MemoryBroker) Publisher(topic string) Publisher {
+	return &MemoryPublisher{broker: b, topic: topic}
+}
+
+// Subscriber returns a subscriber reading topic as a member of group.
+func (b *MemoryBroker) Subscriber(topic, group string) Subscriber {
+	return &MemorySubscriber{broker: b, topic: topic, group: group, closed: make(chan struct{})}
+}
+
+// Messages returns a copy of every message published to topic, ordered by
+// partition and offset.
+func (b *MemoryBroker) Messages(topic string) []Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var messages []Message
+	for _, partition := range b.topicLocked(topic).partitions {
+		messages = append(messages, partition...)
+	}
+	return messages
+}
+
+// Committed returns the committed offset of group on each partition of
+// topic, that is the offset of the next message the group will process.
+func (b *MemoryBroker) Committed(topic, group string) []int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]int64(nil), b.groupLocked(topic, group).committed...)
+}
+
+func (b *MemoryBroker) topicLocked(name string) *memoryTopic {
+	topic, ok := b.topics[name]
+	if !ok {
+		topic = &memoryTopic{
+			partitions: make([][]Message, b.partitions),
+			groups:     make(map[string]*memoryGroup),
+		}
+		b.topics[name] = topic
+	}
+	return topic
+}
+
+func (b *MemoryBroker) groupLocked(topic, name string) *memoryGroup {
+	t := b.topicLocked(topic)
+	group, ok := t.groups[name]
+	if !ok {
+		group = &memoryGroup{
+			next:      make([]int64, b.partitions),
+			committed: make([]int64, b.partitions),
+		}
+		t.groups[name] = group
+	}
+	return group
+}
+
+// MemoryPublisher publishes to a topic of a MemoryBroker.
+type MemoryPublisher struct {
+	broker *MemoryBroker
+	topic  string
+
+	mu     sync.Mutex
+	closed bool
+}
+
+func (p *MemoryPublisher) Publish(ctx context.Context, msgs ...Message) error {
+	p.mu.Lock()
+	closed := p.closed
+	p.mu.Unlock()
+	if closed {
+		return ErrClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	b := p.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	topic := b.topicLocked(p.topic)
+	now := time.Now()
+	for _, msg := range msgs {
+		partition := partitionFor(msg.Key, len(topic.partitions))
+		msg.Topic = p.topic
+		msg.Partition = partition
+		msg.Offset = int64(len(topic.partitions[partition]))
+		msg.Headers = append([]Header(nil), msg.Headers...)
+		if msg.Time.IsZero() {
+			msg.Time = now
+		}
+		topic.partitions[partition] = append(topic.partitions[partition], msg)
+	}
+
+	close(b.publish)
+	b.publish = make(chan struct{})
+	return nil
+}
+
+func (p *MemoryPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	return nil
+}
+
+// MemorySubscriber reads a topic of a MemoryBroker for a consumer group.
+type MemorySubscriber struct {
+	broker *MemoryBroker
+	topic  string
+	group  string
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// Fetch returns the oldest message the group has not fetched yet, across
+// all partitions.
+func (s *MemorySubscriber) Fetch(ctx context.Context) (Message, error) {
+	b := s.broker
+	for {
+		if s.isClosed() {
+			return Message{}, ErrClosed
+		}
+
+		b.mu.Lock()
+		message, ok := s.nextLocked()
+		published := b.publish
+		b.mu.Unlock()
+		if ok {
+			return message, nil
+		}
+
+		select {
+		case <-published:
+		case <-s.closed:
+			return Message{}, ErrClosed
+		case <-ctx.Done():
+			return Message{}, ctx.Err()
+		}
+	}
+}
+
+func (s *MemorySubscriber) nextLocked() (Message, bool) {
+	topic := s.broker.topicLocked(s.topic)
+	group := s.broker.groupLocked(s.topic, s.group)
+
+	// Pick the partition with the oldest pending message, so one busy
+	// partition cannot starve the others.
+	best := -1
+	for p, partition := range topic.partitions {
+		if group.next[p] >= int64(len(partition)) {
+			continue
+		}
+		if best == -1 || partition[group.next[p]].Time.Before(topic.partitions[best][group.next[best]].Time) {
+			best = p
+		}
+	}
+	if best == -1 {
+		return Message{}, false
+	}
+
+	partition := topic.partitions[best]
+	message := partition[group.next[best]]
+	message.HighWaterMark = int64(len(partition))
+	message.Headers = append([]Header(nil), message.Headers...)
+	group.next[best]++
+	return message, true
+}
+
+// Ack commits the offsets following msgs.
+func (s *MemorySubscriber) Ack(ctx context.Context, msgs ...Message) error {
+	if s.isClosed() {
+		return ErrClosed
+	}
+
+	b := s.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	group := b.groupLocked(s.topic, s.group)
+	for _, msg := range msgs {
+		if msg.Offset+1 > group.committed[msg.Partition] {
+			group.committed[msg.Partition] = msg.Offset + 1
+		}
+	}
+	return nil
+}
+
+// Nack rewinds the group so msgs, and the messages fetched after them, are
+// delivered again.
+func (s *MemorySubscriber) Nack(ctx context.Context, msgs ...Message) error {
+	if s.isClosed() {
+		return ErrClosed
+	}
+
+	b := s.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	group := b.groupLocked(s.topic, s.group)
+	for _, msg := range msgs {
+		if msg.Offset < group.next[msg.Partition] {
+			group.next[msg.Partition] = max(msg.Offset, group.committed[msg.Partition])
+		}
+	}
+	return nil
+}
+
+// Close rewinds the group to its committed offsets and unblocks Fetch.
+func (s *MemorySubscriber) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.closed)
+
+		b := s.broker
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		group := b.groupLocked(s.topic, s.group)
+		copy(group.next, group.committed)
+	})
+	return nil
+}
+
+func (s *MemorySubscriber) isClosed() bool {
+	select {
+	case <-s.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// partitionFor hashes key the way kafka.Hash does, so keys map to the same
+// partitions on both transports. Messages without a key go to partition 0.
+func partitionFor(key []byte, partitions int) int {
+	if len(key) == 0 {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write(key)
+	partition := int32(h.Sum32()) % int32(partitions)
+	if partition < 0 {
+		partition = -partition
+	}
+	return int(partition)
+}
@@ -0,0 +1,136 @@
+package transport_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func publish(t *testing.T, publisher transport.Publisher, keys ...string) {
+	t.Helper()
+	for _, key := range keys {
+		err := publisher.Publish(context.Background(), transport.Message{
+			Key:     []byte(key),
+			Value:   []byte("value-" + key),
+			Headers: []transport.Header{{Key: "h", Value: []byte(key)}},
+		})
+		require.NoError(t, err)
+	}
+}
+
+func fetch(t *testing.T, subscriber transport.Subscriber, n int) []transport.Message {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	messages := make([]transport.Message, 0, n)
+	for range n {
+		message, err := subscriber.Fetch(ctx)
+		require.NoError(t, err)
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+func values(messages []transport.Message) []string {
+	result := make([]string, len(messages))
+	for i, message := range messages {
+		result[i] = string(message.Value)
+	}
+	return result
+}
+
+func TestMemoryBroker_PublishAndFetch(t *testing.T) {
+	broker := transport.NewMemoryBroker(3)
+	publish(t, broker.Publisher("orders"), "a", "b", "a", "c", "b")
+
+	messages := fetch(t, broker.Subscriber("orders", "group"), 5)
+
+	perKey := map[string][]int64{}
+	for _, message := range messages {
+		assert.Equal(t, "orders", message.Topic)
+		assert.Equal(t, []transport.Header{{Key: "h", Value: message.Key}}, message.Headers)
+		assert.False(t, message.Time.IsZero())
+		assert.Greater(t, message.HighWaterMark, message.Offset)
+		perKey[string(message.Key)] = append(perKey[string(message.Key)], message.Offset)
+	}
+	assert.Len(t, perKey["a"], 2)
+	assert.Less(t, perKey["a"][0], perKey["a"][1])
+	assert.Len(t, perKey["b"], 2)
+	assert.Less(t, perKey["b"][0], perKey["b"][1])
+}
+
+func TestMemoryBroker_FetchWaitsForPublish(t *testing.T) {
+	broker := transport.NewMemoryBroker(1)
+	subscriber := broker.Subscriber("orders", "group")
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		err := broker.Publisher("orders").Publish(context.Background(), transport.Message{Value: []byte("value-a")})
+		assert.NoError(t, err)
+	}()
+	assert.Equal(t, []string{"value-a"}, values(fetch(t, subscriber, 1)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := subscriber.Fetch(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	require.NoError(t, subscriber.Close())
+	_, err = subscriber.Fetch(context.Background())
+	assert.ErrorIs(t, err, transport.ErrClosed)
+}
+
+func TestMemoryBroker_RedeliversUnacked(t *testing.T) {
+	broker := transport.NewMemoryBroker(1)
+	publish(t, broker.Publisher("orders"), "a", "b", "c", "d")
+
+	first := broker.Subscriber("orders", "group")
+	messages := fetch(t, first, 3)
+	require.NoError(t, first.Ack(context.Background(), messages[1]))
+	assert.Equal(t, []int64{2}, broker.Committed("orders", "group"))
+	require.NoError(t, first.Close())
+
+	second := broker.Subscriber("orders", "group")
+	assert.Equal(t, []string{"value-c", "value-d"}, values(fetch(t, second, 2)))
+}
+
+func TestMemoryBroker_NackRewinds(t *testing.T) {
+	broker := transport.NewMemoryBroker(1)
+	publish(t, broker.Publisher("orders"), "a", "b", "c")
+
+	subscriber := broker.Subscriber("orders", "group")
+	messages := fetch(t, subscriber, 3)
+	require.NoError(t, subscriber.Ack(context.Background(), messages[0]))
+	require.NoError(t, subscriber.Nack(context.Background(), messages[1]))
+
+	assert.Equal(t, []string{"value-b", "value-c"}, values(fetch(t, subscriber, 2)))
+}
+
+func TestMemoryBroker_GroupsAreIndependent(t *testing.T) {
+	broker := transport.NewMemoryBroker(2)
+	keys := make([]string, 10)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%d", i)
+	}
+	publish(t, broker.Publisher("orders"), keys...)
+
+	one := fetch(t, broker.Subscriber("orders", "one"), len(keys))
+	two := fetch(t, broker.Subscriber("orders", "two"), len(keys))
+	assert.ElementsMatch(t, values(one), values(two))
+	assert.Len(t, broker.Messages("orders"), len(keys))
+	assert.Empty(t, broker.Messages("other"))
+}
+
+func TestMemoryPublisher_Closed(t *testing.T) {
+	publisher := transport.NewMemoryBroker(1).Publisher("orders")
+	require.NoError(t, publisher.Close())
+
+	err := publisher.Publish(context.Background(), transport.Message{Value: []byte("x")})
+	assert.ErrorIs(t, err, transport.ErrClosed)
+}
@@ -0,0 +1,436 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/config"
+	"github.com/ArtemKVD/WB-TechL0/internal/logger"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// natsKeyHeader carries the message key, which NATS messages do not have.
+const natsKeyHeader = "Message-Key"
+
+// natsDefaultAckWait is the ack wait the server applies when none is set.
+const natsDefaultAckWait = 30 * time.Second
+
+// NATSBroker creates subscribers and publishers on a NATS JetStream server.
+// Each topic is a subject stored in a stream of its own, named after the
+// topic, and each group is a durable pull consumer of that stream. Streams
+// and consumers are created on first use.
+type NATSBroker struct {
+	conn *nats.Conn
+	js   jetstream.JetStream
+	cfg  config.NATSConfig
+}
+
+func NewNATSBroker(cfg config.NATSConfig) (*NATSBroker, error) {
+	conn, err := nats.Connect(cfg.URL, nats.Timeout(cfg.ConnectTimeout), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, fmt.Errorf("connect to nats: %w", err)
+	}
+
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("create jetstream context: %w", err)
+	}
+	return &NATSBroker{conn: conn, js: js, cfg: cfg}, nil
+}
+
+func (b *NATSBroker) Subscriber(topic, group string) Subscriber {
+	return &NATSSubscriber{
+		broker:   b,
+		topic:    topic,
+		group:    group,
+		messages: make(chan jetstream.Msg),
+		closed:   make(chan struct{}),
+	}
+}
+
+func (b *NATSBroker) Publisher(topic string) Publisher {
+	return &NATSPublisher{broker: b, topic: topic}
+}
+
+// Ping checks that the JetStream server is reachable.
+func (b *NATSBroker) Ping(ctx context.Context) error {
+	_, err := b.js.AccountInfo(ctx)
+	return err
+}
+
+// Close closes the connection shared by the broker's subscribers and
+// publishers, so it must be called after they are closed.
+func (b *NATSBroker) Close() error {
+	b.conn.Close()
+	return nil
+}
+
+func (b *NATSBroker) stream(ctx context.Context, topic string) (jetstream.Stream, error) {
+	stream, err := b.js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:     streamName(topic),
+		Subjects: []string{topic},
+		Storage:  jetstream.FileStorage,
+		MaxAge:   b.cfg.StreamMaxAge,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create stream for %s: %w", topic, err)
+	}
+	return stream, nil
+}
+
+// streamName derives a valid stream name from a subject.
+func streamName(topic string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', '*', '>', '/', '\\', ' ', '\t':
+			return '_'
+		}
+		return r
+	}, strings.ToUpper(topic))
+}
+
+// NATSSubscriber reads a topic through a durable JetStream consumer with
+// explicit acks. Messages are acked in fetch order: acking a message acks
+// every message fetched before it, as with Kafka offsets. The ack wait of
+// fetched messages is extended until they are acked, so a message waiting
+// for a later one to be acked is not delivered again meanwhile.
+type NATSSubscriber struct {
+	broker *NATSBroker
+	topic  string
+	group  string
+
+	mu      sync.Mutex
+	consume jetstream.ConsumeContext
+	// pending holds fetched messages that are neither acked nor nacked, in
+	// fetch order.
+	pending []natsPending
+
+	messages  chan jetstream.Msg
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+type natsPending struct {
+	msg      jetstream.Msg
+	sequence int64
+}
+
+func (s *NATSSubscriber) Fetch(ctx context.Context) (Message, error) {
+	if s.isClosed() {
+		return Message{}, ErrClosed
+	}
+
+	err := s.start(ctx)
+	if err != nil {
+		// Callers retry failed fetches at once, so back off before
+		// reporting that the server is unavailable.
+		select {
+		case <-ctx.Done():
+		case <-s.closed:
+		case <-time.After(s.broker.cfg.ConnectTimeout):
+		}
+		return Message{}, err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return Message{}, ctx.Err()
+		case <-s.closed:
+			return Message{}, ErrClosed
+		case msg := <-s.messages:
+			message, err := fromNATS(msg)
+			if err != nil {
+				_ = msg.Nak()
+				return Message{}, err
+			}
+			if s.track(msg, message.Offset) {
+				return message, nil
+			}
+		}
+	}
+}
+
+// track adds a fetched message to pending. A message that is already
+// pending was delivered again before its ack wait was extended; it replaces
+// the earlier delivery and track reports false, so it is not processed
+// twice.
+func (s *NATSSubscriber) track(msg jetstream.Msg, sequence int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.find(sequence)
+	if i >= 0 {
+		s.pending[i].msg = msg
+		return false
+	}
+	s.pending = append(s.pending, natsPending{msg: msg, sequence: sequence})
+	return true
+}
+
+// start creates the stream and the durable consumer and starts pulling
+// messages, unless that is already done.
+func (s *NATSSubscriber) start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.consume != nil {
+		return nil
+	}
+
+	stream, err := s.broker.stream(ctx, s.topic)
+	if err != nil {
+		return err
+	}
+
+	consumer, err := stream.CreateOrUpdateConsumer(ctx, jetstream.ConsumerConfig{
+		Durable:       s.group,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       s.broker.cfg.AckWait,
+		MaxAckPending: s.broker.cfg.MaxAckPending,
+		DeliverPolicy: jetstream.DeliverAllPolicy,
+	})
+	if err != nil {
+		return fmt.Errorf("create consumer %s for %s: %w", s.group, s.topic, err)
+	}
+
+	consume, err := consumer.Consume(s.deliver, jetstream.ConsumeErrHandler(func(_ jetstream.ConsumeContext, err error) {
+		logger.Log.Warn("Error pulling messages from NATS: ", err)
+	}))
+	if err != nil {
+		return fmt.Errorf("consume %s: %w", s.topic, err)
+	}
+	s.consume = consume
+
+	ackWait := s.broker.cfg.AckWait
+	if ackWait <= 0 {
+		ackWait = natsDefaultAckWait
+	}
+	go s.keepAlive(ackWait / 2)
+	return nil
+}
+
+// keepAlive extends the ack wait of pending messages every interval until
+// the subscriber is closed.
+func (s *NATSSubscriber) keepAlive(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.closed:
+			return
+		case <-ticker.C:
+		}
+
+		s.mu.Lock()
+		for _, pending := range s.pending {
+			err := pending.msg.InProgress()
+			if err != nil {
+				logger.Log.WithField("sequence", pending.sequence).Warn("Error extending NATS ack wait: ", err)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// deliver hands a pulled message to Fetch. Messages pulled after Close are
+// returned to the server at once instead of waiting for the ack wait.
+func (s *NATSSubscriber) deliver(msg jetstream.Msg) {
+	select {
+	case s.messages <- msg:
+	case <-s.closed:
+		_ = msg.Nak()
+	}
+}
+
+func (s *NATSSubscriber) Ack(ctx context.Context, msgs ...Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
+	for _, message := range msgs {
+		i := s.find(message.Offset)
+		if i < 0 {
+			continue
+		}
+		for _, pending := range s.pending[:i+1] {
+			errs = append(errs, pending.msg.Ack())
+		}
+		s.pending = slices.Delete(s.pending, 0, i+1)
+	}
+	return errors.Join(errs...)
+}
+
+func (s *NATSSubscriber) Nack(ctx context.Context, msgs ...Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
+	for _, message := range msgs {
+		i := s.find(message.Offset)
+		if i < 0 {
+			continue
+		}
+		errs = append(errs, s.pending[i].msg.Nak())
+		s.pending = slices.Delete(s.pending, i, i+1)
+	}
+	return errors.Join(errs...)
+}
+
+// find returns the position of the message with the stream sequence in
+// pending, or -1 if it is not pending. Must be called with mu held.
+func (s *NATSSubscriber) find(sequence int64) int {
+	return slices.IndexFunc(s.pending, func(pending natsPending) bool {
+		return pending.sequence == sequence
+	})
+}
+
+// Close stops pulling messages and returns the ones that were fetched but
+// not acked to the server, so they are delivered again without waiting for
+// the ack wait.
+func (s *NATSSubscriber) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.closed)
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.consume != nil {
+		s.consume.Stop()
+	}
+
+	var errs []error
+	for _, pending := range s.pending {
+		errs = append(errs, pending.msg.Nak())
+	}
+	s.pending = nil
+	return errors.Join(errs...)
+}
+
+func (s *NATSSubscriber) isClosed() bool {
+	select {
+	case <-s.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// NATSPublisher publishes to a topic's stream and waits for the server to
+// store each message.
+type NATSPublisher struct {
+	broker *NATSBroker
+	topic  string
+
+	mu     sync.Mutex
+	ready  bool
+	closed bool
+}
+
+func (p *NATSPublisher) Publish(ctx context.Context, msgs ...Message) error {
+	err := p.prepare(ctx)
+	if err != nil {
+		return err
+	}
+
+	acks := make([]jetstream.PubAckFuture, 0, len(msgs))
+	for _, message := range msgs {
+		ack, err := p.broker.js.PublishMsgAsync(toNATS(p.topic, message))
+		if err != nil {
+			return fmt.Errorf("publish to %s: %w", p.topic, err)
+		}
+		acks = append(acks, ack)
+	}
+
+	for _, ack := range acks {
+		select {
+		case <-ack.Ok():
+		case err := <-ack.Err():
+			return fmt.Errorf("publish to %s: %w", p.topic, err)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// prepare creates the topic's stream on the first publish.
+func (p *NATSPublisher) prepare(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return ErrClosed
+	}
+	if p.ready {
+		return nil
+	}
+	_, err := p.broker.stream(ctx, p.topic)
+	if err != nil {
+		return err
+	}
+	p.ready = true
+	return nil
+}
+
+// Close only marks the publisher closed: the connection belongs to the
+// broker.
+func (p *NATSPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	return nil
+}
+
+// fromNATS uses the stream sequence as the offset; a stream has a single
+// partition.
+func fromNATS(msg jetstream.Msg) (Message, error) {
+	metadata, err := msg.Metadata()
+	if err != nil {
+		return Message{}, fmt.Errorf("read message metadata: %w", err)
+	}
+
+	message := Message{
+		Topic:         msg.Subject(),
+		Offset:        int64(metadata.Sequence.Stream),
+		HighWaterMark: int64(metadata.Sequence.Stream+metadata.NumPending) + 1,
+		Value:         msg.Data(),
+		Time:          metadata.Timestamp,
+	}
+
+	header := msg.Headers()
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		if key == natsKeyHeader {
+			message.Key = []byte(header.Get(key))
+			continue
+		}
+		for _, value := range header[key] {
+			message.Headers = append(message.Headers, Header{Key: key, Value: []byte(value)})
+		}
+	}
+	return message, nil
+}
+
+func toNATS(subject string, message Message) *nats.Msg {
+	msg := nats.NewMsg(subject)
+	msg.Data = message.Value
+	for _, header := range message.Headers {
+		msg.Header.Add(header.Key, string(header.Value))
+	}
+	if len(message.Key) > 0 {
+		msg.Header.Set(natsKeyHeader, string(message.Key))
+	}
+	return msg
+}
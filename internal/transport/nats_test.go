@@ -0,0 +1,138 @@
+package transport_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/config"
+	"github.com/ArtemKVD/WB-TechL0/internal/transport"
+	"github.com/nats-io/nats-server/v2/server"
+	natstest "github.com/nats-io/nats-server/v2/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newNATSBroker starts an embedded JetStream server for the test.
+func newNATSBroker(t *testing.T) *transport.NATSBroker {
+	t.Helper()
+
+	opts := natstest.DefaultTestOptions
+	opts.Port = server.RANDOM_PORT
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+	srv := natstest.RunServer(&opts)
+	t.Cleanup(srv.Shutdown)
+
+	broker, err := transport.NewNATSBroker(config.NATSConfig{
+		URL:            srv.ClientURL(),
+		ConnectTimeout: time.Second,
+		AckWait:        500 * time.Millisecond,
+		MaxAckPending:  100,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = broker.Close() })
+	return broker
+}
+
+func TestNATSBroker_PublishAndFetch(t *testing.T) {
+	broker := newNATSBroker(t)
+	publish(t, broker.Publisher("orders"), "a", "b", "c")
+
+	messages := fetch(t, broker.Subscriber("orders", "group"), 3)
+
+	assert.Equal(t, []string{"value-a", "value-b", "value-c"}, values(messages))
+	for i, message := range messages {
+		assert.Equal(t, "orders", message.Topic)
+		assert.Equal(t, int64(i+1), message.Offset)
+		assert.Equal(t, int64(4), message.HighWaterMark)
+		assert.Equal(t, []transport.Header{{Key: "h", Value: message.Key}}, message.Headers)
+		assert.False(t, message.Time.IsZero())
+	}
+	assert.Equal(t, []byte("a"), messages[0].Key)
+	require.NoError(t, broker.Ping(context.Background()))
+}
+
+func TestNATSBroker_AckAcksEarlierMessages(t *testing.T) {
+	broker := newNATSBroker(t)
+	publish(t, broker.Publisher("orders"), "a", "b", "c", "d")
+
+	first := broker.Subscriber("orders", "group")
+	messages := fetch(t, first, 3)
+	require.NoError(t, first.Ack(context.Background(), messages[1]))
+	require.NoError(t, first.Close())
+
+	_, err := first.Fetch(context.Background())
+	assert.ErrorIs(t, err, transport.ErrClosed)
+
+	second := broker.Subscriber("orders", "group")
+	defer second.Close()
+	assert.ElementsMatch(t, []string{"value-c", "value-d"}, values(fetch(t, second, 2)))
+}
+
+func TestNATSBroker_NackRedelivers(t *testing.T) {
+	broker := newNATSBroker(t)
+	publish(t, broker.Publisher("orders"), "a", "b")
+
+	subscriber := broker.Subscriber("orders", "group")
+	defer subscriber.Close()
+	messages := fetch(t, subscriber, 2)
+	require.NoError(t, subscriber.Ack(context.Background(), messages[0]))
+	require.NoError(t, subscriber.Nack(context.Background(), messages[1]))
+
+	redelivered := fetch(t, subscriber, 1)
+	assert.Equal(t, []string{"value-b"}, values(redelivered))
+	assert.Equal(t, messages[1].Offset, redelivered[0].Offset)
+}
+
+func TestNATSBroker_PendingMessagesAreNotRedelivered(t *testing.T) {
+	broker := newNATSBroker(t)
+	publish(t, broker.Publisher("orders"), "a", "b")
+
+	subscriber := broker.Subscriber("orders", "group")
+	defer subscriber.Close()
+	messages := fetch(t, subscriber, 2)
+
+	// Several ack waits pass while the messages are being processed.
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err := subscriber.Fetch(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	require.NoError(t, subscriber.Ack(context.Background(), messages[1]))
+}
+
+func TestNATSBroker_GroupsAreIndependent(t *testing.T) {
+	broker := newNATSBroker(t)
+	keys := make([]string, 10)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%d", i)
+	}
+	publish(t, broker.Publisher("orders.created"), keys...)
+
+	one := broker.Subscriber("orders.created", "one")
+	defer one.Close()
+	two := broker.Subscriber("orders.created", "two")
+	defer two.Close()
+	assert.Equal(t, values(fetch(t, one, len(keys))), values(fetch(t, two, len(keys))))
+}
+
+func TestNATSBroker_FetchWaits(t *testing.T) {
+	broker := newNATSBroker(t)
+	subscriber := broker.Subscriber("orders", "group")
+	defer subscriber.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := subscriber.Fetch(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestNATSPublisher_Closed(t *testing.T) {
+	publisher := newNATSBroker(t).Publisher("orders")
+	require.NoError(t, publisher.Close())
+
+	err := publisher.Publish(context.Background(), transport.Message{Value: []byte("x")})
+	assert.ErrorIs(t, err, transport.ErrClosed)
+}
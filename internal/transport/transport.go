@@ -0,0 +1,88 @@
+// Package transport abstracts the message broker the service reads orders
+// from and publishes to, so the pipeline can run on Kafka in production and
+// on an in-process broker in tests and local runs.
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ArtemKVD/WB-TechL0/internal/config"
+)
+
+// Names of the supported transports, as used in configuration.
+const (
+	Kafka  = "kafka"
+	NATS   = "nats"
+	Memory = "memory"
+)
+
+// ErrClosed is returned by operations on a closed subscriber or publisher.
+var ErrClosed = errors.New("transport is closed")
+
+// Header is a key/value pair attached to a message.
+type Header struct {
+	Key   string
+	Value []byte
+}
+
+// Message is a message read from or published to a topic. Partition and
+// Offset identify a fetched message within its topic; messages of one
+// partition are delivered in offset order. HighWaterMark is the offset the
+// next message published to the partition will get, when known.
+type Message struct {
+	Topic         string
+	Partition     int
+	Offset        int64
+	HighWaterMark int64
+	Key           []byte
+	Value         []byte
+	Headers       []Header
+	Time          time.Time
+}
+
+// Subscriber delivers the messages of a topic to a consumer group.
+//
+// Delivery is at least once: a message that was fetched but never acked is
+// delivered again, to this or another subscriber of the group. Acking a
+// message also acknowledges every earlier message of its partition, so
+// callers must ack a partition's messages in order.
+type Subscriber interface {
+	// Fetch blocks until a message is available or ctx is done.
+	Fetch(ctx context.Context) (Message, error)
+	// Ack marks messages as processed, so they are not delivered again.
+	Ack(ctx context.Context, msgs ...Message) error
+	// Nack reports that messages were not processed and should be
+	// delivered again.
+	Nack(ctx context.Context, msgs ...Message) error
+	Close() error
+}
+
+// Publisher publishes messages to the topic it was created for. Messages
+// with the same key go to the same partition and keep their order.
+type Publisher interface {
+	Publish(ctx context.Context, msgs ...Message) error
+	Close() error
+}
+
+// Broker creates the subscribers and publishers of one transport.
+type Broker interface {
+	Subscriber(topic, group string) Subscriber
+	Publisher(topic string) Publisher
+}
+
+// New returns the broker selected by cfg.Transport.
+func New(cfg *config.Config) (Broker, error) {
+	switch cfg.Transport.Kind {
+	case Kafka:
+		return NewKafkaBroker(cfg.Kafka.Broker), nil
+	case NATS:
+		return NewNATSBroker(cfg.NATS)
+	case Memory:
+		return NewMemoryBroker(cfg.Transport.MemoryPartitions), nil
+	default:
+		return nil, fmt.Errorf("unknown message transport %q", cfg.Transport.Kind)
+	}
+}
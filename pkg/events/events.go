@@ -0,0 +1,120 @@
+// Package events defines the events the order service publishes for other
+// services.
+//
+// Every event is a JSON object carrying event_id, event_type and
+// schema_version. Consumers should dispatch on event_type, ignore fields
+// they do not know and treat event_id as the deduplication key: delivery
+// is at least once, so the same event may arrive more than once. Adding
+// fields keeps the schema version; removing or changing the meaning of a
+// field bumps it.
+package events
+
+import (
+	"crypto/rand"
+	"fmt"
+	"time"
+
+	"github.com/ArtemKVD/WB-TechL0/pkg/models"
+)
+
+// TypeOrderAccepted is published once an order has been persisted.
+const TypeOrderAccepted = "order.accepted"
+
+// OrderAcceptedVersion is the current schema version of OrderAccepted.
+const OrderAcceptedVersion = 1
+
+// Actions of an OrderAccepted event.
+const (
+	// ActionCreated means the order was stored for the first time.
+	ActionCreated = "created"
+	// ActionUpdated means a changed version replaced a stored order.
+	ActionUpdated = "updated"
+)
+
+// Headers set on every published event, so consumers can route messages
+// without decoding them.
+const (
+	HeaderEventID       = "event-id"
+	HeaderEventType     = "event-type"
+	HeaderSchemaVersion = "schema-version"
+)
+
+// OrderAccepted is published with the order_uid as the message key, so all
+// events of an order arrive in the order they happened.
+//
+// Example (schema version 1):
+//
+//	{
+//	  "event_id": "3f1c2a9e-7b4d-4e5f-9a8b-1c2d3e4f5a6b",
+//	  "event_type": "order.accepted",
+//	  "schema_version": 1,
+//	  "occurred_at": "2021-11-26T06:22:20.123456Z",
+//	  "action": "created",
+//	  "order_uid": "b563feb7b2b84b6test",
+//	  "track_number": "WBILMTESTTRACK",
+//	  "customer_id": "test",
+//	  "delivery_service": "meest",
+//	  "date_created": "2021-11-26T06:22:19Z",
+//	  "currency": "USD",
+//	  "amount": 1817,
+//	  "items_count": 1
+//	}
+type OrderAccepted struct {
+	// EventID uniquely identifies the event, in UUID format.
+	EventID string `json:"event_id"`
+	// EventType is always TypeOrderAccepted.
+	EventType string `json:"event_type"`
+	// SchemaVersion is the version of this payload layout.
+	SchemaVersion int `json:"schema_version"`
+	// OccurredAt is when the order was persisted, in UTC.
+	OccurredAt time.Time `json:"occurred_at"`
+	// Action is ActionCreated or ActionUpdated.
+	Action string `json:"action"`
+
+	OrderUID        string `json:"order_uid"`
+	TrackNumber     string `json:"track_number"`
+	CustomerID      string `json:"customer_id"`
+	DeliveryService string `json:"delivery_service"`
+	// DateCreated is copied from the order as received.
+	DateCreated models.Timestamp `json:"date_created"`
+	// Currency and Amount are taken from the order's payment.
+	Currency   string       `json:"currency"`
+	Amount     models.Money `json:"amount"`
+	ItemsCount int          `json:"items_count"`
+}
+
+// NewOrderAccepted builds the event for order, persisted at occurredAt.
+func NewOrderAccepted(order models.Order, action string, occurredAt time.Time) (OrderAccepted, error) {
+	id, err := newID()
+	if err != nil {
+		return OrderAccepted{}, err
+	}
+
+	return OrderAccepted{
+		EventID:         id,
+		EventType:       TypeOrderAccepted,
+		SchemaVersion:   OrderAcceptedVersion,
+		OccurredAt:      occurredAt.UTC(),
+		Action:          action,
+		OrderUID:        order.OrderUID,
+		TrackNumber:     order.TrackNumber,
+		CustomerID:      order.CustomerID,
+		DeliveryService: order.DeliveryService,
+		DateCreated:     order.DateCreated,
+		Currency:        order.Payment.Currency,
+		Amount:          order.Payment.Amount,
+		ItemsCount:      len(order.Items),
+	}, nil
+}
+
+// newID returns a random (version 4) UUID.
+func newID() (string, error) {
+	var b [16]byte
+	_, err := rand.Read(b[:])
+	if err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
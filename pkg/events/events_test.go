@@ -0,0 +1,52 @@
+package events_test
+
+import (
+	"encoding/json"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/ArtemKVD/WB-TechL0/pkg/events"
+	"github.com/ArtemKVD/WB-TechL0/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewOrderAccepted(t *testing.T) {
+	order := models.Order{
+		OrderUID:        "b563feb7b2b84b6test",
+		TrackNumber:     "WBILMTESTTRACK",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		DateCreated:     models.Timestamp{Time: time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC)},
+		Payment:         models.Payment{Currency: "USD", Amount: 1817},
+		Items:           []models.Item{{RID: "a"}, {RID: "b"}},
+	}
+	occurredAt := time.Date(2021, 11, 26, 9, 22, 20, 0, time.FixedZone("MSK", 3*60*60))
+
+	event, err := events.NewOrderAccepted(order, events.ActionCreated, occurredAt)
+	require.NoError(t, err)
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), event.EventID)
+
+	payload, err := json.Marshal(event)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"event_id": "`+event.EventID+`",
+		"event_type": "order.accepted",
+		"schema_version": 1,
+		"occurred_at": "2021-11-26T06:22:20Z",
+		"action": "created",
+		"order_uid": "b563feb7b2b84b6test",
+		"track_number": "WBILMTESTTRACK",
+		"customer_id": "test",
+		"delivery_service": "meest",
+		"date_created": "2021-11-26T06:22:19Z",
+		"currency": "USD",
+		"amount": 1817,
+		"items_count": 2
+	}`, string(payload))
+
+	other, err := events.NewOrderAccepted(order, events.ActionCreated, occurredAt)
+	require.NoError(t, err)
+	assert.NotEqual(t, event.EventID, other.EventID)
+}
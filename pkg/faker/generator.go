@@ -19,20 +19,30 @@ func GenerateTestOrders(count int) []models.Order {
 	return orders
 }
 
+// generateOrder returns an order that passes validation, including the
+// business rules: totals add up and items share the order track number.
 func generateOrder() models.Order {
 	orderUID := gofakeit.UUID()
+	trackNumber := fmt.Sprintf("WBIL%08d", rand.Intn(100000000))
+	items := generateItems(trackNumber, rand.Intn(3)+1)
+
+	var goodsTotal models.Money
+	for _, item := range items {
+		goodsTotal += item.TotalPrice
+	}
+	deliveryCost := models.Money(rand.Intn(2000) + 500)
 
 	return models.Order{
 		OrderUID:          orderUID,
-		TrackNumber:       fmt.Sprintf("WBIL%08d", rand.Intn(100000000)),
+		TrackNumber:       trackNumber,
 		Entry:             "WBIL",
 		Locale:            "en",
 		InternalSignature: "",
 		CustomerID:        gofakeit.UUID(),
 		DeliveryService:   "meest",
 		ShardKey:          fmt.Sprintf("%d", rand.Intn(10)),
-		SMID:              rand.Intn(100),
-		DateCreated:       time.Now().Format(time.RFC3339),
+		SMID:              rand.Intn(99) + 1,
+		DateCreated:       models.Timestamp{Time: time.Now().Truncate(time.Second)},
 		OOFShard:          "1",
 		Delivery: models.Delivery{
 			Name:    gofakeit.Name(),
@@ -44,37 +54,39 @@ func generateOrder() models.Order {
 			Email:   gofakeit.Email(),
 		},
 		Payment: models.Payment{
-			Transaction:  gofakeit.UUID(),
+			Transaction:  orderUID,
 			RequestID:    "",
 			Currency:     "USD",
 			Provider:     "wbpay",
-			Amount:       rand.Intn(10000) + 100,
-			PaymentDt:    int(time.Now().Unix()),
+			Amount:       goodsTotal + deliveryCost,
+			PaymentDt:    models.UnixTime{Time: time.Now().Truncate(time.Second)},
 			Bank:         "alpha",
-			DeliveryCost: rand.Intn(2000) + 500,
-			GoodsTotal:   rand.Intn(1000) + 100,
+			DeliveryCost: deliveryCost,
+			GoodsTotal:   goodsTotal,
 			CustomFee:    0,
 		},
-		Items: generateItems(orderUID, rand.Intn(3)+1),
+		Items: items,
 	}
 }
 
-func generateItems(orderUID string, count int) []models.Item {
+func generateItems(trackNumber string, count int) []models.Item {
 	var items []models.Item
 
 	for i := 0; i < count; i++ {
+		price := models.Money(rand.Intn(1000) + 50)
+		sale := rand.Intn(50)
 		items = append(items, models.Item{
-			ChrtID:      rand.Intn(10000000),
-			TrackNumber: fmt.Sprintf("WBIL%08d", rand.Intn(100000000)),
-			Price:       rand.Intn(1000) + 50,
+			ChrtID:      rand.Intn(10000000) + 1,
+			TrackNumber: trackNumber,
+			Price:       price,
 			RID:         gofakeit.UUID(),
 			Name:        gofakeit.ProductName(),
-			Sale:        rand.Intn(50),
+			Sale:        sale,
 			Size:        fmt.Sprintf("%d", rand.Intn(5)),
-			TotalPrice:  rand.Intn(500) + 50,
-			NmID:        rand.Intn(1000000),
+			TotalPrice:  price * models.Money(100-sale) / 100,
+			NmID:        rand.Intn(1000000) + 1,
 			Brand:       gofakeit.Company(),
-			Status:      202,
+			Status:      models.ItemStatusAccepted,
 		})
 	}
 
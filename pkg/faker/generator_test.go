@@ -0,0 +1,20 @@
+package faker_test
+
+import (
+	"testing"
+
+	"github.com/ArtemKVD/WB-TechL0/pkg/faker"
+	"github.com/ArtemKVD/WB-TechL0/pkg/validator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateTestOrders_AreValid(t *testing.T) {
+	orders := faker.GenerateTestOrders(500)
+	assert.Len(t, orders, 500)
+
+	for _, order := range orders {
+		warnings, err := validator.CheckOrder(order)
+		assert.NoError(t, err, order.OrderUID)
+		assert.Empty(t, warnings, order.OrderUID)
+	}
+}
@@ -1,55 +1,55 @@
 package models
 
 type Order struct {
-	OrderUID          string   `json:"order_uid" validate:"required"`
-	TrackNumber       string   `json:"track_number" validate:"required"`
-	Entry             string   `json:"entry" validate:"required"`
-	Delivery          Delivery `json:"delivery" validate:"required"`
-	Payment           Payment  `json:"payment" validate:"required"`
-	Items             []Item   `json:"items" validate:"required"`
-	Locale            string   `json:"locale" validate:"required"`
-	InternalSignature string   `json:"internal_signature"`
-	CustomerID        string   `json:"customer_id" validate:"required"`
-	DeliveryService   string   `json:"delivery_service" validate:"required"`
-	ShardKey          string   `json:"shardkey" validate:"required"`
-	SMID              int      `json:"sm_id" validate:"required"`
-	DateCreated       string   `json:"date_created" validate:"required"`
-	OOFShard          string   `json:"oof_shard" validate:"required"`
+	OrderUID          string    `json:"order_uid" validate:"required"`
+	TrackNumber       string    `json:"track_number" validate:"required"`
+	Entry             string    `json:"entry" validate:"required"`
+	Delivery          Delivery  `json:"delivery" validate:"required"`
+	Payment           Payment   `json:"payment" validate:"required"`
+	Items             []Item    `json:"items" validate:"required,dive"`
+	Locale            string    `json:"locale" validate:"required,bcp47_language_tag"`
+	InternalSignature string    `json:"internal_signature"`
+	CustomerID        string    `json:"customer_id" validate:"required"`
+	DeliveryService   string    `json:"delivery_service" validate:"required"`
+	ShardKey          string    `json:"shardkey" validate:"required"`
+	SMID              int       `json:"sm_id" validate:"required"`
+	DateCreated       Timestamp `json:"date_created" validate:"required,timestamp"`
+	OOFShard          string    `json:"oof_shard" validate:"required"`
 }
 
 type Delivery struct {
 	Name    string `json:"name" validate:"required"`
-	Phone   string `json:"phone" validate:"required"`
-	Zip     string `json:"zip" validate:"required"`
+	Phone   string `json:"phone" validate:"required,phone"`
+	Zip     string `json:"zip" validate:"required,zip"`
 	City    string `json:"city" validate:"required"`
 	Address string `json:"address" validate:"required"`
 	Region  string `json:"region" validate:"required"`
-	Email   string `json:"email" validate:"required"`
+	Email   string `json:"email" validate:"required,email"`
 }
 
 type Payment struct {
-	Transaction  string `json:"transaction" validate:"required"`
-	RequestID    string `json:"request_id"`
-	Currency     string `json:"currency" validate:"required"`
-	Provider     string `json:"provider" validate:"required"`
-	Amount       int    `json:"amount" validate:"required"`
-	PaymentDt    int    `json:"payment_dt" validate:"required"`
-	Bank         string `json:"bank" validate:"required"`
-	DeliveryCost int    `json:"delivery_cost" validate:"required"`
-	GoodsTotal   int    `json:"goods_total" validate:"required"`
-	CustomFee    int    `json:"custom_fee"`
+	Transaction  string   `json:"transaction" validate:"required"`
+	RequestID    string   `json:"request_id"`
+	Currency     string   `json:"currency" validate:"required,iso4217"`
+	Provider     string   `json:"provider" validate:"required"`
+	Amount       Money    `json:"amount" validate:"required"`
+	PaymentDt    UnixTime `json:"payment_dt" validate:"required,unix_time"`
+	Bank         string   `json:"bank" validate:"required"`
+	DeliveryCost Money    `json:"delivery_cost" validate:"required"`
+	GoodsTotal   Money    `json:"goods_total" validate:"required"`
+	CustomFee    Money    `json:"custom_fee"`
 }
 
 type Item struct {
-	ChrtID      int    `json:"chrt_id" validate:"required"`
-	TrackNumber string `json:"track_number" validate:"required"`
-	Price       int    `json:"price" validate:"required"`
-	RID         string `json:"rid" validate:"required"`
-	Name        string `json:"name" validate:"required"`
-	Sale        int    `json:"sale"`
-	Size        string `json:"size" validate:"required"`
-	TotalPrice  int    `json:"total_price" validate:"required"`
-	NmID        int    `json:"nm_id" validate:"required"`
-	Brand       string `json:"brand" validate:"required"`
-	Status      int    `json:"status" validate:"required"`
+	ChrtID      int        `json:"chrt_id" validate:"required"`
+	TrackNumber string     `json:"track_number" validate:"required"`
+	Price       Money      `json:"price" validate:"required,min=0"`
+	RID         string     `json:"rid" validate:"required"`
+	Name        string     `json:"name" validate:"required"`
+	Sale        int        `json:"sale"`
+	Size        string     `json:"size" validate:"required"`
+	TotalPrice  Money      `json:"total_price" validate:"required"`
+	NmID        int        `json:"nm_id" validate:"required"`
+	Brand       string     `json:"brand" validate:"required"`
+	Status      ItemStatus `json:"status" validate:"required,item_status"`
 }
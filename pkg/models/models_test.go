@@ -0,0 +1,193 @@
+package models_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ArtemKVD/WB-TechL0/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const orderJSON = `{
+	"order_uid": "b563feb7b2b84b6test",
+	"track_number": "WBILMTESTTRACK",
+	"entry": "WBIL",
+	"delivery": {
+		"name": "Test Testov",
+		"phone": "+9720000000",
+		"zip": "2639809",
+		"city": "Kiryat Mozkin",
+		"address": "Ploshad Mira 15",
+		"region": "Kraiot",
+		"email": "test@gmail.com"
+	},
+	"payment": {
+		"transaction": "b563feb7b2b84b6test",
+		"request_id": "",
+		"currency": "USD",
+		"provider": "wbpay",
+		"amount": 1817,
+		"payment_dt": 1637907727,
+		"bank": "alpha",
+		"delivery_cost": 1500,
+		"goods_total": 317,
+		"custom_fee": 0
+	},
+	"items": [{
+		"chrt_id": 9934930,
+		"track_number": "WBILMTESTTRACK",
+		"price": 453,
+		"rid": "ab4219087a764ae0btest",
+		"name": "Mascaras",
+		"sale": 30,
+		"size": "0",
+		"total_price": 317,
+		"nm_id": 2389212,
+		"brand": "Vivienne Sabo",
+		"status": 202
+	}],
+	"locale": "en",
+	"internal_signature": "",
+	"customer_id": "test",
+	"delivery_service": "meest",
+	"shardkey": "9",
+	"sm_id": 99,
+	"date_created": "2021-11-26T09:22:19.5+03:00",
+	"oof_shard": "1"
+}`
+
+func TestOrderJSON(t *testing.T) {
+	var order models.Order
+	require.NoError(t, json.Unmarshal([]byte(orderJSON), &order))
+
+	assert.True(t, order.DateCreated.Equal(time.Date(2021, 11, 26, 6, 22, 19, 500000000, time.UTC)))
+	assert.Equal(t, int64(1637907727), order.Payment.PaymentDt.Unix())
+	assert.Equal(t, models.Money(1817), order.Payment.Amount)
+	assert.Equal(t, models.ItemStatusAccepted, order.Items[0].Status)
+
+	encoded, err := json.Marshal(order)
+	require.NoError(t, err)
+	assert.JSONEq(t, orderJSON, string(encoded))
+}
+
+func TestOrderJSON_ZeroTimes(t *testing.T) {
+	var order models.Order
+	require.NoError(t, json.Unmarshal([]byte(`{"date_created": "", "payment": {"payment_dt": 0}}`), &order))
+	assert.True(t, order.DateCreated.IsZero())
+	assert.True(t, order.Payment.PaymentDt.IsZero())
+
+	encoded, err := json.Marshal(order)
+	require.NoError(t, err)
+	assert.Contains(t, string(encoded), `"date_created":""`)
+	assert.Contains(t, string(encoded), `"payment_dt":0`)
+}
+
+func TestOrderJSON_MalformedDate(t *testing.T) {
+	var order models.Order
+	require.NoError(t, json.Unmarshal([]byte(`{"date_created": "2021-11-26T06:22:19"}`), &order))
+	assert.True(t, order.DateCreated.IsZero())
+	assert.Equal(t, "2021-11-26T06:22:19", order.DateCreated.String())
+
+	encoded, err := json.Marshal(order)
+	require.NoError(t, err)
+	assert.Contains(t, string(encoded), `"date_created":"2021-11-26T06:22:19"`)
+
+	require.NoError(t, json.Unmarshal([]byte(`{"date_created": "2021-11-26T06:22:19Z"}`), &order))
+	assert.Equal(t, "2021-11-26T06:22:19Z", order.DateCreated.String())
+}
+
+func TestOrderJSON_Strict(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		err  string
+	}{
+		{
+			name: "date as a number",
+			json: `{"date_created": 1637907727}`,
+			err:  "timestamp must be an RFC 3339 string, got 1637907727",
+		},
+		{
+			name: "payment_dt as a string",
+			json: `{"payment": {"payment_dt": "1637907727"}}`,
+			err:  `unix time must be an integer number of seconds, got "1637907727"`,
+		},
+		{
+			name: "fractional payment_dt",
+			json: `{"payment": {"payment_dt": 1637907727.5}}`,
+			err:  "unix time must be an integer number of seconds, got 1637907727.5",
+		},
+		{
+			name: "fractional amount",
+			json: `{"payment": {"amount": 18.17}}`,
+			err:  "cannot unmarshal number 18.17",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var order models.Order
+			err := json.Unmarshal([]byte(tt.json), &order)
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
+
+func TestTimestamp_Scan(t *testing.T) {
+	want := time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC)
+
+	var fromTime models.Timestamp
+	require.NoError(t, fromTime.Scan(want.In(time.FixedZone("", 0))))
+	assert.Equal(t, want, fromTime.Time)
+
+	var fromText models.Timestamp
+	require.NoError(t, fromText.Scan("2021-11-26T06:22:19.000000Z"))
+	assert.Equal(t, want, fromText.Time)
+
+	assert.Error(t, fromText.Scan("26.11.2021"))
+}
+
+func TestUnixTime_Scan(t *testing.T) {
+	var scanned models.UnixTime
+	require.NoError(t, scanned.Scan(int64(1637907727)))
+	assert.Equal(t, time.Unix(1637907727, 0).UTC(), scanned.Time)
+
+	value, err := scanned.Value()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1637907727), value)
+
+	value, err = models.UnixTime{}.Value()
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), value)
+}
+
+func TestMoney_Decimal(t *testing.T) {
+	tests := []struct {
+		amount   models.Money
+		currency string
+		want     string
+	}{
+		{1817, "USD", "18.17"},
+		{5, "RUB", "0.05"},
+		{-1817, "EUR", "-18.17"},
+		{1817, "JPY", "1817"},
+		{1817, "KWD", "1.817"},
+		{0, "USD", "0.00"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.amount.Decimal(tt.currency), "%d %s", tt.amount, tt.currency)
+	}
+	assert.Equal(t, "18.17 USD", models.Money(1817).Format("USD"))
+}
+
+func TestItemStatus(t *testing.T) {
+	assert.Equal(t, "accepted", models.ItemStatusAccepted.String())
+	assert.True(t, models.ItemStatusAccepted.Known())
+
+	unknown := models.ItemStatus(999)
+	assert.Equal(t, "status 999", unknown.String())
+	assert.False(t, unknown.Known())
+}
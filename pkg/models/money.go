@@ -0,0 +1,54 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Money is an amount in the minor units of the payment currency, such as
+// cents for USD, as it is sent on the wire: 1817 USD is 18.17 USD.
+type Money int64
+
+// minorUnits lists the ISO 4217 currencies that do not have two decimal
+// places.
+var minorUnits = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"CLF": 4, "UYW": 4,
+}
+
+// MinorUnits returns the number of decimal places of currency.
+func MinorUnits(currency string) int {
+	digits, ok := minorUnits[currency]
+	if !ok {
+		return 2
+	}
+	return digits
+}
+
+// Decimal formats m in the major units of currency, such as 18.17 for 1817
+// USD.
+func (m Money) Decimal(currency string) string {
+	digits := MinorUnits(currency)
+	amount := int64(m)
+	if digits == 0 {
+		return strconv.FormatInt(amount, 10)
+	}
+
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	scale := int64(1)
+	for range digits {
+		scale *= 10
+	}
+	return fmt.Sprintf("%s%d.%0*d", sign, amount/scale, digits, amount%scale)
+}
+
+// Format formats m with its currency, such as 18.17 USD.
+func (m Money) Format(currency string) string {
+	return m.Decimal(currency) + " " + currency
+}
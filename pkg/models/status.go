@@ -0,0 +1,41 @@
+package models
+
+import "strconv"
+
+// ItemStatus is the status code of an item, sent as a number.
+type ItemStatus int
+
+const (
+	ItemStatusCreated   ItemStatus = 201
+	ItemStatusAccepted  ItemStatus = 202
+	ItemStatusAssembled ItemStatus = 203
+	ItemStatusShipped   ItemStatus = 204
+	ItemStatusDelivered ItemStatus = 205
+	ItemStatusCanceled  ItemStatus = 400
+	ItemStatusReturned  ItemStatus = 401
+)
+
+var itemStatusNames = map[ItemStatus]string{
+	ItemStatusCreated:   "created",
+	ItemStatusAccepted:  "accepted",
+	ItemStatusAssembled: "assembled",
+	ItemStatusShipped:   "shipped",
+	ItemStatusDelivered: "delivered",
+	ItemStatusCanceled:  "canceled",
+	ItemStatusReturned:  "returned",
+}
+
+// Known reports whether s is one of the ItemStatus constants.
+func (s ItemStatus) Known() bool {
+	_, ok := itemStatusNames[s]
+	return ok
+}
+
+// String returns the name of s, or its code if it is not known.
+func (s ItemStatus) String() string {
+	name, ok := itemStatusNames[s]
+	if !ok {
+		return "status " + strconv.Itoa(int(s))
+	}
+	return name
+}
@@ -0,0 +1,142 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Timestamp is a time sent as an RFC 3339 string, such as date_created. The
+// zero Timestamp is sent as an empty string.
+type Timestamp struct {
+	time.Time
+	// invalid keeps a decoded string that is not in RFC 3339 format, so
+	// validation can report it. Time is zero then.
+	invalid string
+}
+
+// String returns t in RFC 3339 format, or the string it was decoded from if
+// that is not in RFC 3339 format.
+func (t Timestamp) String() string {
+	if t.invalid != "" {
+		return t.invalid
+	}
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
+// UnmarshalJSON only accepts strings. An empty string or null leaves the
+// zero Timestamp and a string in another format is kept as it is, so that
+// validation reports a missing or malformed date like other fields.
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var value string
+	err := json.Unmarshal(data, &value)
+	if err != nil {
+		return fmt.Errorf("timestamp must be an RFC 3339 string, got %s", data)
+	}
+
+	*t = Timestamp{}
+	if value == "" {
+		return nil
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		t.invalid = value
+		return nil
+	}
+	t.Time = parsed
+	return nil
+}
+
+// Scan reads a timestamp column: a time from Postgres or the RFC 3339 text
+// SQLite stores. Times are returned in UTC.
+func (t *Timestamp) Scan(src any) error {
+	t.invalid = ""
+	switch value := src.(type) {
+	case nil:
+		t.Time = time.Time{}
+	case time.Time:
+		t.Time = value.UTC()
+	case string:
+		return t.scanText(value)
+	case []byte:
+		return t.scanText(string(value))
+	default:
+		return fmt.Errorf("cannot scan %T into a timestamp", src)
+	}
+	return nil
+}
+
+func (t *Timestamp) scanText(value string) error {
+	parsed, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return fmt.Errorf("invalid stored timestamp %q: %w", value, err)
+	}
+	t.Time = parsed.UTC()
+	return nil
+}
+
+// UnixTime is a time sent as whole seconds since the Unix epoch, such as
+// payment_dt. The zero UnixTime is sent as 0.
+type UnixTime struct {
+	time.Time
+}
+
+// Seconds returns t as a Unix time, or 0 for the zero UnixTime.
+func (t UnixTime) Seconds() int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func (t UnixTime) MarshalJSON() ([]byte, error) {
+	return strconv.AppendInt(nil, t.Seconds(), 10), nil
+}
+
+// UnmarshalJSON only accepts integers. Null leaves t unchanged.
+func (t *UnixTime) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	seconds, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil {
+		return fmt.Errorf("unix time must be an integer number of seconds, got %s", data)
+	}
+	*t = unixTime(seconds)
+	return nil
+}
+
+func (t *UnixTime) Scan(src any) error {
+	switch value := src.(type) {
+	case nil:
+		t.Time = time.Time{}
+	case int64:
+		*t = unixTime(value)
+	default:
+		return fmt.Errorf("cannot scan %T into a unix time", src)
+	}
+	return nil
+}
+
+func (t UnixTime) Value() (driver.Value, error) {
+	return t.Seconds(), nil
+}
+
+func unixTime(seconds int64) UnixTime {
+	if seconds == 0 {
+		return UnixTime{}
+	}
+	return UnixTime{Time: time.Unix(seconds, 0).UTC()}
+}
@@ -0,0 +1,120 @@
+package validator
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Violation is one failed check. Path is the JSON path of the field, such
+// as items[2].price, Rule the name of the check and Value the offending
+// value.
+type Violation struct {
+	Path    string `json:"path"`
+	Rule    string `json:"rule"`
+	Value   any    `json:"value,omitempty"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every violation found in a value.
+type ValidationError struct {
+	Violations []Violation `json:"violations"`
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		messages[i] = violation.Path + " " + violation.Message
+	}
+	return "order validation failed: " + strings.Join(messages, "; ")
+}
+
+// Violations returns the violations of err if it is a ValidationError.
+func Violations(err error) ([]Violation, bool) {
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		return nil, false
+	}
+	return validationErr.Violations, true
+}
+
+// newValidationError returns nil if there are no violations, so the
+// result can be returned as an error directly.
+func newValidationError(violations []Violation) error {
+	if len(violations) == 0 {
+		return nil
+	}
+	return &ValidationError{Violations: violations}
+}
+
+// structViolations converts the error of validate.Struct. Errors other than
+// failed checks, such as an invalid argument, are returned as is.
+func structViolations(err error) ([]Violation, error) {
+	if err == nil {
+		return nil, nil
+	}
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		return nil, err
+	}
+
+	violations := make([]Violation, len(fieldErrs))
+	for i, fieldErr := range fieldErrs {
+		// The namespace starts with the name of the validated struct.
+		_, path, _ := strings.Cut(fieldErr.Namespace(), ".")
+		violations[i] = Violation{
+			Path:    path,
+			Rule:    fieldErr.Tag(),
+			Value:   offendingValue(fieldErr.Value()),
+			Message: ruleMessage(fieldErr.Tag(), fieldErr.Param()),
+		}
+	}
+	return violations, nil
+}
+
+// offendingValue drops nil slices, maps and pointers, which would only be
+// reported as null.
+func offendingValue(value any) any {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Invalid:
+		return nil
+	case reflect.Slice, reflect.Map, reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+	}
+	return value
+}
+
+func ruleMessage(rule, param string) string {
+	switch rule {
+	case "required":
+		return "is required"
+	case "min":
+		return "must be at least " + param
+	case "max":
+		return "must be at most " + param
+	case "email":
+		return "must be a valid email address"
+	case "phone":
+		return "must be a phone number in E.164 format"
+	case "timestamp":
+		return "must be an RFC 3339 timestamp"
+	case "unix_time":
+		return "must be a unix time between 2000-01-01 and one day from now"
+	case "zip":
+		return "must be a postal code valid for the region"
+	case "item_status":
+		return "must be a known item status"
+	case "iso4217":
+		return "must be an ISO 4217 currency code"
+	case "bcp47_language_tag":
+		return "must be a BCP 47 language tag"
+	default:
+		return fmt.Sprintf("failed the %s check", rule)
+	}
+}
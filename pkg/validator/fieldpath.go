@@ -0,0 +1,114 @@
+package validator
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/ArtemKVD/WB-TechL0/pkg/models"
+)
+
+// fieldPath is a parsed JSON path into models.Order, such as
+// payment.currency or items[*].brand, where [*] selects every element.
+type fieldPath struct {
+	raw      string
+	segments []pathSegment
+	// kind is the kind of the field the path ends at.
+	kind reflect.Kind
+	// each is set if the path selects several values.
+	each bool
+}
+
+type pathSegment struct {
+	name  string
+	index []int
+	each  bool
+}
+
+// resolvedField is one value selected by a path, with its concrete path.
+type resolvedField struct {
+	path  string
+	value reflect.Value
+}
+
+var orderType = reflect.TypeOf(models.Order{})
+
+// parseFieldPath checks path against models.Order.
+func parseFieldPath(path string) (fieldPath, error) {
+	if path == "" {
+		return fieldPath{}, fmt.Errorf("field is empty")
+	}
+
+	parsed := fieldPath{raw: path}
+	t := orderType
+	for _, part := range strings.Split(path, ".") {
+		name, each := strings.CutSuffix(part, "[*]")
+		if t.Kind() != reflect.Struct {
+			return fieldPath{}, fmt.Errorf("field %q: %s has no fields", path, t)
+		}
+		field, ok := jsonField(t, name)
+		if !ok {
+			return fieldPath{}, fmt.Errorf("field %q: unknown field %q", path, name)
+		}
+		t = field.Type
+		if each {
+			if t.Kind() != reflect.Slice {
+				return fieldPath{}, fmt.Errorf("field %q: %s is not a list", path, name)
+			}
+			t = t.Elem()
+			parsed.each = true
+		}
+		parsed.segments = append(parsed.segments, pathSegment{name: name, index: field.Index, each: each})
+	}
+	parsed.kind = t.Kind()
+	return parsed, nil
+}
+
+func jsonField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if tag == name && tag != "-" {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// resolve returns the values path selects in order.
+func (p fieldPath) resolve(order models.Order) []resolvedField {
+	fields := []resolvedField{{value: reflect.ValueOf(order)}}
+	for _, segment := range p.segments {
+		var next []resolvedField
+		for _, field := range fields {
+			path := segment.name
+			if field.path != "" {
+				path = field.path + "." + segment.name
+			}
+			value := field.value.FieldByIndex(segment.index)
+			if !segment.each {
+				next = append(next, resolvedField{path: path, value: value})
+				continue
+			}
+			for i := 0; i < value.Len(); i++ {
+				next = append(next, resolvedField{path: path + "[" + strconv.Itoa(i) + "]", value: value.Index(i)})
+			}
+		}
+		fields = next
+	}
+	return fields
+}
+
+// valueString formats a string or integer value for comparison with the
+// values of a rule.
+func valueString(value reflect.Value) string {
+	switch value.Kind() {
+	case reflect.String:
+		return value.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(value.Int(), 10)
+	default:
+		return fmt.Sprint(value.Interface())
+	}
+}
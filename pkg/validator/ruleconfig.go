@@ -0,0 +1,267 @@
+package validator
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/ArtemKVD/WB-TechL0/pkg/models"
+	"gopkg.in/yaml.v3"
+)
+
+// RuleFile is the format of a validation rules file, in YAML or JSON:
+//
+//	rules:
+//	  - name: allowed_delivery_services
+//	    field: delivery_service
+//	    in: [meest, cdek]
+//	  - name: rub_only_for_wbru
+//	    field: payment.currency
+//	    in: [RUB]
+//	    when: {field: entry, in: [WBRU]}
+type RuleFile struct {
+	Rules []RuleSpec `yaml:"rules" json:"rules"`
+}
+
+// RuleSpec declares a rule on the values Field selects: a JSON path into the
+// order such as payment.bank or items[*].brand. The rule only applies when
+// the When condition holds. Min and Max bound numbers, or the length of a
+// list. Empty strings are only checked by Required. Severity is reject
+// unless set to warn.
+type RuleSpec struct {
+	Name     string     `yaml:"name" json:"name"`
+	Severity string     `yaml:"severity" json:"severity"`
+	Field    string     `yaml:"field" json:"field"`
+	When     *Condition `yaml:"when" json:"when"`
+	Required bool       `yaml:"required" json:"required"`
+	In       []string   `yaml:"in" json:"in"`
+	Min      *int64     `yaml:"min" json:"min"`
+	Max      *int64     `yaml:"max" json:"max"`
+	Pattern  string     `yaml:"pattern" json:"pattern"`
+}
+
+// Condition holds when the single value Field selects is one of In.
+type Condition struct {
+	Field string   `yaml:"field" json:"field"`
+	In    []string `yaml:"in" json:"in"`
+}
+
+// configuredRules are applied by CheckOrder after the business rules.
+var configuredRules atomic.Pointer[RuleEngine]
+
+// SetRules replaces the configured rules applied by ValidateOrder and
+// CheckOrder. It is safe to call while orders are being validated.
+func SetRules(rules []Rule) {
+	configuredRules.Store(NewRuleEngine(rules...))
+}
+
+// LoadRules reads and compiles the rules file at path.
+func LoadRules(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := ParseRules(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// ParseRules compiles the rules of a rules file. All problems found are
+// reported together, each naming the rule it is about.
+func ParseRules(data []byte) ([]Rule, error) {
+	var file RuleFile
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	err := decoder.Decode(&file)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parse rules: %w", err)
+	}
+
+	reserved := make(map[string]bool)
+	for _, rule := range BusinessRules() {
+		reserved[rule.Name] = true
+	}
+	seen := make(map[string]bool)
+
+	var rules []Rule
+	var errs []error
+	for i, spec := range file.Rules {
+		rule, err := compileRule(spec)
+		switch {
+		case err != nil:
+		case reserved[spec.Name]:
+			err = fmt.Errorf("name is used by a built-in rule")
+		case seen[spec.Name]:
+			err = fmt.Errorf("name is used by another rule")
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rules[%d] %q: %w", i, spec.Name, err))
+			continue
+		}
+		seen[spec.Name] = true
+		rules = append(rules, rule)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return rules, nil
+}
+
+func compileRule(spec RuleSpec) (Rule, error) {
+	if spec.Name == "" {
+		return Rule{}, fmt.Errorf("name is empty")
+	}
+	severity := spec.Severity
+	if severity == "" {
+		severity = SeverityReject
+	}
+	if severity != SeverityReject && severity != SeverityWarn {
+		return Rule{}, fmt.Errorf("severity must be %s or %s, got %q", SeverityReject, SeverityWarn, spec.Severity)
+	}
+
+	path, err := parseFieldPath(spec.Field)
+	if err != nil {
+		return Rule{}, err
+	}
+	if !spec.Required && spec.In == nil && spec.Min == nil && spec.Max == nil && spec.Pattern == "" {
+		return Rule{}, fmt.Errorf("no check: set required, in, min, max or pattern")
+	}
+
+	check := &configuredCheck{spec: spec, path: path}
+	if spec.When != nil {
+		when, err := parseFieldPath(spec.When.Field)
+		switch {
+		case err != nil:
+			return Rule{}, fmt.Errorf("when: %w", err)
+		case when.each || !isScalar(when.kind):
+			return Rule{}, fmt.Errorf("when: field %q must select a single string or number", spec.When.Field)
+		case len(spec.When.In) == 0:
+			return Rule{}, fmt.Errorf("when: in is empty")
+		}
+		check.when = &when
+	}
+	if spec.In != nil && !isScalar(path.kind) {
+		return Rule{}, fmt.Errorf("in: field %q is not a string or number", spec.Field)
+	}
+	if (spec.Min != nil || spec.Max != nil) && !isInt(path.kind) && path.kind != reflect.Slice {
+		return Rule{}, fmt.Errorf("min, max: field %q is not a number or list", spec.Field)
+	}
+	if spec.Min != nil && spec.Max != nil && *spec.Min > *spec.Max {
+		return Rule{}, fmt.Errorf("min %d is greater than max %d", *spec.Min, *spec.Max)
+	}
+	if spec.Pattern != "" {
+		if path.kind != reflect.String {
+			return Rule{}, fmt.Errorf("pattern: field %q is not a string", spec.Field)
+		}
+		check.pattern, err = regexp.Compile(spec.Pattern)
+		if err != nil {
+			return Rule{}, fmt.Errorf("pattern: %w", err)
+		}
+	}
+
+	return Rule{Name: spec.Name, Severity: severity, Check: check.violations}, nil
+}
+
+func isScalar(kind reflect.Kind) bool {
+	return kind == reflect.String || isInt(kind)
+}
+
+func isInt(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+type configuredCheck struct {
+	spec    RuleSpec
+	path    fieldPath
+	when    *fieldPath
+	pattern *regexp.Regexp
+}
+
+func (c *configuredCheck) violations(order models.Order) []Violation {
+	if c.when != nil && !c.conditionHolds(order) {
+		return nil
+	}
+
+	var violations []Violation
+	for _, field := range c.path.resolve(order) {
+		message, ok := c.check(field.value)
+		if ok {
+			continue
+		}
+		value := field.value.Interface()
+		if field.value.Kind() == reflect.Slice {
+			value = field.value.Len()
+		}
+		violations = append(violations, Violation{Path: field.path, Value: value, Message: message})
+	}
+	return violations
+}
+
+func (c *configuredCheck) conditionHolds(order models.Order) bool {
+	fields := c.when.resolve(order)
+	return len(fields) == 1 && slices.Contains(c.spec.When.In, valueString(fields[0].value))
+}
+
+// check returns the message of the first check value fails.
+func (c *configuredCheck) check(value reflect.Value) (string, bool) {
+	isList := value.Kind() == reflect.Slice
+	empty := value.IsZero()
+	if isList {
+		empty = value.Len() == 0
+	}
+	switch {
+	case empty && c.spec.Required:
+		return c.requiredMessage(), false
+	case empty && value.Kind() == reflect.String:
+		return "", true
+	}
+
+	if c.spec.In != nil && !slices.Contains(c.spec.In, valueString(value)) {
+		return "must be one of " + strings.Join(c.spec.In, ", "), false
+	}
+
+	switch {
+	case isList:
+		return c.checkBounds(int64(value.Len()), "must have at %s %d elements")
+	case isInt(value.Kind()):
+		return c.checkBounds(value.Int(), "must be at %s %d")
+	case c.pattern != nil && !c.pattern.MatchString(value.String()):
+		return "must match " + c.spec.Pattern, false
+	}
+	return "", true
+}
+
+// checkBounds checks n against Min and Max, describing a failure with
+// format filled with "least" or "most" and the bound.
+func (c *configuredCheck) checkBounds(n int64, format string) (string, bool) {
+	if c.spec.Min != nil && n < *c.spec.Min {
+		return fmt.Sprintf(format, "least", *c.spec.Min), false
+	}
+	if c.spec.Max != nil && n > *c.spec.Max {
+		return fmt.Sprintf(format, "most", *c.spec.Max), false
+	}
+	return "", true
+}
+
+func (c *configuredCheck) requiredMessage() string {
+	if c.when == nil {
+		return "is required"
+	}
+	if len(c.spec.When.In) == 1 {
+		return fmt.Sprintf("is required when %s is %s", c.spec.When.Field, c.spec.When.In[0])
+	}
+	return fmt.Sprintf("is required when %s is one of %s", c.spec.When.Field, strings.Join(c.spec.When.In, ", "))
+}
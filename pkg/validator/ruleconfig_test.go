@@ -0,0 +1,204 @@
+package validator_test
+
+import (
+	"testing"
+
+	"github.com/ArtemKVD/WB-TechL0/pkg/models"
+	"github.com/ArtemKVD/WB-TechL0/pkg/validator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRules_Checks(t *testing.T) {
+	tests := []struct {
+		name       string
+		rules      string
+		modify     func(*models.Order)
+		violations []validator.Violation
+	}{
+		{
+			name:   "allowed value",
+			rules:  `{field: delivery_service, in: [meest, cdek]}`,
+			modify: func(*models.Order) {},
+		},
+		{
+			name:   "value not allowed",
+			rules:  `{field: delivery_service, in: [cdek]}`,
+			modify: func(*models.Order) {},
+			violations: []validator.Violation{
+				{Path: "delivery_service", Value: "meest", Message: "must be one of cdek"},
+			},
+		},
+		{
+			name:  "numbers are compared as text",
+			rules: `{field: "items[*].status", in: ["202"]}`,
+			modify: func(order *models.Order) {
+				order.Items = append(order.Items, order.Items[0])
+				order.Items[1].Status = 400
+			},
+			violations: []validator.Violation{
+				{Path: "items[1].status", Value: models.ItemStatusCanceled, Message: "must be one of 202"},
+			},
+		},
+		{
+			name:   "number below min",
+			rules:  `{field: "items[*].price", min: 500}`,
+			modify: func(*models.Order) {},
+			violations: []validator.Violation{
+				{Path: "items[0].price", Value: models.Money(453), Message: "must be at least 500"},
+			},
+		},
+		{
+			name:   "list longer than max",
+			rules:  `{field: items, max: 1}`,
+			modify: func(order *models.Order) { order.Items = append(order.Items, order.Items[0]) },
+			violations: []validator.Violation{
+				{Path: "items", Value: 2, Message: "must have at most 1 elements"},
+			},
+		},
+		{
+			name:   "pattern mismatch",
+			rules:  `{field: track_number, pattern: '^WB[A-Z]{2}\d+$'}`,
+			modify: func(*models.Order) {},
+			violations: []validator.Violation{
+				{Path: "track_number", Value: "WBILMTESTTRACK", Message: `must match ^WB[A-Z]{2}\d+$`},
+			},
+		},
+		{
+			name:   "empty optional string is not checked",
+			rules:  `{field: payment.request_id, in: [abc], pattern: '^a'}`,
+			modify: func(*models.Order) {},
+		},
+		{
+			name:   "required if condition holds",
+			rules:  `{field: payment.request_id, required: true, when: {field: payment.provider, in: [wbpay]}}`,
+			modify: func(*models.Order) {},
+			violations: []validator.Violation{
+				{Path: "payment.request_id", Value: "", Message: "is required when payment.provider is wbpay"},
+			},
+		},
+		{
+			name:   "condition does not hold",
+			rules:  `{field: payment.request_id, required: true, when: {field: payment.provider, in: [sbp]}}`,
+			modify: func(*models.Order) {},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := validator.ParseRules([]byte("rules:\n  - name: test\n    <<: " + tt.rules))
+			require.NoError(t, err)
+			require.Len(t, rules, 1)
+
+			order := validOrder()
+			tt.modify(&order)
+			rejected, warnings := validator.NewRuleEngine(rules...).Evaluate(order)
+			assert.Empty(t, warnings)
+
+			for i := range tt.violations {
+				tt.violations[i].Rule = "test"
+			}
+			assert.Equal(t, tt.violations, rejected)
+		})
+	}
+}
+
+func TestParseRules_JSON(t *testing.T) {
+	rules, err := validator.ParseRules([]byte(`{"rules": [
+		{"name": "banks", "severity": "warn", "field": "payment.bank", "in": ["sber"]}
+	]}`))
+	require.NoError(t, err)
+
+	rejected, warnings := validator.NewRuleEngine(rules...).Evaluate(validOrder())
+	assert.Empty(t, rejected)
+	assert.Equal(t, []validator.Violation{
+		{Path: "payment.bank", Rule: "banks", Value: "alpha", Message: "must be one of sber"},
+	}, warnings)
+}
+
+func TestParseRules_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules string
+		err   string
+	}{
+		{
+			name:  "unknown key",
+			rules: "rules:\n  - name: a\n    field: entry\n    allowed: [WBIL]",
+			err:   "parse rules: yaml: unmarshal errors:\n  line 4: field allowed not found in type validator.RuleSpec",
+		},
+		{
+			name:  "unknown field",
+			rules: "rules:\n  - name: a\n    field: payment.iban\n    required: true",
+			err:   `rules[0] "a": field "payment.iban": unknown field "iban"`,
+		},
+		{
+			name:  "no check",
+			rules: "rules:\n  - name: a\n    field: entry",
+			err:   `rules[0] "a": no check: set required, in, min, max or pattern`,
+		},
+		{
+			name:  "bad severity",
+			rules: "rules:\n  - name: a\n    severity: fatal\n    field: entry\n    required: true",
+			err:   `rules[0] "a": severity must be reject or warn, got "fatal"`,
+		},
+		{
+			name:  "pattern on a number",
+			rules: "rules:\n  - name: a\n    field: sm_id\n    pattern: '^1'",
+			err:   `rules[0] "a": pattern: field "sm_id" is not a string`,
+		},
+		{
+			name:  "invalid pattern",
+			rules: "rules:\n  - name: a\n    field: entry\n    pattern: '('",
+			err:   "rules[0] \"a\": pattern: error parsing regexp: missing closing ): `(`",
+		},
+		{
+			name:  "min above max",
+			rules: "rules:\n  - name: a\n    field: items\n    min: 5\n    max: 1",
+			err:   `rules[0] "a": min 5 is greater than max 1`,
+		},
+		{
+			name:  "condition on a list",
+			rules: "rules:\n  - name: a\n    field: entry\n    required: true\n    when: {field: 'items[*].brand', in: [x]}",
+			err:   `rules[0] "a": when: field "items[*].brand" must select a single string or number`,
+		},
+		{
+			name:  "all problems are reported",
+			rules: "rules:\n  - name: goods_total_matches_items\n    field: entry\n    required: true\n  - name: a\n    field: entry\n    required: true\n  - name: a\n    field: entry\n    required: true",
+			err:   "rules[0] \"goods_total_matches_items\": name is used by a built-in rule\nrules[2] \"a\": name is used by another rule",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := validator.ParseRules([]byte(tt.rules))
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestLoadRules_Example(t *testing.T) {
+	rules, err := validator.LoadRules("../../configs/validation-rules.example.yaml")
+	require.NoError(t, err)
+	assert.NotEmpty(t, rules)
+
+	_, err = validator.ParseRules(nil)
+	assert.NoError(t, err)
+}
+
+func TestSetRules(t *testing.T) {
+	rules, err := validator.ParseRules([]byte("rules:\n  - name: cdek_only\n    field: delivery_service\n    in: [cdek]"))
+	require.NoError(t, err)
+
+	validator.SetRules(rules)
+	t.Cleanup(func() { validator.SetRules(nil) })
+
+	violations, ok := validator.Violations(validator.ValidateOrder(validOrder()))
+	require.True(t, ok)
+	assert.Equal(t, []validator.Violation{
+		{Path: "delivery_service", Rule: "cdek_only", Value: "meest", Message: "must be one of cdek"},
+	}, violations)
+
+	validator.SetRules(nil)
+	assert.NoError(t, validator.ValidateOrder(validOrder()))
+}
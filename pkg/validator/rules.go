@@ -0,0 +1,171 @@
+package validator
+
+import (
+	"fmt"
+
+	"github.com/ArtemKVD/WB-TechL0/pkg/models"
+)
+
+// Severities of a Rule. Violations of a reject rule fail validation, those
+// of a warn rule are only reported.
+const (
+	SeverityReject = "reject"
+	SeverityWarn   = "warn"
+)
+
+// Rule is a named business rule checking the consistency of an order.
+// Violations returned by Check carry the rule name.
+type Rule struct {
+	Name     string
+	Severity string
+	Check    func(order models.Order) []Violation
+}
+
+// Names of the business rules.
+const (
+	RuleTransactionMatchesOrder = "payment_transaction_matches_order_uid"
+	RuleItemTrackNumber         = "item_track_number_matches_order"
+	RuleItemRIDUnique           = "item_rid_unique"
+	RuleItemTotalPrice          = "item_total_price_matches_sale"
+	RuleGoodsTotal              = "goods_total_matches_items"
+	RulePaymentAmount           = "amount_matches_payment_parts"
+)
+
+// BusinessRules returns the rules applied by ValidateOrder.
+func BusinessRules() []Rule {
+	return []Rule{
+		{Name: RuleTransactionMatchesOrder, Severity: SeverityReject, Check: checkTransaction},
+		{Name: RuleItemTrackNumber, Severity: SeverityReject, Check: checkItemTrackNumbers},
+		{Name: RuleItemRIDUnique, Severity: SeverityReject, Check: checkItemRIDs},
+		{Name: RuleItemTotalPrice, Severity: SeverityWarn, Check: checkItemTotalPrices},
+		{Name: RuleGoodsTotal, Severity: SeverityReject, Check: checkGoodsTotal},
+		{Name: RulePaymentAmount, Severity: SeverityReject, Check: checkPaymentAmount},
+	}
+}
+
+// RuleEngine applies a set of rules to orders.
+type RuleEngine struct {
+	rules []Rule
+}
+
+func NewRuleEngine(rules ...Rule) *RuleEngine {
+	return &RuleEngine{rules: rules}
+}
+
+// Evaluate applies every rule to order and returns the violations of
+// reject rules and of warn rules separately.
+func (e *RuleEngine) Evaluate(order models.Order) (rejected, warnings []Violation) {
+	for _, rule := range e.rules {
+		violations := rule.Check(order)
+		for i := range violations {
+			violations[i].Rule = rule.Name
+		}
+		if rule.Severity == SeverityWarn {
+			warnings = append(warnings, violations...)
+		} else {
+			rejected = append(rejected, violations...)
+		}
+	}
+	return rejected, warnings
+}
+
+func checkTransaction(order models.Order) []Violation {
+	if order.Payment.Transaction == order.OrderUID {
+		return nil
+	}
+	return []Violation{{
+		Path:    "payment.transaction",
+		Value:   order.Payment.Transaction,
+		Message: "must equal order_uid",
+	}}
+}
+
+func checkItemTrackNumbers(order models.Order) []Violation {
+	var violations []Violation
+	for i, item := range order.Items {
+		if item.TrackNumber != order.TrackNumber {
+			violations = append(violations, Violation{
+				Path:    fmt.Sprintf("items[%d].track_number", i),
+				Value:   item.TrackNumber,
+				Message: "must equal the order track_number " + order.TrackNumber,
+			})
+		}
+	}
+	return violations
+}
+
+// checkItemRIDs reports items repeating the rid of an earlier item of the
+// order. Items are stored by (order_uid, rid), so a repeated rid would
+// silently replace an item.
+func checkItemRIDs(order models.Order) []Violation {
+	var violations []Violation
+	seen := make(map[string]int, len(order.Items))
+	for i, item := range order.Items {
+		first, ok := seen[item.RID]
+		if !ok {
+			seen[item.RID] = i
+			continue
+		}
+		violations = append(violations, Violation{
+			Path:    fmt.Sprintf("items[%d].rid", i),
+			Value:   item.RID,
+			Message: fmt.Sprintf("must differ from the rid of items[%d]", first),
+		})
+	}
+	return violations
+}
+
+// checkItemTotalPrices expects total_price to be price less sale percent,
+// rounded either way.
+func checkItemTotalPrices(order models.Order) []Violation {
+	var violations []Violation
+	for i, item := range order.Items {
+		if item.Sale < 0 || item.Sale > 100 {
+			violations = append(violations, Violation{
+				Path:    fmt.Sprintf("items[%d].sale", i),
+				Value:   item.Sale,
+				Message: "must be a percentage between 0 and 100",
+			})
+			continue
+		}
+
+		// Compared in hundredths to avoid rounding twice.
+		diff := item.TotalPrice*100 - item.Price*models.Money(100-item.Sale)
+		if diff <= -100 || diff >= 100 {
+			violations = append(violations, Violation{
+				Path:    fmt.Sprintf("items[%d].total_price", i),
+				Value:   item.TotalPrice,
+				Message: fmt.Sprintf("must be price %d less %d%% sale", item.Price, item.Sale),
+			})
+		}
+	}
+	return violations
+}
+
+func checkGoodsTotal(order models.Order) []Violation {
+	var sum models.Money
+	for _, item := range order.Items {
+		sum += item.TotalPrice
+	}
+	if order.Payment.GoodsTotal == sum {
+		return nil
+	}
+	return []Violation{{
+		Path:    "payment.goods_total",
+		Value:   order.Payment.GoodsTotal,
+		Message: fmt.Sprintf("must equal the sum of item total_price %d", sum),
+	}}
+}
+
+func checkPaymentAmount(order models.Order) []Violation {
+	payment := order.Payment
+	expected := payment.GoodsTotal + payment.DeliveryCost + payment.CustomFee
+	if payment.Amount == expected {
+		return nil
+	}
+	return []Violation{{
+		Path:    "payment.amount",
+		Value:   payment.Amount,
+		Message: fmt.Sprintf("must equal goods_total + delivery_cost + custom_fee %d", expected),
+	}}
+}
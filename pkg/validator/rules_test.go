@@ -0,0 +1,268 @@
+package validator_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ArtemKVD/WB-TechL0/pkg/models"
+	"github.com/ArtemKVD/WB-TechL0/pkg/validator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type ruleCase struct {
+	name       string
+	modify     func(*models.Order)
+	violations []validator.Violation
+}
+
+func businessRule(t *testing.T, name string) validator.Rule {
+	t.Helper()
+	for _, rule := range validator.BusinessRules() {
+		if rule.Name == name {
+			return rule
+		}
+	}
+	t.Fatalf("rule %s not found", name)
+	return validator.Rule{}
+}
+
+func runRuleCases(t *testing.T, ruleName string, tests []ruleCase) {
+	t.Helper()
+	rule := businessRule(t, ruleName)
+	engine := validator.NewRuleEngine(rule)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := validOrder()
+			tt.modify(&order)
+
+			rejected, warnings := engine.Evaluate(order)
+			got := rejected
+			if rule.Severity == validator.SeverityWarn {
+				assert.Empty(t, rejected)
+				got = warnings
+			} else {
+				assert.Empty(t, warnings)
+			}
+
+			for i := range tt.violations {
+				tt.violations[i].Rule = ruleName
+			}
+			assert.Equal(t, tt.violations, got)
+		})
+	}
+}
+
+func TestRule_TransactionMatchesOrder(t *testing.T) {
+	runRuleCases(t, validator.RuleTransactionMatchesOrder, []ruleCase{
+		{
+			name:   "matching",
+			modify: func(*models.Order) {},
+		},
+		{
+			name: "different transaction",
+			modify: func(order *models.Order) {
+				order.Payment.Transaction = "other"
+			},
+			violations: []validator.Violation{
+				{Path: "payment.transaction", Value: "other", Message: "must equal order_uid"},
+			},
+		},
+	})
+}
+
+func TestRule_ItemTrackNumber(t *testing.T) {
+	runRuleCases(t, validator.RuleItemTrackNumber, []ruleCase{
+		{
+			name:   "matching",
+			modify: func(*models.Order) {},
+		},
+		{
+			name: "every mismatching item is reported",
+			modify: func(order *models.Order) {
+				item := order.Items[0]
+				order.Items = []models.Item{item, item, item}
+				order.Items[0].TrackNumber = "WBILOTHER"
+				order.Items[2].TrackNumber = ""
+			},
+			violations: []validator.Violation{
+				{Path: "items[0].track_number", Value: "WBILOTHER", Message: "must equal the order track_number WBILMTESTTRACK"},
+				{Path: "items[2].track_number", Value: "", Message: "must equal the order track_number WBILMTESTTRACK"},
+			},
+		},
+	})
+}
+
+func TestRule_ItemRIDUnique(t *testing.T) {
+	runRuleCases(t, validator.RuleItemRIDUnique, []ruleCase{
+		{
+			name: "distinct rids",
+			modify: func(order *models.Order) {
+				item := order.Items[0]
+				item.RID = "other"
+				order.Items = append(order.Items, item)
+			},
+		},
+		{
+			name: "repeated rids",
+			modify: func(order *models.Order) {
+				item := order.Items[0]
+				order.Items = []models.Item{item, item, item}
+				order.Items[1].RID = "other"
+			},
+			violations: []validator.Violation{
+				{Path: "items[2].rid", Value: "ab4219087a764ae0btest", Message: "must differ from the rid of items[0]"},
+			},
+		},
+	})
+}
+
+func TestRule_ItemTotalPrice(t *testing.T) {
+	runRuleCases(t, validator.RuleItemTotalPrice, []ruleCase{
+		{
+			name:   "rounded down",
+			modify: func(*models.Order) {},
+		},
+		{
+			name: "rounded up",
+			modify: func(order *models.Order) {
+				order.Items[0].TotalPrice = 318
+			},
+		},
+		{
+			name: "no sale",
+			modify: func(order *models.Order) {
+				order.Items[0].Sale = 0
+				order.Items[0].TotalPrice = 453
+			},
+		},
+		{
+			name: "full sale",
+			modify: func(order *models.Order) {
+				order.Items[0].Sale = 100
+				order.Items[0].TotalPrice = 0
+			},
+		},
+		{
+			name: "off by one",
+			modify: func(order *models.Order) {
+				order.Items[0].TotalPrice = 319
+			},
+			violations: []validator.Violation{
+				{Path: "items[0].total_price", Value: models.Money(319), Message: "must be price 453 less 30% sale"},
+			},
+		},
+		{
+			name: "sale out of range",
+			modify: func(order *models.Order) {
+				order.Items[0].Sale = 120
+			},
+			violations: []validator.Violation{
+				{Path: "items[0].sale", Value: 120, Message: "must be a percentage between 0 and 100"},
+			},
+		},
+	})
+}
+
+func TestRule_GoodsTotal(t *testing.T) {
+	runRuleCases(t, validator.RuleGoodsTotal, []ruleCase{
+		{
+			name:   "matching",
+			modify: func(*models.Order) {},
+		},
+		{
+			name: "sum of several items",
+			modify: func(order *models.Order) {
+				order.Items = append(order.Items, order.Items[0])
+				order.Payment.GoodsTotal = 634
+			},
+		},
+		{
+			name: "mismatch",
+			modify: func(order *models.Order) {
+				order.Payment.GoodsTotal = 300
+			},
+			violations: []validator.Violation{
+				{Path: "payment.goods_total", Value: models.Money(300), Message: "must equal the sum of item total_price 317"},
+			},
+		},
+	})
+}
+
+func TestRule_PaymentAmount(t *testing.T) {
+	runRuleCases(t, validator.RulePaymentAmount, []ruleCase{
+		{
+			name:   "matching",
+			modify: func(*models.Order) {},
+		},
+		{
+			name: "with custom fee",
+			modify: func(order *models.Order) {
+				order.Payment.CustomFee = 83
+				order.Payment.Amount = 1900
+			},
+		},
+		{
+			name: "custom fee missing from amount",
+			modify: func(order *models.Order) {
+				order.Payment.CustomFee = 83
+			},
+			violations: []validator.Violation{
+				{Path: "payment.amount", Value: models.Money(1817), Message: "must equal goods_total + delivery_cost + custom_fee 1900"},
+			},
+		},
+	})
+}
+
+func TestCheckOrder_Severities(t *testing.T) {
+	t.Run("warn rules do not reject", func(t *testing.T) {
+		order := validOrder()
+		order.Items[0].TotalPrice = 300
+		order.Payment.GoodsTotal = 300
+		order.Payment.Amount = 1800
+
+		warnings, err := validator.CheckOrder(order)
+
+		require.NoError(t, err)
+		assert.Equal(t, []validator.Violation{{
+			Path:    "items[0].total_price",
+			Rule:    validator.RuleItemTotalPrice,
+			Value:   models.Money(300),
+			Message: "must be price 453 less 30% sale",
+		}}, warnings)
+		assert.NoError(t, validator.ValidateOrder(order))
+	})
+
+	t.Run("reject rules fail validation", func(t *testing.T) {
+		order := validOrder()
+		order.Payment.Transaction = "other"
+		order.Payment.Amount = 1
+
+		_, err := validator.CheckOrder(order)
+
+		var validationErr *validator.ValidationError
+		require.True(t, errors.As(err, &validationErr))
+		assert.Equal(t, []string{"payment.transaction", "payment.amount"}, paths(validationErr.Violations))
+	})
+
+	t.Run("not applied to incomplete orders", func(t *testing.T) {
+		order := validOrder()
+		order.OrderUID = ""
+
+		warnings, err := validator.CheckOrder(order)
+
+		assert.Empty(t, warnings)
+		violations, ok := validator.Violations(err)
+		require.True(t, ok)
+		assert.Equal(t, []string{"order_uid"}, paths(violations))
+	})
+}
+
+func paths(violations []validator.Violation) []string {
+	result := make([]string, len(violations))
+	for i, violation := range violations {
+		result[i] = violation.Path
+	}
+	return result
+}
@@ -3,7 +3,9 @@ package validator
 import (
 	"fmt"
 	"log"
+	"reflect"
 	"regexp"
+	"strings"
 	"time"
 
 	"github.com/ArtemKVD/WB-TechL0/pkg/models"
@@ -14,9 +16,26 @@ var (
 	validate   = validator.New()
 	emailRegex = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
 	phoneRegex = regexp.MustCompile(`^\+[1-9]\d{1,14}$`)
+
+	// Unix times before minUnixTime or more than maxClockSkew ahead of now
+	// are rejected as bogus.
+	minUnixTime  = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+	maxClockSkew = 24 * time.Hour
+
+	businessRules = NewRuleEngine(BusinessRules()...)
 )
 
 func init() {
+	// Violations are reported by JSON path, so fields are named by their
+	// json tags.
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
 	err := validate.RegisterValidation("phone", validatePhone)
 	if err != nil {
 		log.Println("Error register validation phone: ", err)
@@ -29,19 +48,57 @@ func init() {
 	if err != nil {
 		log.Println("Error register validation timestamp: ", err)
 	}
+	err = validate.RegisterValidation("unix_time", validateUnixTime)
+	if err != nil {
+		log.Println("Error register validation unix_time: ", err)
+	}
+	err = validate.RegisterValidation("zip", validateZip)
+	if err != nil {
+		log.Println("Error register validation zip: ", err)
+	}
+	err = validate.RegisterValidation("item_status", validateItemStatus)
+	if err != nil {
+		log.Println("Error register validation item_status: ", err)
+	}
+
+	// Times are checked as they are sent: date_created as a string and
+	// payment_dt as seconds, so a zero time fails required.
+	validate.RegisterCustomTypeFunc(func(field reflect.Value) any {
+		return field.Interface().(models.Timestamp).String()
+	}, models.Timestamp{})
+	validate.RegisterCustomTypeFunc(func(field reflect.Value) any {
+		return field.Interface().(models.UnixTime).Seconds()
+	}, models.UnixTime{})
 }
 
+// ValidateOrder checks order and returns a *ValidationError with every
+// violation found, or nil. Violations of warn rules are ignored.
 func ValidateOrder(order models.Order) error {
-	err := validate.Struct(order)
+	_, err := CheckOrder(order)
+	return err
+}
+
+// CheckOrder validates order like ValidateOrder and also returns the
+// violations of warn rules, which do not fail validation. Business rules
+// and the rules set by SetRules are only applied to orders that have all
+// required fields, as their violations would just repeat the missing ones.
+func CheckOrder(order models.Order) ([]Violation, error) {
+	violations, err := structViolations(validate.Struct(order))
 	if err != nil {
-		return fmt.Errorf("order validation failed: %v", err)
+		return nil, fmt.Errorf("order validation failed: %w", err)
 	}
-	err = validateItems(order.Items)
-	if err != nil {
-		return err
+	violations = append(violations, itemViolations(order.Items, violations)...)
+	if len(violations) > 0 {
+		return nil, newValidationError(violations)
 	}
 
-	return nil
+	rejected, warnings := businessRules.Evaluate(order)
+	if configured := configuredRules.Load(); configured != nil {
+		configuredRejected, configuredWarnings := configured.Evaluate(order)
+		rejected = append(rejected, configuredRejected...)
+		warnings = append(warnings, configuredWarnings...)
+	}
+	return warnings, newValidationError(rejected)
 }
 
 func validatePhone(fl validator.FieldLevel) bool {
@@ -60,28 +117,56 @@ func validateTimestamp(fl validator.FieldLevel) bool {
 	return err == nil
 }
 
-func validateItems(items []models.Item) error {
-	if len(items) == 0 {
-		return fmt.Errorf("order item is nil")
+func validateUnixTime(fl validator.FieldLevel) bool {
+	unix := fl.Field().Int()
+	return unix >= minUnixTime.Unix() && unix <= time.Now().Add(maxClockSkew).Unix()
+}
+
+func validateItemStatus(fl validator.FieldLevel) bool {
+	return models.ItemStatus(fl.Field().Int()).Known()
+}
+
+// validateZip checks the zip against the format of the region in the
+// sibling Region field.
+func validateZip(fl validator.FieldLevel) bool {
+	region := fl.Parent().FieldByName("Region")
+	if !region.IsValid() || region.Kind() != reflect.String {
+		return false
 	}
+	return zipFormat(region.String()).MatchString(fl.Field().String())
+}
 
-	for _, item := range items {
-		if item.Price < 0 {
-			return fmt.Errorf("item price < 0")
+// itemViolations reports an empty item list, which required lets through
+// as long as it is not nil. It is not reported again if the tags already
+// flagged it.
+func itemViolations(items []models.Item, found []Violation) []Violation {
+	if len(items) > 0 {
+		return nil
+	}
+	for _, violation := range found {
+		if violation.Path == "items" {
+			return nil
 		}
 	}
-
-	return nil
+	return []Violation{{Path: "items", Rule: "required", Message: ruleMessage("required", "")}}
 }
 
 func ValidateDelivery(delivery models.Delivery) error {
-	return validate.Struct(delivery)
+	return validateStruct(delivery)
 }
 
 func ValidatePayment(payment models.Payment) error {
-	return validate.Struct(payment)
+	return validateStruct(payment)
 }
 
 func ValidateItem(item models.Item) error {
-	return validate.Struct(item)
+	return validateStruct(item)
+}
+
+func validateStruct(value any) error {
+	violations, err := structViolations(validate.Struct(value))
+	if err != nil {
+		return err
+	}
+	return newValidationError(violations)
 }
@@ -0,0 +1,325 @@
+package validator_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ArtemKVD/WB-TechL0/pkg/models"
+	"github.com/ArtemKVD/WB-TechL0/pkg/validator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func validOrder() models.Order {
+	return models.Order{
+		OrderUID:    "b563feb7b2b84b6test",
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery: models.Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "test@gmail.com",
+		},
+		Payment: models.Payment{
+			Transaction:  "b563feb7b2b84b6test",
+			Currency:     "USD",
+			Provider:     "wbpay",
+			Amount:       1817,
+			PaymentDt:    models.UnixTime{Time: time.Unix(1637907727, 0).UTC()},
+			Bank:         "alpha",
+			DeliveryCost: 1500,
+			GoodsTotal:   317,
+		},
+		Items: []models.Item{{
+			ChrtID:      9934930,
+			TrackNumber: "WBILMTESTTRACK",
+			Price:       453,
+			RID:         "ab4219087a764ae0btest",
+			Name:        "Mascaras",
+			Sale:        30,
+			Size:        "0",
+			TotalPrice:  317,
+			NmID:        2389212,
+			Brand:       "Vivienne Sabo",
+			Status:      202,
+		}},
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		ShardKey:        "9",
+		SMID:            99,
+		DateCreated:     models.Timestamp{Time: time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC)},
+		OOFShard:        "1",
+	}
+}
+
+// decodeTimestamp decodes value as date_created is decoded from a message.
+func decodeTimestamp(value string) models.Timestamp {
+	var timestamp models.Timestamp
+	err := json.Unmarshal([]byte(`"`+value+`"`), &timestamp)
+	if err != nil {
+		panic(err)
+	}
+	return timestamp
+}
+
+func TestValidateOrder(t *testing.T) {
+	tests := []struct {
+		name       string
+		modify     func(*models.Order)
+		violations []validator.Violation
+	}{
+		{
+			name:   "valid order",
+			modify: func(*models.Order) {},
+		},
+		{
+			name: "missing fields are reported by JSON path",
+			modify: func(order *models.Order) {
+				order.TrackNumber = ""
+				order.Delivery.Email = ""
+				order.Payment.Amount = 0
+			},
+			violations: []validator.Violation{
+				{Path: "track_number", Rule: "required", Value: "", Message: "is required"},
+				{Path: "delivery.email", Rule: "required", Value: "", Message: "is required"},
+				{Path: "payment.amount", Rule: "required", Value: models.Money(0), Message: "is required"},
+			},
+		},
+		{
+			name: "missing times",
+			modify: func(order *models.Order) {
+				order.DateCreated = models.Timestamp{}
+				order.Payment.PaymentDt = models.UnixTime{}
+			},
+			violations: []validator.Violation{
+				{Path: "payment.payment_dt", Rule: "required", Value: int64(0), Message: "is required"},
+				{Path: "date_created", Rule: "required", Value: "", Message: "is required"},
+			},
+		},
+		{
+			name: "no items",
+			modify: func(order *models.Order) {
+				order.Items = nil
+			},
+			violations: []validator.Violation{
+				{Path: "items", Rule: "required", Message: "is required"},
+			},
+		},
+		{
+			name: "empty item list",
+			modify: func(order *models.Order) {
+				order.Items = []models.Item{}
+			},
+			violations: []validator.Violation{
+				{Path: "items", Rule: "required", Message: "is required"},
+			},
+		},
+		{
+			name: "item field missing",
+			modify: func(order *models.Order) {
+				order.Items[0].RID = ""
+			},
+			violations: []validator.Violation{
+				{Path: "items[0].rid", Rule: "required", Value: "", Message: "is required"},
+			},
+		},
+		{
+			name: "every failing item and field is collected",
+			modify: func(order *models.Order) {
+				item := order.Items[0]
+				item.Price = -1
+				order.Items = []models.Item{item, order.Items[0], item}
+				order.Items[2].Price = -5
+				order.Locale = ""
+			},
+			violations: []validator.Violation{
+				{Path: "items[0].price", Rule: "min", Value: models.Money(-1), Message: "must be at least 0"},
+				{Path: "items[2].price", Rule: "min", Value: models.Money(-5), Message: "must be at least 0"},
+				{Path: "locale", Rule: "required", Value: "", Message: "is required"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := validOrder()
+			tt.modify(&order)
+
+			err := validator.ValidateOrder(order)
+			if tt.violations == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			var validationErr *validator.ValidationError
+			require.True(t, errors.As(err, &validationErr), "got %v", err)
+			assert.Equal(t, tt.violations, validationErr.Violations)
+		})
+	}
+}
+
+func TestValidateOrder_Formats(t *testing.T) {
+	future := time.Now().Add(48 * time.Hour).Unix()
+
+	tests := []struct {
+		name      string
+		modify    func(*models.Order)
+		violation *validator.Violation
+	}{
+		{
+			name:      "phone without country code",
+			modify:    func(order *models.Order) { order.Delivery.Phone = "89001234567" },
+			violation: &validator.Violation{Path: "delivery.phone", Rule: "phone", Value: "89001234567", Message: "must be a phone number in E.164 format"},
+		},
+		{
+			name:      "malformed email",
+			modify:    func(order *models.Order) { order.Delivery.Email = "test@" },
+			violation: &validator.Violation{Path: "delivery.email", Rule: "email", Value: "test@", Message: "must be a valid email address"},
+		},
+		{
+			name:      "date_created not RFC 3339",
+			modify:    func(order *models.Order) { order.DateCreated = decodeTimestamp("2021-11-26 06:22:19") },
+			violation: &validator.Violation{Path: "date_created", Rule: "timestamp", Value: "2021-11-26 06:22:19", Message: "must be an RFC 3339 timestamp"},
+		},
+		{
+			name:   "date_created with offset",
+			modify: func(order *models.Order) { order.DateCreated = decodeTimestamp("2021-11-26T09:22:19.123+03:00") },
+		},
+		{
+			name:      "unknown item status",
+			modify:    func(order *models.Order) { order.Items[0].Status = 999 },
+			violation: &validator.Violation{Path: "items[0].status", Rule: "item_status", Value: models.ItemStatus(999), Message: "must be a known item status"},
+		},
+		{
+			name:      "unknown currency",
+			modify:    func(order *models.Order) { order.Payment.Currency = "XYZ" },
+			violation: &validator.Violation{Path: "payment.currency", Rule: "iso4217", Value: "XYZ", Message: "must be an ISO 4217 currency code"},
+		},
+		{
+			name:      "lower-case currency",
+			modify:    func(order *models.Order) { order.Payment.Currency = "usd" },
+			violation: &validator.Violation{Path: "payment.currency", Rule: "iso4217", Value: "usd", Message: "must be an ISO 4217 currency code"},
+		},
+		{
+			name:   "regional locale",
+			modify: func(order *models.Order) { order.Locale = "ru-RU" },
+		},
+		{
+			name:      "malformed locale",
+			modify:    func(order *models.Order) { order.Locale = "en_US!" },
+			violation: &validator.Violation{Path: "locale", Rule: "bcp47_language_tag", Value: "en_US!", Message: "must be a BCP 47 language tag"},
+		},
+		{
+			name:      "payment_dt before 2000",
+			modify:    func(order *models.Order) { order.Payment.PaymentDt = models.UnixTime{Time: time.Unix(86400, 0)} },
+			violation: &validator.Violation{Path: "payment.payment_dt", Rule: "unix_time", Value: int64(86400), Message: "must be a unix time between 2000-01-01 and one day from now"},
+		},
+		{
+			name:      "payment_dt in the future",
+			modify:    func(order *models.Order) { order.Payment.PaymentDt = models.UnixTime{Time: time.Unix(future, 0)} },
+			violation: &validator.Violation{Path: "payment.payment_dt", Rule: "unix_time", Value: future, Message: "must be a unix time between 2000-01-01 and one day from now"},
+		},
+		{
+			name: "US zip",
+			modify: func(order *models.Order) {
+				order.Delivery.Region = "New York"
+				order.Delivery.Zip = "10001-0001"
+			},
+		},
+		{
+			name: "zip of another country",
+			modify: func(order *models.Order) {
+				order.Delivery.Region = "Texas"
+				order.Delivery.Zip = "2639809"
+			},
+			violation: &validator.Violation{Path: "delivery.zip", Rule: "zip", Value: "2639809", Message: "must be a postal code valid for the region"},
+		},
+		{
+			name: "region is matched regardless of case",
+			modify: func(order *models.Order) {
+				order.Delivery.Region = "москва"
+				order.Delivery.Zip = "10100"
+			},
+			violation: &validator.Violation{Path: "delivery.zip", Rule: "zip", Value: "10100", Message: "must be a postal code valid for the region"},
+		},
+		{
+			name: "unknown region",
+			modify: func(order *models.Order) {
+				order.Delivery.Region = "England"
+				order.Delivery.Zip = "SW1A 1AA"
+			},
+		},
+		{
+			name: "region name used by several countries",
+			modify: func(order *models.Order) {
+				order.Delivery.Region = "Georgia"
+				order.Delivery.Zip = "0105"
+			},
+		},
+		{
+			name: "unknown region with implausible zip",
+			modify: func(order *models.Order) {
+				order.Delivery.Region = "England"
+				order.Delivery.Zip = "#1"
+			},
+			violation: &validator.Violation{Path: "delivery.zip", Rule: "zip", Value: "#1", Message: "must be a postal code valid for the region"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := validOrder()
+			tt.modify(&order)
+
+			err := validator.ValidateOrder(order)
+			if tt.violation == nil {
+				assert.NoError(t, err)
+				return
+			}
+			violations, ok := validator.Violations(err)
+			require.True(t, ok, "got %v", err)
+			assert.Equal(t, []validator.Violation{*tt.violation}, violations)
+		})
+	}
+}
+
+func TestValidationError(t *testing.T) {
+	order := validOrder()
+	order.Delivery.Phone = ""
+	order.Items[0].Price = -1
+
+	err := validator.ValidateOrder(order)
+
+	assert.EqualError(t, err, "order validation failed: delivery.phone is required; items[0].price must be at least 0")
+
+	violations, ok := validator.Violations(err)
+	require.True(t, ok)
+	assert.Len(t, violations, 2)
+
+	encoded, err := json.Marshal(err)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"violations": [
+		{"path": "delivery.phone", "rule": "required", "value": "", "message": "is required"},
+		{"path": "items[0].price", "rule": "min", "value": -1, "message": "must be at least 0"}
+	]}`, string(encoded))
+
+	_, ok = validator.Violations(errors.New("not a validation error"))
+	assert.False(t, ok)
+}
+
+func TestValidateDelivery(t *testing.T) {
+	delivery := validOrder().Delivery
+	assert.NoError(t, validator.ValidateDelivery(delivery))
+
+	delivery.City = ""
+	violations, ok := validator.Violations(validator.ValidateDelivery(delivery))
+	require.True(t, ok)
+	assert.Equal(t, []validator.Violation{{Path: "city", Rule: "required", Value: "", Message: "is required"}}, violations)
+}
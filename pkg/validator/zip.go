@@ -0,0 +1,50 @@
+package validator
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	usZip      = regexp.MustCompile(`^\d{5}(-\d{4})?$`)
+	israelZip  = regexp.MustCompile(`^\d{7}$`)
+	russiaZip  = regexp.MustCompile(`^\d{6}$`)
+	genericZip = regexp.MustCompile(`^[0-9A-Za-z][0-9A-Za-z -]{1,8}[0-9A-Za-z]$`)
+)
+
+// zipFormats maps lower-cased region names to the postal code format of
+// their country. Orders carry no country, so names that regions of other
+// countries share, such as Georgia or Central, are left out. Zips of other
+// regions only have to look like a postal code: 3 to 10 letters and digits,
+// possibly separated by spaces or dashes.
+var zipFormats = map[string]*regexp.Regexp{}
+
+func init() {
+	addZipFormat(usZip,
+		"Alabama", "Alaska", "Arizona", "Arkansas", "California", "Colorado", "Connecticut", "Delaware",
+		"District of Columbia", "Florida", "Hawaii", "Idaho", "Illinois", "Indiana", "Iowa",
+		"Kansas", "Kentucky", "Louisiana", "Maine", "Maryland", "Massachusetts", "Michigan", "Minnesota",
+		"Mississippi", "Missouri", "Montana", "Nebraska", "Nevada", "New Hampshire", "New Jersey",
+		"New Mexico", "New York", "North Carolina", "North Dakota", "Ohio", "Oklahoma", "Oregon",
+		"Pennsylvania", "Rhode Island", "South Carolina", "South Dakota", "Tennessee", "Texas", "Utah",
+		"Vermont", "Virginia", "Washington", "West Virginia", "Wisconsin", "Wyoming")
+	addZipFormat(israelZip,
+		"Kraiot", "Haifa", "Tel Aviv", "Jerusalem")
+	addZipFormat(russiaZip,
+		"Moscow", "Москва", "Moscow Oblast", "Московская область",
+		"Saint Petersburg", "Санкт-Петербург", "Leningrad Oblast", "Ленинградская область")
+}
+
+func addZipFormat(format *regexp.Regexp, regions ...string) {
+	for _, region := range regions {
+		zipFormats[strings.ToLower(region)] = format
+	}
+}
+
+func zipFormat(region string) *regexp.Regexp {
+	format, ok := zipFormats[strings.ToLower(strings.TrimSpace(region))]
+	if !ok {
+		return genericZip
+	}
+	return format
+}